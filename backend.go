@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"os/exec"
+	"slices"
+	"sync"
+	"time"
+)
+
+// TurnRequest is everything a backend needs to run one conversation turn.
+type TurnRequest struct {
+	Prompt    string
+	SessionID string // session to resume, empty for a new conversation
+	PermMode  PermissionMode
+	Model     string // --model alias or name, empty for claude's default
+	// FallbackModel is used when Model is overloaded (--fallback-model).
+	FallbackModel string
+	// AllowedTools are extra allow rules (e.g. "Write", "Bash(git:*)")
+	// passed as --allowedTools.
+	AllowedTools []string
+}
+
+// sameProcessOptions reports whether a and b can share one claude process:
+// everything except the prompt and session is fixed at spawn time.
+func sameProcessOptions(a, b TurnRequest) bool {
+	return a.PermMode == b.PermMode && a.Model == b.Model && a.FallbackModel == b.FallbackModel &&
+		slices.Equal(a.AllowedTools, b.AllowedTools)
+}
+
+// Backend is a transport that runs conversation turns against claude.
+// All backends emit the same normalized StreamMsg events, so the chat model
+// consumes one stream regardless of how claude is being driven.
+type Backend interface {
+	// StartTurn sends a prompt and returns a channel of events for the turn.
+	// The channel is closed after the final StreamMsg{Done: true}.
+	// It may block (e.g. while spawning a process) and is called from a tea.Cmd.
+	StartTurn(req TurnRequest) (<-chan StreamMsg, error)
+	// Cancel aborts the turn in flight, if any. The turn's channel still
+	// delivers a final Done message.
+	Cancel()
+	// Close cancels any turn in flight and releases long-lived resources.
+	Close() error
+}
+
+// promptSource is implemented by backends that script their own prompts
+// (replay), so the chat model submits them instead of waiting for input.
+type promptSource interface {
+	// NextPrompt returns the next prompt and how long to wait before
+	// submitting it, or ok=false when there are no more.
+	NextPrompt() (prompt string, delay time.Duration, ok bool)
+}
+
+// interrupter is implemented by backends that can stop a turn without
+// tearing down the process (stream-json's interrupt control request).
+// Backends without it are interrupted with Cancel.
+type interrupter interface {
+	// Interrupt asks claude to end the turn in flight. The turn's channel
+	// still delivers a final Done message.
+	Interrupt() error
+}
+
+// injector is implemented by backends that accept prompts while a turn is
+// in flight (stream-json input). Claude answers them in order after the
+// current reply, each ending with its own result event on the turn's
+// channel; the Done message follows the last one.
+type injector interface {
+	Inject(prompt string) error
+}
+
+// sessionResetter is implemented by backends that keep the conversation
+// themselves rather than resuming TurnRequest.SessionID, so /clear can make
+// them start a new one.
+type sessionResetter interface {
+	ResetSession()
+}
+
+// PrintBackend spawns a fresh `claude -p` process for every turn.
+type PrintBackend struct {
+	mu  sync.Mutex
+	cmd *exec.Cmd // process for the turn in flight, nil when idle
+}
+
+// NewPrintBackend creates a backend that runs one process per prompt.
+func NewPrintBackend() *PrintBackend {
+	return &PrintBackend{}
+}
+
+// StartTurn spawns claude in print mode for req.
+func (b *PrintBackend) StartTurn(req TurnRequest) (<-chan StreamMsg, error) {
+	ch, cmd, err := StreamClaude(req)
+	if err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	b.cmd = cmd
+	b.mu.Unlock()
+
+	// Forget the process once the turn is over so Cancel doesn't signal a stale pid.
+	out := make(chan StreamMsg, cap(ch))
+	go func() {
+		defer close(out)
+		for msg := range ch {
+			if msg.Done {
+				b.mu.Lock()
+				if b.cmd == cmd {
+					b.cmd = nil
+				}
+				b.mu.Unlock()
+			}
+			out <- msg
+		}
+	}()
+	return out, nil
+}
+
+// Cancel terminates the process for the turn in flight.
+func (b *PrintBackend) Cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.cmd != nil {
+		gracefulKill(b.cmd)
+	}
+}
+
+// Close is Cancel; print mode holds nothing between turns.
+func (b *PrintBackend) Close() error {
+	b.Cancel()
+	return nil
+}
+
+// syntheticTextDelta wraps plain text in a stream_event text_delta, so
+// transports without structured output still flow through extractDeltas.
+func syntheticTextDelta(text string) *StreamEvent {
+	raw, _ := json.Marshal(map[string]any{
+		"type": "stream_event",
+		"event": map[string]any{
+			"type":  "content_block_delta",
+			"delta": map[string]any{"type": "text_delta", "text": text},
+		},
+	})
+	return &StreamEvent{Type: "stream_event", Raw: string(raw), ReceivedAt: time.Now()}
+}
+
+// adaptTextStream converts a channel of plain text chunks into StreamMsgs:
+// one synthetic text_delta per chunk, then a Done carrying the collected events.
+// The response has no result event, so it carries no cost or usage.
+func adaptTextStream(prompt string, in <-chan string) <-chan StreamMsg {
+	startedAt := time.Now()
+	out := make(chan StreamMsg, 64)
+	go func() {
+		defer close(out)
+		var events []StreamEvent
+		for text := range in {
+			ev := syntheticTextDelta(text)
+			events = append(events, *ev)
+			out <- StreamMsg{Event: ev}
+		}
+		out <- StreamMsg{Done: true, Response: &ClaudeResponse{
+			Prompt:    prompt,
+			Events:    events,
+			StartedAt: startedAt,
+		}}
+	}()
+	return out
+}
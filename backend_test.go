@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSyntheticTextDelta(t *testing.T) {
+	ev := syntheticTextDelta("hello \"world\"\n")
+	if ev.Type != "stream_event" {
+		t.Errorf("Type = %q, want stream_event", ev.Type)
+	}
+	got := extractDeltas(ev.Raw)
+	if got.Text != "hello \"world\"\n" {
+		t.Errorf("extractDeltas().Text = %q, want round-tripped text", got.Text)
+	}
+}
+
+func TestAdaptTextStream(t *testing.T) {
+	in := make(chan string, 2)
+	in <- "hel"
+	in <- "lo"
+	close(in)
+
+	var text string
+	var done *StreamMsg
+	for msg := range adaptTextStream("prompt", in) {
+		if msg.Done {
+			done = &msg
+			continue
+		}
+		if done != nil {
+			t.Fatal("event received after Done")
+		}
+		text += extractDeltas(msg.Event.Raw).Text
+	}
+
+	if text != "hello" {
+		t.Errorf("streamed text = %q, want 'hello'", text)
+	}
+	if done == nil || done.Response == nil {
+		t.Fatalf("missing Done with response: %+v", done)
+	}
+	if done.Response.Prompt != "prompt" {
+		t.Errorf("Prompt = %q, want 'prompt'", done.Response.Prompt)
+	}
+	if len(done.Response.Events) != 2 {
+		t.Errorf("got %d events, want 2", len(done.Response.Events))
+	}
+	if done.Response.HasResult() {
+		t.Error("text stream should not report a result")
+	}
+}
+
+func TestWaitForStreamMsgSyntheticDelta(t *testing.T) {
+	ch := make(chan StreamMsg, 1)
+	ch <- StreamMsg{Event: syntheticTextDelta("chunk")}
+	close(ch)
+
+	msg := waitForStreamMsg(ch)()
+	chunk, ok := msg.(ClaudeStreamChunkMsg)
+	if !ok {
+		t.Fatalf("got %T, want ClaudeStreamChunkMsg", msg)
+	}
+	if chunk.TextDelta != "chunk" {
+		t.Errorf("TextDelta = %q, want 'chunk'", chunk.TextDelta)
+	}
+
+	if _, ok := waitForStreamMsg(ch)().(ClaudeStreamDoneMsg); !ok {
+		t.Error("closed channel should yield ClaudeStreamDoneMsg")
+	}
+}
@@ -3,34 +3,38 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"log"
-	"os/exec"
+	"os"
+	"slices"
 	"strings"
 	"time"
 
-	"charm.land/bubbles/v2/key"
 	"charm.land/bubbles/v2/textarea"
-	"charm.land/bubbles/v2/viewport"
+	"charm.land/bubbles/v2/textinput"
 	tea "charm.land/bubbletea/v2"
 	"charm.land/lipgloss/v2"
+	"github.com/alecthomas/chroma/v2"
 	"github.com/charmbracelet/glamour"
-	"github.com/charmbracelet/glamour/styles"
 )
 
 type chatEntry struct {
-	role       string      // "user", "assistant", or "error"
-	text       string      // plain text for user/error; fallback text for assistant
-	blocks     []ChatBlock // parsed content blocks for assistant responses
-	result     ClaudeResult
-	model      string
-	stopReason string
+	id             int         // stable key for the render cache and card IDs, see appendEntries
+	role           string      // "user", "assistant", or "error"
+	text           string      // plain text for user/error; fallback text for assistant
+	blocks         []ChatBlock // parsed content blocks for assistant responses
+	result         ClaudeResult
+	model          string
+	stopReason     string
 	hasResult      bool
 	cacheReadTok   int
 	durationMs     int
 	durationAPIMs  int
-	streaming      bool   // true while being streamed
-	streamThinking string // accumulated thinking text during streaming
-	streamText     string // accumulated raw text during streaming
+	streaming      bool               // true while being streamed
+	streamThinking string             // accumulated thinking text during streaming
+	streamText     string             // accumulated raw text during streaming
+	denials        []PermissionDenial // tool calls refused by the permission mode
+	interrupted    bool               // the user stopped the turn before it finished
+	permMode       PermissionMode     // mode the turn ran in, named on its denial card
+	transient      bool               // local command feedback, not saved or exported
 }
 
 type cardZone struct {
@@ -41,38 +45,74 @@ type cardZone struct {
 
 // ChatModel is the chat tab: viewport (history) + textarea (input) + glamour rendering.
 type ChatModel struct {
-	viewport      viewport.Model
+	viewport      virtualViewport
 	textarea      textarea.Model
 	entries       []chatEntry
 	width         int
 	height        int
 	renderer      *glamour.TermRenderer
-	sessionID     string         // persist session for --resume
-	streamCh      <-chan StreamMsg // current stream channel
-	cachedContent string          // rendered content of all finalized entries
-	scrollMode    bool            // when true, keys go to viewport instead of textarea
-	permMode      PermissionMode  // current permission mode for claude CLI
+	markdownCache map[string]string // glamour output per markdown chunk, reset with the renderer
+	sessionID     string            // persist session for --resume
+	streamCh      <-chan StreamMsg  // current stream channel
+	interrupting  bool              // ctrl+x: the turn in flight is being stopped
+	turnSessionID string            // session ID from the current turn's init event
+	queued        []queuedPrompt    // prompts submitted while streaming, see queue.go
+	scrollMode    bool              // when true, keys go to viewport instead of textarea
+	showTodos     bool              // ctrl+t: pin the todo list beside the viewport
+	todos         []TodoItem        // latest TodoWrite list, refreshed on render
+	permMode      PermissionMode    // current permission mode for claude CLI
+	turnPermMode  PermissionMode    // permission mode of the last turn started
+	model         string            // --model for the next turns, empty for claude's default
+	fallbackModel string            // --fallback-model, empty for none
+	turnModel     string            // model requested for the last turn started
+	modelPicking  bool              // /model picker open (see models.go)
+	modelSel      int
+
+	// Slash command completion popup (see slash.go)
+	slashSel       int    // selected match
+	slashQuery     string // input the selection belongs to
+	slashDismissed string // input the popup was closed on with esc
+
+	// @file finder (see mention.go)
+	mentionFiles     []string // files and directories under cwd
+	mentionListing   bool     // a listing is in flight
+	mentionListedAt  time.Time
+	mentionSel       int
+	mentionQuery     string   // mention the matches and selection belong to
+	mentionMatched   []string // matches for mentionQuery
+	mentionDismissed string   // mention the finder was closed on with esc
+	inlineMentions   bool     // append small mentioned files to prompts
+
+	// Prompt history (see history.go, nil disables it)
+	history          *PromptHistory
+	historyPos       int    // index of the prompt shown, -1 when not browsing
+	historyDraft     string // input saved when browsing started
+	historySearching bool   // ctrl+r search open
+	historyQuery     string
+	historySel       int // selected match, 0 is the newest
 
 	// Session-level cumulative stats for status line
-	totalCost      float64
-	totalInputTok  int
-	totalOutputTok int
-	totalRequests  int
-	lastModel      string
-	lastCost       float64
-	lastInputTok   int
-	lastCacheRead     int
-	lastCacheCreation int
-	lastDurationMs    int
-	lastAPIMs      int
+	totalCost          float64
+	totalInputTok      int
+	totalOutputTok     int
+	totalRequests      int
+	lastModel          string
+	lastRequestedModel string // turnModel of the turn lastModel answered
+	lastCost           float64
+	lastInputTok       int
+	lastCacheRead      int
+	lastCacheCreation  int
+	lastDurationMs     int
+	lastAPIMs          int
 
 	// Init event data (shown as startup banner)
-	initModel      string
-	initVersion    string
-	initNumTools   int
-	initPermMode   string
-	initPlugins    []string
-	initReceived   bool
+	initModel         string
+	initVersion       string
+	initNumTools      int
+	initPermMode      string
+	initPlugins       []string
+	initSlashCommands []string
+	initReceived      bool
 
 	// Rate limit tracking
 	rateLimitStatus    string // "allowed", "throttled"
@@ -80,38 +120,66 @@ type ChatModel struct {
 	rateLimitOverage   string // "allowed", "throttled"
 	rateLimitIsOverage bool
 
-	// Active streaming process, for cancellation on ctrl+c
-	streamCmd *exec.Cmd
+	// Transport that runs turns (print, persistent, or interactive)
+	backend Backend
 
-	// Interactive mode (experimental goexpect-based session)
-	interactive   bool                 // use interactive session instead of print mode
-	iSession      *InteractiveSession  // persistent interactive session
-	iStreamCh     <-chan string         // current interactive response stream
+	// Session persistence (nil store disables saving)
+	store           *SessionStore
+	cwd             string
+	savedID         string // ID in the store, assigned on first save
+	savedCreatedAt  time.Time
+	savedSessionIDs []string // claude session IDs seen, see SavedSession.PastSessionIDs
+
+	// Permission prompts (claude's --permission-prompt-tool shown as a modal)
+	permRequests  <-chan *PermissionRequest // from the broker, nil when disabled
+	permQueue     []*PermissionRequest      // pending requests, first one is shown
+	permAlways    map[string]bool           // tool name → allowed for the rest of the session
+	permDenying   bool                      // typing a deny message
+	permDenyInput textinput.Model
+	allowedTools  []string // allow rules added from denial cards, passed as --allowedTools
 
 	// Cached lipgloss styles (initialized in NewChatModel, updated in SetSize)
-	styleUserCard      lipgloss.Style
-	styleErrorCard     lipgloss.Style
+	styleUserCard       lipgloss.Style
+	styleErrorCard      lipgloss.Style
 	styleToolResultCard lipgloss.Style
-	styleDim           lipgloss.Style
-	styleToolName      lipgloss.Style
-	styleToolInput     lipgloss.Style
-	styleToolOutput    lipgloss.Style
-	styleToolErr       lipgloss.Style
-	styleUserLabel     lipgloss.Style
-	styleThinkingCard  lipgloss.Style
-	styleThinkingLabel lipgloss.Style
-	styleHeaderCard    lipgloss.Style
-	styleAssistantCard lipgloss.Style
-	styleToolCard      lipgloss.Style
+	styleDim            lipgloss.Style
+	styleToolName       lipgloss.Style
+	styleToolInput      lipgloss.Style
+	styleToolOutput     lipgloss.Style
+	styleToolErr        lipgloss.Style
+	styleUserLabel      lipgloss.Style
+	styleThinkingCard   lipgloss.Style
+	styleThinkingLabel  lipgloss.Style
+	styleHeaderCard     lipgloss.Style
+	styleAssistantCard  lipgloss.Style
+	styleToolCard       lipgloss.Style
+	stylePermCard       lipgloss.Style
+	styleDenialCard     lipgloss.Style
+	styleDiffAdd        lipgloss.Style
+	styleDiffDel        lipgloss.Style
+	styleTodoPanel      lipgloss.Style
+	stylePopupItem      lipgloss.Style
+	stylePopupSelected  lipgloss.Style
+	styleText           lipgloss.Style
+
+	// Theme (see theme.go)
+	themeName      string            // configured theme, "auto" follows the terminal background
+	activeTheme    Theme             // theme the styles are built from
+	colorOverrides map[string]string // config colors applied over the theme
+	codeStyle      *chroma.Style     // chroma style for code previews
 
 	// Layout padding
 	padH int // horizontal padding (each side)
 
+	// Configurable limits and key bindings (see config.go)
+	ui   UIConfig
+	keys KeyMap
+
 	// Collapsible card state
-	expandedCards      map[string]bool // card ID → expanded
-	cardZones          []cardZone      // rebuilt each render
-	cachedCardZoneCount int            // zones from finalized entries
-	cachedLineCount    int             // line count of cached content
+	expandedCards map[string]bool     // card ID → expanded
+	cardZones     []cardZone          // zones of the entry being rendered
+	entryRenders  map[int]entryRender // render cache by entry ID, see refreshViewport
+	lastEntryID   int
 }
 
 // NewChatModel creates a new chat tab model.
@@ -121,93 +189,36 @@ func NewChatModel() *ChatModel {
 	ta.SetHeight(3)
 	ta.CharLimit = 0
 
-	vp := viewport.New(viewport.WithWidth(80), viewport.WithHeight(20))
-	vp.SoftWrap = true
-	vp.KeyMap.Left = key.NewBinding(key.WithDisabled())
-	vp.KeyMap.Right = key.NewBinding(key.WithDisabled())
+	vp := newVirtualViewport(80, 20)
 
-	r, err := glamour.NewTermRenderer(
-		glamour.WithStandardStyle(styles.DarkStyle),
-		glamour.WithWordWrap(73),
-	)
-	if err != nil {
-		log.Printf("glamour renderer init failed: %v (markdown rendering disabled)", err)
-	}
-
-	return &ChatModel{
-		viewport: vp,
-		textarea: ta,
-		renderer: r,
-		permMode: PermAcceptEdits,
-		styleUserCard: lipgloss.NewStyle().
-			BorderLeft(true).
-			BorderStyle(lipgloss.ThickBorder()).
-			BorderForeground(lipgloss.Color("4")).
-			Background(lipgloss.Color("236")).
-			PaddingLeft(1).
-			PaddingRight(1),
-		styleErrorCard: lipgloss.NewStyle().
-			BorderLeft(true).
-			BorderStyle(lipgloss.ThickBorder()).
-			BorderForeground(lipgloss.Color("1")).
-			Background(lipgloss.Color("236")).
-			PaddingLeft(1).
-			PaddingRight(1),
-		styleToolResultCard: lipgloss.NewStyle().
-			Background(lipgloss.Color("235")).
-			PaddingLeft(1).
-			PaddingRight(1),
-		styleDim: lipgloss.NewStyle().
-			Foreground(lipgloss.Color("8")),
-		styleToolName: lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("3")),
-		styleToolInput: lipgloss.NewStyle().
-			Foreground(lipgloss.Color("6")),
-		styleToolOutput: lipgloss.NewStyle().
-			Foreground(lipgloss.Color("7")),
-		styleToolErr: lipgloss.NewStyle().
-			Foreground(lipgloss.Color("9")),
-		styleUserLabel: lipgloss.NewStyle().
-			Foreground(lipgloss.Color("4")).
-			Background(lipgloss.Color("236")).
-			Bold(true),
-		styleThinkingCard: lipgloss.NewStyle().
-			BorderLeft(true).
-			BorderStyle(lipgloss.ThickBorder()).
-			BorderForeground(lipgloss.Color("243")).
-			Background(lipgloss.Color("236")).
-			PaddingLeft(1).
-			PaddingRight(1).
-			Foreground(lipgloss.Color("243")).
-			Italic(true),
-		styleThinkingLabel: lipgloss.NewStyle().
-			Foreground(lipgloss.Color("243")).
-			Background(lipgloss.Color("236")).
-			Bold(true).
-			Italic(true),
-		styleHeaderCard: lipgloss.NewStyle().
-			Background(lipgloss.Color("236")).
-			PaddingLeft(1).
-			PaddingRight(1),
-		styleAssistantCard: lipgloss.NewStyle().
-			BorderLeft(true).
-			BorderStyle(lipgloss.ThickBorder()).
-			BorderForeground(lipgloss.Color("208")).
-			PaddingLeft(1),
-		styleToolCard: lipgloss.NewStyle().
-			BorderLeft(true).
-			BorderStyle(lipgloss.ThickBorder()).
-			BorderForeground(lipgloss.Color("3")).
-			PaddingLeft(1),
-		padH:          2,
+	di := textinput.New()
+	di.Placeholder = "Reason for denying (optional)"
+	di.Prompt = "✗ "
+
+	cwd, _ := os.Getwd()
+
+	m := &ChatModel{
+		viewport:      vp,
+		cwd:           cwd,
+		textarea:      ta,
+		historyPos:    -1,
+		permMode:      PermAcceptEdits,
+		backend:       NewPrintBackend(),
+		permAlways:    make(map[string]bool),
+		permDenyInput: di,
 		expandedCards: make(map[string]bool),
 	}
+	m.applyConfig(defaultConfig())
+	return m
 }
 
-// Init returns the initial command (focus textarea).
+// Init returns the initial command (focus textarea, first scripted prompt).
 func (m *ChatModel) Init() tea.Cmd {
-	return m.textarea.Focus()
+	cmds := []tea.Cmd{m.textarea.Focus(), m.nextScriptedPrompt(), m.detectBackground()}
+	if m.permRequests != nil {
+		cmds = append(cmds, waitForPermissionRequest(m.permRequests))
+	}
+	return tea.Batch(cmds...)
 }
 
 // Update handles messages for the chat tab.
@@ -216,8 +227,26 @@ func (m *ChatModel) Update(msg tea.Msg) tea.Cmd {
 
 	switch msg := msg.(type) {
 	case tea.KeyPressMsg:
+		// A pending permission prompt takes all keys
+		if len(m.permQueue) > 0 {
+			return m.handlePermissionKey(msg)
+		}
+		if m.historySearching {
+			return m.handleHistorySearchKey(msg)
+		}
+		if m.modelPicking {
+			return m.handleModelPickerKey(msg)
+		}
+		if !m.scrollMode {
+			if cmd, ok := m.handleSlashKey(msg); ok {
+				return cmd
+			}
+			if m.handleMentionKey(msg) {
+				return nil
+			}
+		}
 		// Scroll mode toggle
-		if msg.String() == "esc" {
+		if msg.String() == m.keys.Scroll {
 			m.scrollMode = !m.scrollMode
 			if m.scrollMode {
 				m.textarea.Blur()
@@ -238,58 +267,93 @@ func (m *ChatModel) Update(msg tea.Msg) tea.Cmd {
 			return cmd
 		}
 
-		if msg.String() == "ctrl+p" {
+		if msg.String() == m.keys.PermMode {
 			m.permMode = m.permMode.Next()
 			return nil
 		}
-		if msg.String() == "shift+enter" {
+		if msg.String() == m.keys.Interrupt {
+			m.interruptTurn()
+			return nil
+		}
+		if msg.String() == m.keys.Todos {
+			m.showTodos = !m.showTodos
+			m.SetSize(m.width, m.height)
+			return nil
+		}
+		if msg.String() == m.keys.RetryEscalate || msg.String() == m.keys.RetryAllow {
+			return m.retryDenied(msg.String() == m.keys.RetryEscalate)
+		}
+		if msg.String() == m.keys.Newline {
 			m.textarea.InsertRune('\n')
 			return nil
 		}
-		if msg.String() == "enter" {
+		if msg.String() == "up" && m.textarea.Value() == "" && m.editQueued() {
+			return nil
+		}
+		if msg.String() == "up" && m.textarea.Line() == 0 && m.historyUp() {
+			return nil
+		}
+		if msg.String() == "down" && m.textarea.Line() == m.textarea.LineCount()-1 && m.historyDown() {
+			return nil
+		}
+		if msg.String() == m.keys.Editor {
+			return m.composeInEditor()
+		}
+		if msg.String() == m.keys.HistorySearch {
+			m.startHistorySearch()
+			return nil
+		}
+		if msg.String() == m.keys.Submit || msg.String() == m.keys.Inject {
 			text := strings.TrimSpace(m.textarea.Value())
-			if text != "" && m.streamCh == nil && m.iStreamCh == nil {
+			if text != "" {
+				m.recordPrompt(text)
+			}
+			if cmd, ok := m.runSlashCommand(text); ok {
 				m.textarea.Reset()
-				m.entries = append(m.entries, chatEntry{role: "user", text: text})
-				m.refreshViewport()
-
-				if m.interactive {
-					// Interactive mode: use goexpect session
-					session := m.iSession
-					cmds = append(cmds, func() tea.Msg {
-						if session == nil {
-							s, err := StartInteractive()
-							if err != nil {
-								return InteractiveDoneMsg{Err: err}
-							}
-							return InteractiveStartMsg{Session: s}
-						}
-						ch, err := session.SendPrompt(text)
-						if err != nil {
-							return InteractiveDoneMsg{Err: err}
-						}
-						return interactiveStreamStartMsg{ch: ch}
-					})
-				} else {
-					// Print mode: spawn new process per message
-					sid := m.sessionID
-					pm := m.permMode
-					cmds = append(cmds, func() tea.Msg {
-						ch, cmd, err := StreamClaude(text, sid, pm)
-						if err != nil {
-							return ClaudeStreamDoneMsg{Prompt: text, Err: err}
-						}
-						return ClaudeStreamStartMsg{Prompt: text, Ch: ch, Cmd: cmd}
-					})
-				}
+				return cmd
+			}
+			if text != "" && m.streamCh == nil {
+				m.textarea.Reset()
+				cmds = append(cmds, m.submitPrompt(text))
+			} else if text != "" {
+				m.textarea.Reset()
+				m.queuePrompt(text, msg.String() == m.keys.Inject)
 			}
 			return tea.Batch(cmds...)
 		}
 
+	case scriptedPromptMsg:
+		return m.submitPrompt(msg.Prompt)
+
+	case tea.BackgroundColorMsg:
+		if m.themeName == autoTheme {
+			m.setTheme(themeForBackground(msg.IsDark()))
+		}
+		return nil
+
+	case editorDoneMsg:
+		m.finishEditing(msg)
+		return m.textarea.Focus()
+
+	case mentionFilesMsg:
+		m.mentionFiles = msg.Files
+		m.mentionListing = false
+		m.mentionListedAt = time.Now()
+		m.mentionQuery = "" // rank the new list
+		return nil
+
+	case PermissionRequestMsg:
+		if m.permAlways[msg.Req.ToolName] {
+			msg.Req.Respond(allowDecision(msg.Req.permissionQuery))
+		} else {
+			m.permQueue = append(m.permQueue, msg.Req)
+		}
+		return waitForPermissionRequest(m.permRequests)
+
 	case ClaudeStreamStartMsg:
 		m.streamCh = msg.Ch
-		m.streamCmd = msg.Cmd
-		m.entries = append(m.entries, chatEntry{
+		m.turnSessionID = ""
+		m.appendEntries(chatEntry{
 			role:      "assistant",
 			streaming: true,
 		})
@@ -301,14 +365,27 @@ func (m *ChatModel) Update(msg tea.Msg) tea.Cmd {
 			return nil
 		}
 		// Extract init event data for startup banner
-		if msg.Event.Type == "system" && msg.Event.Subtype == "init" && !m.initReceived {
-			m.parseInitEvent(msg.Event)
+		if msg.Event.Type == "system" && msg.Event.Subtype == "init" {
+			if !m.initReceived {
+				m.parseInitEvent(msg.Event)
+			}
+			var init struct {
+				SessionID string `json:"session_id"`
+			}
+			if json.Unmarshal([]byte(msg.Event.Raw), &init) == nil {
+				m.turnSessionID = init.SessionID
+			}
 		}
 		// Extract rate limit info
 		if msg.Event.Type == "rate_limit_event" {
 			m.parseRateLimitEvent(msg.Event)
 		}
 
+		// A result mid-stream ends the reply before an injected prompt
+		if msg.Event.Type == "result" && m.startInjectedReply(msg.Event) {
+			return waitForStreamMsg(m.streamCh)
+		}
+
 		if len(m.entries) > 0 {
 			last := &m.entries[len(m.entries)-1]
 			if last.streaming {
@@ -349,13 +426,29 @@ func (m *ChatModel) Update(msg tea.Msg) tea.Cmd {
 
 	case ClaudeStreamDoneMsg:
 		m.streamCh = nil
-		m.streamCmd = nil
-		if msg.Err != nil {
+		interrupted := m.interrupting
+		m.interrupting = false
+		// Prompts left over from a turn that ended can never be answered usefully
+		m.clearPermissionQueue()
+		if interrupted && len(m.entries) > 0 && m.entries[len(m.entries)-1].streaming {
+			// Keep what was streamed; a killed process's error is expected
+			last := &m.entries[len(m.entries)-1]
+			m.finalizeStreamingEntry(last, msg.Response)
+			last.interrupted = true
+			if msg.Response != nil && msg.Response.Result.SessionID != "" {
+				m.sessionID = msg.Response.Result.SessionID
+			} else if m.turnSessionID != "" {
+				m.sessionID = m.turnSessionID
+			}
+			if msg.Response != nil && msg.Response.HasResult() {
+				m.updateSessionStats(msg.Response)
+			}
+		} else if msg.Err != nil {
 			// Replace streaming entry with error
 			if len(m.entries) > 0 && m.entries[len(m.entries)-1].streaming {
-				m.entries[len(m.entries)-1] = chatEntry{role: "error", text: msg.Err.Error()}
+				m.entries[len(m.entries)-1] = chatEntry{id: m.newEntryID(), role: "error", text: msg.Err.Error()}
 			} else {
-				m.entries = append(m.entries, chatEntry{role: "error", text: msg.Err.Error()})
+				m.appendEntries(chatEntry{role: "error", text: msg.Err.Error()})
 			}
 		} else if msg.Response != nil {
 			if msg.Response.Result.SessionID != "" {
@@ -363,108 +456,33 @@ func (m *ChatModel) Update(msg tea.Msg) tea.Cmd {
 			}
 			// Finalize the streaming entry in place — blocks were built incrementally
 			if len(m.entries) > 0 && m.entries[len(m.entries)-1].streaming {
-				last := &m.entries[len(m.entries)-1]
-				last.streaming = false
-				last.text = last.streamText
-				last.result = msg.Response.Result
-				last.model = msg.Response.Model
-				last.stopReason = msg.Response.StopReason
-				last.hasResult = true
-				last.cacheReadTok = msg.Response.Result.Usage.CacheReadInputTokens
-				last.durationMs = msg.Response.Result.DurationMs
-				last.durationAPIMs = msg.Response.Result.DurationAPIMs
-
-				// Pretty-print tool input JSON and parse Task inputs now that streaming is done
-				for i := range last.blocks {
-					if last.blocks[i].Kind == BlockToolUse && last.blocks[i].ToolInput != "" {
-						last.blocks[i].ToolInput = prettyJSON([]byte(last.blocks[i].ToolInput))
-						if last.blocks[i].IsTask {
-							parseTaskInput(&last.blocks[i], last.blocks[i].ToolInput)
-						}
-					}
-				}
-			}
-			m.updateSessionStats(msg.Response)
-		}
-		m.refreshViewport()
-		return nil
-
-	case InteractiveStartMsg:
-		m.iSession = msg.Session
-		// Re-send the last user prompt now that session is ready
-		if len(m.entries) > 0 {
-			lastEntry := m.entries[len(m.entries)-1]
-			if lastEntry.role == "user" {
-				session := m.iSession
-				text := lastEntry.text
-				return func() tea.Msg {
-					ch, err := session.SendPrompt(text)
-					if err != nil {
-						return InteractiveDoneMsg{Err: err}
-					}
-					return interactiveStreamStartMsg{ch: ch}
-				}
+				m.finalizeStreamingEntry(&m.entries[len(m.entries)-1], msg.Response)
 			}
-		}
-		return nil
-
-	case interactiveStreamStartMsg:
-		m.iStreamCh = msg.ch
-		m.entries = append(m.entries, chatEntry{
-			role:      "assistant",
-			streaming: true,
-		})
-		m.refreshStreamingViewport()
-		return waitForInteractiveMsg(msg.ch)
-
-	case InteractiveChunkMsg:
-		if m.iStreamCh == nil {
-			return nil
-		}
-		if len(m.entries) > 0 {
-			last := &m.entries[len(m.entries)-1]
-			if last.streaming {
-				last.streamText += msg.Text
-				// Update or create a text block
-				if idx := lastBlockIndex(last.blocks, BlockText); idx >= 0 {
-					last.blocks[idx].Text += msg.Text
-				} else {
-					last.blocks = append(last.blocks, ChatBlock{Kind: BlockText, Text: msg.Text})
-				}
-				m.refreshStreamingViewport()
+			// Text-only transports (interactive) have no result event to count
+			if msg.Response.HasResult() {
+				m.updateSessionStats(msg.Response)
 			}
 		}
-		if m.iStreamCh != nil {
-			return waitForInteractiveMsg(m.iStreamCh)
-		}
-		return nil
-
-	case InteractiveDoneMsg:
-		m.iStreamCh = nil
-		if msg.Err != nil {
-			if len(m.entries) > 0 && m.entries[len(m.entries)-1].streaming {
-				m.entries[len(m.entries)-1] = chatEntry{role: "error", text: msg.Err.Error()}
-			} else {
-				m.entries = append(m.entries, chatEntry{role: "error", text: msg.Err.Error()})
-			}
-		} else if len(m.entries) > 0 && m.entries[len(m.entries)-1].streaming {
-			last := &m.entries[len(m.entries)-1]
-			last.streaming = false
-			last.text = last.streamText
+		if interrupted {
+			m.restoreQueued()
 		}
 		m.refreshViewport()
-		return nil
+		m.saveSession()
+		if cmd := m.dispatchQueued(); cmd != nil {
+			return cmd
+		}
+		return m.nextScriptedPrompt()
 
 	case ClaudeResponseMsg:
 		if msg.Err != nil {
-			m.entries = append(m.entries, chatEntry{role: "error", text: msg.Err.Error()})
+			m.appendEntries(chatEntry{role: "error", text: msg.Err.Error()})
 		} else {
 			if msg.Response.Result.SessionID != "" {
 				m.sessionID = msg.Response.Result.SessionID
 			}
 			blocks := msg.Response.ExtractBlocks()
 
-			m.entries = append(m.entries, chatEntry{
+			m.appendEntries(chatEntry{
 				role:          "assistant",
 				text:          msg.Response.AssistantText(),
 				blocks:        blocks,
@@ -475,11 +493,14 @@ func (m *ChatModel) Update(msg tea.Msg) tea.Cmd {
 				cacheReadTok:  msg.Response.Result.Usage.CacheReadInputTokens,
 				durationMs:    msg.Response.Result.DurationMs,
 				durationAPIMs: msg.Response.Result.DurationAPIMs,
+				denials:       msg.Response.Result.PermissionDenials,
+				permMode:      m.turnPermMode,
 			})
 
 			m.updateSessionStats(msg.Response)
 		}
 		m.refreshViewport()
+		m.saveSession()
 		return nil
 	}
 
@@ -491,10 +512,14 @@ func (m *ChatModel) Update(msg tea.Msg) tea.Cmd {
 			const headerLines = 2 // header card + blank line
 			vpHeight := m.viewport.Height()
 			vpY := msg.Y - headerLines
-			if vpY >= 0 && vpY < vpHeight {
-				contentLine := vpY + m.viewport.YOffset()
-				for _, zone := range m.cardZones {
-					if contentLine >= zone.startLine && contentLine <= zone.endLine {
+			if vpY >= 0 && vpY < vpHeight && msg.X < m.padH+m.viewport.Width() {
+				item, line := m.viewport.ItemAt(vpY + m.viewport.YOffset())
+				var zones []cardZone
+				if item >= 0 {
+					zones = m.viewport.Item(item).zones
+				}
+				for _, zone := range zones {
+					if line >= zone.startLine && line <= zone.endLine {
 						if m.expandedCards[zone.id] {
 							delete(m.expandedCards, zone.id)
 						} else {
@@ -516,21 +541,137 @@ func (m *ChatModel) Update(msg tea.Msg) tea.Cmd {
 		if cmd != nil {
 			cmds = append(cmds, cmd)
 		}
+		if cmd := m.refreshMentionFiles(); cmd != nil {
+			cmds = append(cmds, cmd)
+		}
 	}
 
 	return tea.Batch(cmds...)
 }
 
+// finalizeStreamingEntry ends streaming for e, whose blocks were built
+// incrementally. resp is nil when the turn ended without a response (an
+// interrupted print-mode process).
+func (m *ChatModel) finalizeStreamingEntry(e *chatEntry, resp *ClaudeResponse) {
+	e.streaming = false
+	e.text = e.streamText
+	e.permMode = m.turnPermMode
+	if resp != nil {
+		e.model = resp.Model
+		e.stopReason = resp.StopReason
+		if resp.HasResult() {
+			e.result = resp.Result
+			e.hasResult = true
+			e.cacheReadTok = resp.Result.Usage.CacheReadInputTokens
+			e.durationMs = resp.Result.DurationMs
+			e.durationAPIMs = resp.Result.DurationAPIMs
+			e.denials = resp.Result.PermissionDenials
+		}
+	}
+
+	// Pretty-print tool input JSON and parse Task inputs now that streaming is done
+	for i := range e.blocks {
+		if e.blocks[i].Kind == BlockToolUse && e.blocks[i].ToolInput != "" {
+			e.blocks[i].ToolInput = prettyJSON([]byte(e.blocks[i].ToolInput))
+			if e.blocks[i].IsTask {
+				parseTaskInput(&e.blocks[i], e.blocks[i].ToolInput)
+			}
+		}
+	}
+}
+
+// interruptTurn stops the turn in flight without quitting. Backends that
+// can interrupt claude keep their process; the others cancel the turn. The
+// streamed entry is finalized when the turn's Done message arrives.
+func (m *ChatModel) interruptTurn() {
+	if m.streamCh == nil || m.interrupting {
+		return
+	}
+	m.interrupting = true
+	if in, ok := m.backend.(interrupter); ok {
+		if err := in.Interrupt(); err != nil {
+			m.addNotice("error", "interrupt failed: "+err.Error())
+		}
+		return
+	}
+	m.backend.Cancel()
+}
+
+// newEntryID returns an ID no entry of this chat has had.
+func (m *ChatModel) newEntryID() int {
+	m.lastEntryID++
+	return m.lastEntryID
+}
+
+// appendEntries adds entries to the transcript with new IDs. Entries are
+// keyed by ID rather than index, since notices can be inserted before the
+// streaming entry.
+func (m *ChatModel) appendEntries(entries ...chatEntry) {
+	for _, e := range entries {
+		e.id = m.newEntryID()
+		m.entries = append(m.entries, e)
+	}
+}
+
+// submitPrompt records a user entry for prompt and starts a turn.
+func (m *ChatModel) submitPrompt(prompt string) tea.Cmd {
+	m.appendEntries(chatEntry{role: "user", text: prompt})
+	m.refreshViewport()
+	return m.startTurn(prompt)
+}
+
+// nextScriptedPrompt schedules the backend's next prompt, if it scripts its own.
+func (m *ChatModel) nextScriptedPrompt() tea.Cmd {
+	src, ok := m.backend.(promptSource)
+	if !ok {
+		return nil
+	}
+	prompt, delay, ok := src.NextPrompt()
+	if !ok {
+		return nil
+	}
+	return tea.Tick(delay, func(time.Time) tea.Msg {
+		return scriptedPromptMsg{Prompt: prompt}
+	})
+}
+
+// startTurn returns a command that starts a turn on the backend for prompt.
+func (m *ChatModel) startTurn(prompt string) tea.Cmd {
+	backend := m.backend
+	inline, cwd := m.inlineMentions, m.cwd
+	req := TurnRequest{
+		Prompt:        prompt,
+		SessionID:     m.sessionID,
+		PermMode:      m.permMode,
+		Model:         m.model,
+		FallbackModel: m.fallbackModel,
+		AllowedTools:  slices.Clone(m.allowedTools),
+	}
+	m.turnModel = m.model
+	m.turnPermMode = m.permMode
+	return func() tea.Msg {
+		if inline {
+			req.Prompt = inlineMentions(req.Prompt, cwd)
+		}
+		ch, err := backend.StartTurn(req)
+		if err != nil {
+			return ClaudeStreamDoneMsg{Prompt: prompt, Err: err}
+		}
+		return ClaudeStreamStartMsg{Prompt: prompt, Ch: ch}
+	}
+}
+
 // parseInitEvent extracts startup metadata from the system/init event.
 func (m *ChatModel) parseInitEvent(ev StreamEvent) {
 	var init struct {
-		Model     string   `json:"model"`
-		Version   string   `json:"claude_code_version"`
-		Tools     []string `json:"tools"`
-		PermMode  string   `json:"permissionMode"`
-		Plugins   []struct {
+		Model    string   `json:"model"`
+		Version  string   `json:"claude_code_version"`
+		Tools    []string `json:"tools"`
+		PermMode string   `json:"permissionMode"`
+		Plugins  []struct {
 			Name string `json:"name"`
 		} `json:"plugins"`
+		SlashCommands []string `json:"slash_commands"`
 	}
 	if json.Unmarshal([]byte(ev.Raw), &init) == nil {
 		m.initModel = init.Model
@@ -540,6 +681,7 @@ func (m *ChatModel) parseInitEvent(ev StreamEvent) {
 		for _, p := range init.Plugins {
 			m.initPlugins = append(m.initPlugins, p.Name)
 		}
+		m.initSlashCommands = init.SlashCommands
 		m.initReceived = true
 	}
 }
@@ -548,10 +690,10 @@ func (m *ChatModel) parseInitEvent(ev StreamEvent) {
 func (m *ChatModel) parseRateLimitEvent(ev StreamEvent) {
 	var rl struct {
 		RateLimitInfo struct {
-			Status       string `json:"status"`
-			ResetsAt     int64  `json:"resetsAt"`
-			OverageStatus string `json:"overageStatus"`
-			IsUsingOverage bool  `json:"isUsingOverage"`
+			Status         string `json:"status"`
+			ResetsAt       int64  `json:"resetsAt"`
+			OverageStatus  string `json:"overageStatus"`
+			IsUsingOverage bool   `json:"isUsingOverage"`
 		} `json:"rate_limit_info"`
 	}
 	if json.Unmarshal([]byte(ev.Raw), &rl) == nil {
@@ -562,6 +704,23 @@ func (m *ChatModel) parseRateLimitEvent(ev StreamEvent) {
 	}
 }
 
+// resetSessionStats zeroes the cumulative and per-request stats for a new
+// conversation.
+func (m *ChatModel) resetSessionStats() {
+	m.totalRequests = 0
+	m.totalCost = 0
+	m.totalInputTok = 0
+	m.totalOutputTok = 0
+	m.lastModel = ""
+	m.lastRequestedModel = ""
+	m.lastCost = 0
+	m.lastInputTok = 0
+	m.lastCacheRead = 0
+	m.lastCacheCreation = 0
+	m.lastDurationMs = 0
+	m.lastAPIMs = 0
+}
+
 // updateSessionStats updates cumulative and per-request stats from a response.
 func (m *ChatModel) updateSessionStats(resp *ClaudeResponse) {
 	m.totalRequests++
@@ -569,6 +728,7 @@ func (m *ChatModel) updateSessionStats(resp *ClaudeResponse) {
 	m.totalInputTok += resp.Result.Usage.InputTokens
 	m.totalOutputTok += resp.Result.Usage.OutputTokens
 	m.lastModel = resp.Model
+	m.lastRequestedModel = m.turnModel
 	m.lastCost = resp.Result.CostUSD
 	m.lastInputTok = resp.Result.Usage.InputTokens
 	m.lastCacheRead = resp.Result.Usage.CacheReadInputTokens
@@ -590,17 +750,14 @@ func (m *ChatModel) SetSize(w, h int) {
 	// 1 header + 1 blank line below header + 1 divider + 1 status line
 	viewportHeight := h - textareaHeight - 4
 
-	m.viewport.SetWidth(innerW)
+	m.viewport.SetWidth(m.transcriptWidth())
 	m.viewport.SetHeight(viewportHeight)
 	m.textarea.SetWidth(innerW)
 	m.textarea.SetHeight(textareaHeight)
 
-	r, err := glamour.NewTermRenderer(
-		glamour.WithStandardStyle(styles.DarkStyle),
-		glamour.WithWordWrap(innerW-7),
-	)
-	if err == nil {
+	if r := m.newMarkdownRenderer(); r != nil {
 		m.renderer = r
+		m.markdownCache = nil
 	}
 	m.refreshViewport()
 }
@@ -624,7 +781,7 @@ func (m *ChatModel) View() string {
 			Foreground(lipgloss.Color("0")).
 			Background(lipgloss.Color("3"))
 		pct := int(m.viewport.ScrollPercent() * 100)
-		label := fmt.Sprintf(" SCROLL (esc to exit) %d%% ", pct)
+		label := fmt.Sprintf(" SCROLL (%s to exit) %d%% ", m.keys.Scroll, pct)
 		labelW := lipgloss.Width(label)
 		lineW := innerW - labelW
 		if lineW < 0 {
@@ -636,7 +793,7 @@ func (m *ChatModel) View() string {
 	} else {
 		hint := lipgloss.NewStyle().
 			Foreground(lipgloss.Color("240")).
-			Render(fmt.Sprintf(" ctrl+p: %s ", m.permMode.Short()))
+			Render(m.dividerHint())
 		hintW := lipgloss.Width(hint)
 		lineW := innerW - hintW
 		if lineW < 0 {
@@ -647,10 +804,31 @@ func (m *ChatModel) View() string {
 			Render(strings.Repeat("─", lineW)) + hint
 	}
 
+	// The todo panel is pinned to the right of the viewport
+	vpView := m.viewport.View()
+	if w := m.todoPanelWidth(innerW); w > 0 {
+		vpView = lipgloss.JoinHorizontal(lipgloss.Top,
+			lipgloss.NewStyle().Width(m.transcriptWidth()+1).Render(vpView),
+			m.renderTodoPanel(w, m.viewport.Height()))
+	}
+
+	// Permission prompts and the slash command popup overlay the bottom of the viewport
+	if len(m.permQueue) > 0 {
+		vpView = overlayBottom(vpView, m.renderPermissionModal(innerW))
+	} else if m.historySearching {
+		vpView = overlayBottom(vpView, m.renderHistorySearch(innerW))
+	} else if m.modelPicking {
+		vpView = overlayBottom(vpView, m.renderModelPicker(innerW))
+	} else if !m.scrollMode && len(m.slashMatches()) > 0 {
+		vpView = overlayBottom(vpView, m.renderSlashPopup(innerW))
+	} else if !m.scrollMode && len(m.mentionPopupMatches()) > 0 {
+		vpView = overlayBottom(vpView, m.renderMentionPopup(innerW))
+	}
+
 	// Indent every line with horizontal padding
 	body := fmt.Sprintf("%s\n\n%s\n%s\n%s\n%s",
 		header,
-		m.viewport.View(),
+		vpView,
 		m.renderStatusLine(),
 		divider,
 		m.textarea.View(),
@@ -666,3 +844,123 @@ func (m *ChatModel) View() string {
 	}
 	return sb.String()
 }
+
+// dividerHint lists the mode keys shown at the right of the divider.
+func (m *ChatModel) dividerHint() string {
+	hint := fmt.Sprintf(" %s: %s ", m.keys.PermMode, m.permMode.Short())
+	if m.interrupting {
+		hint = " interrupting… ·" + hint
+	} else if m.streamCh != nil {
+		hint = " " + m.keys.Interrupt + ": interrupt ·" + hint
+	}
+	if len(m.todos) > 0 && !m.showTodos {
+		hint = " " + m.keys.Todos + ": todos ·" + hint
+	}
+	return hint
+}
+
+// handlePermissionKey handles keys while a permission prompt is shown:
+// a/y allow once, A allow always for this tool, d/n deny with a message.
+func (m *ChatModel) handlePermissionKey(msg tea.KeyPressMsg) tea.Cmd {
+	req := m.permQueue[0]
+
+	if m.permDenying {
+		switch msg.String() {
+		case "enter":
+			req.Respond(denyDecision(strings.TrimSpace(m.permDenyInput.Value())))
+			m.popPermission()
+			return nil
+		case "esc":
+			m.permDenying = false
+			m.permDenyInput.Blur()
+			return nil
+		}
+		var cmd tea.Cmd
+		m.permDenyInput, cmd = m.permDenyInput.Update(msg)
+		return cmd
+	}
+
+	switch msg.String() {
+	case "a", "y":
+		req.Respond(allowDecision(req.permissionQuery))
+		m.popPermission()
+	case "A":
+		m.permAlways[req.ToolName] = true
+		req.Respond(allowDecision(req.permissionQuery))
+		m.popPermission()
+		// Anything else queued for the same tool is now allowed too
+		for len(m.permQueue) > 0 && m.permAlways[m.permQueue[0].ToolName] {
+			m.permQueue[0].Respond(allowDecision(m.permQueue[0].permissionQuery))
+			m.popPermission()
+		}
+	case "d", "n":
+		m.permDenying = true
+		m.permDenyInput.Reset()
+		return m.permDenyInput.Focus()
+	}
+	return nil
+}
+
+// lastTurnEntry returns the index of the last entry that isn't a notice,
+// or -1 if there is none.
+func (m *ChatModel) lastTurnEntry() int {
+	for i := len(m.entries) - 1; i >= 0; i-- {
+		if !m.entries[i].transient {
+			return i
+		}
+	}
+	return -1
+}
+
+// retryDenied re-runs the last prompt after its turn had tool calls denied,
+// either with a more permissive mode (escalate) or with allow rules for the
+// denied tools. It does nothing unless the last turn has denials; notices
+// after it don't count.
+func (m *ChatModel) retryDenied(escalate bool) tea.Cmd {
+	n := m.lastTurnEntry()
+	if m.streamCh != nil || n < 1 {
+		return nil
+	}
+	last := m.entries[n]
+	if last.role != "assistant" || len(last.denials) == 0 {
+		return nil
+	}
+	var prompt string
+	for i := n - 1; i >= 0; i-- {
+		if m.entries[i].role == "user" {
+			prompt = m.entries[i].text
+			break
+		}
+	}
+	if prompt == "" {
+		return nil
+	}
+
+	if escalate {
+		m.permMode = m.permMode.Escalate()
+	} else {
+		for _, name := range deniedToolNames(last.denials) {
+			if !slices.Contains(m.allowedTools, name) {
+				m.allowedTools = append(m.allowedTools, name)
+			}
+		}
+	}
+	return m.submitPrompt(prompt)
+}
+
+// popPermission removes the answered prompt at the head of the queue.
+func (m *ChatModel) popPermission() {
+	m.permQueue = m.permQueue[1:]
+	m.permDenying = false
+	m.permDenyInput.Blur()
+}
+
+// clearPermissionQueue denies and drops all pending prompts.
+func (m *ChatModel) clearPermissionQueue() {
+	for _, req := range m.permQueue {
+		req.Respond(denyDecision("The turn ended before the user answered."))
+	}
+	m.permQueue = nil
+	m.permDenying = false
+	m.permDenyInput.Blur()
+}
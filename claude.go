@@ -33,16 +33,26 @@ type TokenUsage struct {
 
 // ClaudeResult is the final "result" event from claude.
 type ClaudeResult struct {
-	Type           string     `json:"type"`
-	Subtype        string     `json:"subtype"`
-	Result         string     `json:"result"`
-	IsError        bool       `json:"is_error"`
-	DurationMs     int        `json:"duration_ms"`
-	DurationAPIMs  int        `json:"duration_api_ms"`
-	NumTurns       int        `json:"num_turns"`
-	CostUSD        float64    `json:"total_cost_usd"`
-	SessionID      string     `json:"session_id"`
-	Usage          TokenUsage `json:"usage"`
+	Type          string     `json:"type"`
+	Subtype       string     `json:"subtype"`
+	Result        string     `json:"result"`
+	IsError       bool       `json:"is_error"`
+	DurationMs    int        `json:"duration_ms"`
+	DurationAPIMs int        `json:"duration_api_ms"`
+	NumTurns      int        `json:"num_turns"`
+	CostUSD       float64    `json:"total_cost_usd"`
+	SessionID     string     `json:"session_id"`
+	Usage         TokenUsage `json:"usage"`
+
+	PermissionDenials []PermissionDenial `json:"permission_denials"`
+}
+
+// PermissionDenial is a tool call the CLI refused during the turn, as listed
+// in the result event.
+type PermissionDenial struct {
+	ToolName  string          `json:"tool_name"`
+	ToolUseID string          `json:"tool_use_id"`
+	ToolInput json.RawMessage `json:"tool_input"`
 }
 
 // ClaudeResponse holds everything from a single claude invocation.
@@ -106,6 +116,15 @@ func (p PermissionMode) Next() PermissionMode {
 	return permModes[0]
 }
 
+// Escalate returns the next more permissive mode, used to re-run a turn
+// after tool calls were denied: plan allows edits, anything else bypasses.
+func (p PermissionMode) Escalate() PermissionMode {
+	if p == PermPlan {
+		return PermAcceptEdits
+	}
+	return PermBypassPermissions
+}
+
 // Short returns a compact display name for the permission mode.
 func (p PermissionMode) Short() string {
 	switch p {
@@ -408,6 +427,12 @@ func (r *ClaudeResponse) ExtractBlocks() []ChatBlock {
 	return blocks
 }
 
+// HasResult reports whether the response ended with a result event.
+// Text-only transports (interactive mode) never produce one.
+func (r *ClaudeResponse) HasResult() bool {
+	return r.Result.Type == "result"
+}
+
 // AssistantText extracts the text content from assistant events.
 func (r *ClaudeResponse) AssistantText() string {
 	// Prefer result.result if present
@@ -485,6 +510,9 @@ func extractDeltas(raw string) streamDeltas {
 	return streamDeltas{}
 }
 
+// claudeBin is the claude executable to run (overridable with -claude-bin, and by tests).
+var claudeBin = "claude"
+
 // wireLogConfig holds wire logging state, safe for concurrent access.
 var wireLog struct {
 	enabled atomic.Bool
@@ -502,19 +530,76 @@ func WireLogPath() string {
 	return wireLog.path
 }
 
+// openWireLog opens the wire log for appending, or returns nil if logging is
+// disabled or the file can't be opened. The path is fixed on first use so all
+// requests in one app session land in the same file.
+func openWireLog(startedAt time.Time) *os.File {
+	if !wireLog.enabled.Load() {
+		return nil
+	}
+	wireLog.once.Do(func() {
+		wireLog.path = fmt.Sprintf("/tmp/flawdcode-%s.jsonl", startedAt.Format("20060102-150405"))
+	})
+	wl, err := os.OpenFile(wireLog.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil // non-fatal, just skip logging
+	}
+	return wl
+}
+
+// writeWireEnvelope appends a synthetic {"_wire": kind, "_ts": ...} line to the wire log.
+func writeWireEnvelope(wl *os.File, kind string, fields map[string]any) {
+	if wl == nil {
+		return
+	}
+	env := map[string]any{
+		"_wire": kind,
+		"_ts":   time.Now().Format(time.RFC3339Nano),
+	}
+	for k, v := range fields {
+		env[k] = v
+	}
+	line, _ := json.Marshal(env)
+	fmt.Fprintf(wl, "%s\n", line)
+}
+
 // buildClaudeCmd constructs the exec.Cmd for a claude invocation with args and filtered env.
-func buildClaudeCmd(prompt, sessionID string, permMode PermissionMode) *exec.Cmd {
+func buildClaudeCmd(req TurnRequest) *exec.Cmd {
 	args := []string{"-p", "--output-format", "stream-json", "--verbose", "--include-partial-messages"}
-	if permMode != "" {
-		args = append(args, "--permission-mode", string(permMode))
+	args = append(args, turnArgs(req)...)
+	args = append(args, req.Prompt)
+	cmd := exec.Command(claudeBin, args...)
+	cmd.Env = claudeEnv()
+	return cmd
+}
+
+// turnArgs returns the claude CLI args for the options in req, shared by
+// the print and persistent transports. The prompt itself is not included.
+func turnArgs(req TurnRequest) []string {
+	var args []string
+	if req.PermMode != "" {
+		args = append(args, "--permission-mode", string(req.PermMode))
+	}
+	if req.SessionID != "" {
+		args = append(args, "--resume", req.SessionID)
+	}
+	if req.Model != "" {
+		args = append(args, "--model", req.Model)
+	}
+	// claude refuses a fallback that is the main model
+	if req.FallbackModel != "" && req.FallbackModel != req.Model {
+		args = append(args, "--fallback-model", req.FallbackModel)
 	}
-	if sessionID != "" {
-		args = append(args, "--resume", sessionID)
+	if len(req.AllowedTools) > 0 {
+		// --allowedTools is variadic; the = form keeps it from swallowing the prompt.
+		args = append(args, "--allowedTools="+strings.Join(req.AllowedTools, ","))
 	}
-	args = append(args, prompt)
-	cmd := exec.Command("claude", args...)
+	return append(args, permissionPromptArgs()...)
+}
 
-	// Filter out CLAUDECODE env var
+// claudeEnv returns the current environment without CLAUDECODE, so a nested
+// claude process doesn't think it is running inside another session.
+func claudeEnv() []string {
 	env := os.Environ()
 	filtered := make([]string, 0, len(env))
 	for _, e := range env {
@@ -522,9 +607,7 @@ func buildClaudeCmd(prompt, sessionID string, permMode PermissionMode) *exec.Cmd
 			filtered = append(filtered, e)
 		}
 	}
-	cmd.Env = filtered
-
-	return cmd
+	return filtered
 }
 
 // parseEventLine parses one NDJSON line, updating result/model/stopReason as needed.
@@ -565,8 +648,8 @@ func parseEventLine(line string, result *ClaudeResult, model *string, stopReason
 
 // StreamClaude spawns claude in print mode and returns a channel that emits
 // events incrementally. The channel is closed after the final StreamMsg{Done: true}.
-func StreamClaude(prompt, sessionID string, permMode PermissionMode) (<-chan StreamMsg, *exec.Cmd, error) {
-	cmd := buildClaudeCmd(prompt, sessionID, permMode)
+func StreamClaude(req TurnRequest) (<-chan StreamMsg, *exec.Cmd, error) {
+	cmd := buildClaudeCmd(req)
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -582,17 +665,7 @@ func StreamClaude(prompt, sessionID string, permMode PermissionMode) (<-chan Str
 	}
 
 	// Open wire log file (one per app session, append across requests)
-	var wl *os.File
-	if wireLog.enabled.Load() {
-		wireLog.once.Do(func() {
-			wireLog.path = fmt.Sprintf("/tmp/flawdcode-%s.jsonl", startedAt.Format("20060102-150405"))
-		})
-		var wireLogErr error
-		wl, wireLogErr = os.OpenFile(wireLog.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
-		if wireLogErr != nil {
-			wl = nil // non-fatal, just skip logging
-		}
-	}
+	wl := openWireLog(startedAt)
 
 	ch := make(chan StreamMsg, 64)
 
@@ -601,14 +674,12 @@ func StreamClaude(prompt, sessionID string, permMode PermissionMode) (<-chan Str
 		if wl != nil {
 			defer wl.Close()
 			// Log the outbound prompt as a synthetic event
-			header, _ := json.Marshal(map[string]any{
-				"_wire":      "request",
+			writeWireEnvelope(wl, "request", map[string]any{
 				"_ts":        startedAt.Format(time.RFC3339Nano),
-				"prompt":     prompt,
-				"session_id": sessionID,
+				"prompt":     req.Prompt,
+				"session_id": req.SessionID,
 				"command":    cmd.Args,
 			})
-			fmt.Fprintf(wl, "%s\n", header)
 		}
 
 		var events []StreamEvent
@@ -633,13 +704,8 @@ func StreamClaude(prompt, sessionID string, permMode PermissionMode) (<-chan Str
 			if ev == nil {
 				continue
 			}
-			if resultErr != nil && wl != nil {
-				errJSON, _ := json.Marshal(map[string]any{
-					"_wire": "error",
-					"_ts":   time.Now().Format(time.RFC3339Nano),
-					"error": resultErr.Error(),
-				})
-				fmt.Fprintf(wl, "%s\n", errJSON)
+			if resultErr != nil {
+				writeWireEnvelope(wl, "error", map[string]any{"error": resultErr.Error()})
 			}
 			events = append(events, *ev)
 
@@ -647,22 +713,17 @@ func StreamClaude(prompt, sessionID string, permMode PermissionMode) (<-chan Str
 		}
 
 		if scanErr := scanner.Err(); scanErr != nil {
-			if wl != nil {
-				errJSON, _ := json.Marshal(map[string]any{
-					"_wire":  "error",
-					"_ts":    time.Now().Format(time.RFC3339Nano),
-					"error":  scanErr.Error(),
-					"source": "scanner",
-				})
-				fmt.Fprintf(wl, "%s\n", errJSON)
-			}
+			writeWireEnvelope(wl, "error", map[string]any{
+				"error":  scanErr.Error(),
+				"source": "scanner",
+			})
 		}
 
 		waitErr := cmd.Wait()
 
 		resp := &ClaudeResponse{
 			Command:    cmd.Args,
-			Prompt:     prompt,
+			Prompt:     req.Prompt,
 			Events:     events,
 			Result:     result,
 			Stderr:     stderr.String(),
@@ -672,17 +733,12 @@ func StreamClaude(prompt, sessionID string, permMode PermissionMode) (<-chan Str
 		}
 
 		// Log completion to wire log
-		if wl != nil {
-			trailer, _ := json.Marshal(map[string]any{
-				"_wire":    "done",
-				"_ts":      time.Now().Format(time.RFC3339Nano),
-				"exit_err": fmt.Sprintf("%v", waitErr),
-				"stderr":   stderr.String(),
-				"model":    model,
-				"stop":     stopReason,
-			})
-			fmt.Fprintf(wl, "%s\n", trailer)
-		}
+		writeWireEnvelope(wl, "done", map[string]any{
+			"exit_err": fmt.Sprintf("%v", waitErr),
+			"stderr":   stderr.String(),
+			"model":    model,
+			"stop":     stopReason,
+		})
 
 		if waitErr != nil {
 			ch <- StreamMsg{
@@ -717,4 +773,3 @@ func waitForStreamMsg(ch <-chan StreamMsg) tea.Cmd {
 		}
 	}
 }
-
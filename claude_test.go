@@ -385,9 +385,9 @@ func TestParseEventLine(t *testing.T) {
 		wantErr    bool
 	}{
 		{
-			name:     "result event",
-			line:     `{"type":"result","subtype":"success","result":"Done","duration_ms":1234,"total_cost_usd":0.05,"session_id":"sess-1","usage":{"input_tokens":100,"output_tokens":50}}`,
-			wantType: "result",
+			name:       "result event",
+			line:       `{"type":"result","subtype":"success","result":"Done","duration_ms":1234,"total_cost_usd":0.05,"session_id":"sess-1","usage":{"input_tokens":100,"output_tokens":50}}`,
+			wantType:   "result",
 			wantResult: true,
 		},
 		{
@@ -423,10 +423,10 @@ func TestParseEventLine(t *testing.T) {
 			wantType: "stream_event",
 		},
 		{
-			name:    "result with bad JSON body",
-			line:    `{"type":"result","usage":"bad"}`,
+			name:     "result with bad JSON body",
+			line:     `{"type":"result","usage":"bad"}`,
 			wantType: "result",
-			wantErr: true,
+			wantErr:  true,
 		},
 	}
 
@@ -628,9 +628,28 @@ func TestPermissionModeShort(t *testing.T) {
 	}
 }
 
+func TestPermissionModeEscalate(t *testing.T) {
+	tests := []struct {
+		mode PermissionMode
+		want PermissionMode
+	}{
+		{PermPlan, PermAcceptEdits},
+		{PermAcceptEdits, PermBypassPermissions},
+		{PermDontAsk, PermBypassPermissions},
+		{PermBypassPermissions, PermBypassPermissions},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			if got := tt.mode.Escalate(); got != tt.want {
+				t.Errorf("Escalate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestBuildClaudeCmdPermissionMode(t *testing.T) {
 	t.Run("includes permission mode", func(t *testing.T) {
-		cmd := buildClaudeCmd("hello", "", PermPlan)
+		cmd := buildClaudeCmd(TurnRequest{Prompt: "hello", PermMode: PermPlan})
 		args := cmd.Args[1:] // skip "claude" binary
 		idx := slices.Index(args, "--permission-mode")
 		if idx < 0 || idx+1 >= len(args) {
@@ -642,7 +661,7 @@ func TestBuildClaudeCmdPermissionMode(t *testing.T) {
 	})
 
 	t.Run("omits when empty", func(t *testing.T) {
-		cmd := buildClaudeCmd("hello", "", "")
+		cmd := buildClaudeCmd(TurnRequest{Prompt: "hello"})
 		args := cmd.Args[1:]
 		if slices.Contains(args, "--permission-mode") {
 			t.Errorf("--permission-mode should not be present for empty mode, args: %v", args)
@@ -650,7 +669,7 @@ func TestBuildClaudeCmdPermissionMode(t *testing.T) {
 	})
 
 	t.Run("includes session ID", func(t *testing.T) {
-		cmd := buildClaudeCmd("hello", "sess-123", PermAcceptEdits)
+		cmd := buildClaudeCmd(TurnRequest{Prompt: "hello", SessionID: "sess-123", PermMode: PermAcceptEdits})
 		args := cmd.Args[1:]
 		if !slices.Contains(args, "--resume") {
 			t.Fatalf("--resume not found in args: %v", args)
@@ -665,6 +684,39 @@ func TestBuildClaudeCmdPermissionMode(t *testing.T) {
 			t.Errorf("permission mode not correct in args: %v", args)
 		}
 	})
+
+	t.Run("includes allowed tools before the prompt", func(t *testing.T) {
+		cmd := buildClaudeCmd(TurnRequest{Prompt: "hello", AllowedTools: []string{"Write", "Bash(git:*)"}})
+		args := cmd.Args[1:]
+		if !slices.Contains(args, "--allowedTools=Write,Bash(git:*)") {
+			t.Errorf("allowed tools missing: %v", args)
+		}
+		if args[len(args)-1] != "hello" {
+			t.Errorf("prompt should be the last arg: %v", args)
+		}
+	})
+
+	t.Run("includes model", func(t *testing.T) {
+		cmd := buildClaudeCmd(TurnRequest{Prompt: "hello", Model: "opus"})
+		args := cmd.Args[1:]
+		idx := slices.Index(args, "--model")
+		if idx < 0 || args[idx+1] != "opus" {
+			t.Errorf("--model opus not found in args: %v", args)
+		}
+	})
+
+	t.Run("includes fallback model", func(t *testing.T) {
+		cmd := buildClaudeCmd(TurnRequest{Prompt: "hello", Model: "opus", FallbackModel: "sonnet"})
+		args := cmd.Args[1:]
+		idx := slices.Index(args, "--fallback-model")
+		if idx < 0 || args[idx+1] != "sonnet" {
+			t.Errorf("--fallback-model sonnet not found in args: %v", args)
+		}
+		cmd = buildClaudeCmd(TurnRequest{Prompt: "hello", Model: "sonnet", FallbackModel: "sonnet"})
+		if slices.Contains(cmd.Args, "--fallback-model") {
+			t.Errorf("fallback equal to the model should be dropped: %v", cmd.Args)
+		}
+	})
 }
 
 func TestPrettyJSON(t *testing.T) {
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Defaults can be set in a JSON config file: a global one in
+// $XDG_CONFIG_HOME/flawdcode/config.json (~/.config/flawdcode when unset) and
+// a per-project .flawdcode.json in the working directory, which overrides
+// it key by key. Command-line flags override both. A project file comes with
+// the repository, so its args are limited to projectConfigArgs: it can't pick
+// the claude binary or loosen permissions. For example:
+//
+//	{
+//	  "args": {"perm-mode": "plan", "model": "sonnet"},
+//	  "ui": {"tool_result_lines": 30},
+//	  "theme": "light",
+//	  "colors": {"assistant": "#ff8700"},
+//	  "keys": {"interrupt": "ctrl+k"}
+//	}
+
+const projectConfigName = ".flawdcode.json"
+
+// projectConfigArgs are the only args a project config may set.
+var projectConfigArgs = []string{"model"}
+
+// Config is the effective configuration.
+type Config struct {
+	// Args sets command-line flags by name, e.g. "perm-mode": "plan".
+	Args map[string]any `json:"args"`
+	UI   UIConfig       `json:"ui"`
+	// Theme is "auto" or one of themes; Colors override its palette by name.
+	Theme  string            `json:"theme"`
+	Colors map[string]string `json:"colors"`
+	Keys   KeyMap            `json:"keys"`
+}
+
+// UIConfig holds layout limits.
+type UIConfig struct {
+	MaxCollapsedLines int `json:"max_collapsed_lines"` // card lines shown before collapsing
+	ToolResultLines   int `json:"tool_result_lines"`   // output lines shown in tool result cards
+	Padding           int `json:"padding"`             // horizontal padding on each side
+}
+
+// KeyMap binds the chat's actions to keys, in bubbletea's key notation
+// ("ctrl+p", "alt+enter", "esc"). Keys the pickers and popups handle
+// themselves (see reservedKeys) and single characters, which are typed into
+// the input and answer permission prompts (y/n/a), can't be bound.
+type KeyMap struct {
+	Submit        string `json:"submit"`
+	Inject        string `json:"inject"` // send while a turn runs (see queue.go)
+	Newline       string `json:"newline"`
+	Scroll        string `json:"scroll"` // toggle scroll mode
+	PermMode      string `json:"perm_mode"`
+	Interrupt     string `json:"interrupt"`
+	Todos         string `json:"todos"`
+	Editor        string `json:"editor"`
+	HistorySearch string `json:"history_search"`
+	RetryEscalate string `json:"retry_escalate"` // retry a denied turn in a more permissive mode
+	RetryAllow    string `json:"retry_allow"`    // retry a denied turn allowing the denied tools
+	Quit          string `json:"quit"`           // ctrl+c always quits too
+}
+
+// bindings returns the key map's keys by their config name.
+func (k *KeyMap) bindings() map[string]*string {
+	return map[string]*string{
+		"submit":         &k.Submit,
+		"inject":         &k.Inject,
+		"newline":        &k.Newline,
+		"scroll":         &k.Scroll,
+		"perm_mode":      &k.PermMode,
+		"interrupt":      &k.Interrupt,
+		"todos":          &k.Todos,
+		"editor":         &k.Editor,
+		"history_search": &k.HistorySearch,
+		"retry_escalate": &k.RetryEscalate,
+		"retry_allow":    &k.RetryAllow,
+		"quit":           &k.Quit,
+	}
+}
+
+// reservedKeys are handled in code by the pickers and popups (↑/↓ select,
+// tab completes, enter picks, esc closes), mapped to the one action each may
+// still be bound to, since the popups are built around it.
+var reservedKeys = map[string]string{
+	"up":        "",
+	"down":      "",
+	"tab":       "",
+	"shift+tab": "",
+	"enter":     "submit",
+	"esc":       "scroll",
+}
+
+// defaultConfig returns the built-in defaults.
+func defaultConfig() Config {
+	return Config{
+		Args: map[string]any{},
+		UI: UIConfig{
+			MaxCollapsedLines: 5,
+			ToolResultLines:   15,
+			Padding:           2,
+		},
+		Theme:  autoTheme,
+		Colors: map[string]string{},
+		Keys: KeyMap{
+			Submit:        "enter",
+			Inject:        "alt+enter",
+			Newline:       "shift+enter",
+			Scroll:        "esc",
+			PermMode:      "ctrl+p",
+			Interrupt:     "ctrl+x",
+			Todos:         "ctrl+t",
+			Editor:        "ctrl+g",
+			HistorySearch: "ctrl+r",
+			RetryEscalate: "alt+r",
+			RetryAllow:    "alt+a",
+			Quit:          "ctrl+q",
+		},
+	}
+}
+
+// configDir returns flawdcode's XDG config directory.
+func configDir() (string, error) {
+	if d := os.Getenv("XDG_CONFIG_HOME"); d != "" {
+		return filepath.Join(d, "flawdcode"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("config dir: %w", err)
+	}
+	return filepath.Join(home, ".config", "flawdcode"), nil
+}
+
+// configFiles returns the config files read for cwd, global first.
+func configFiles(cwd string) []string {
+	var files []string
+	if dir, err := configDir(); err == nil {
+		files = append(files, filepath.Join(dir, "config.json"))
+	}
+	return append(files, filepath.Join(cwd, projectConfigName))
+}
+
+// LoadConfig reads the config files for cwd over the defaults. Missing files
+// are skipped; unknown keys and invalid values are errors.
+func LoadConfig(cwd string) (Config, error) {
+	cfg := defaultConfig()
+	for _, path := range configFiles(cwd) {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return cfg, fmt.Errorf("config: %w", err)
+		}
+		if filepath.Base(path) == projectConfigName {
+			if err := checkProjectArgs(data); err != nil {
+				return cfg, fmt.Errorf("config %s: %w", path, err)
+			}
+		}
+		if err := decodeConfig(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("config %s: %w", path, err)
+		}
+		if err := cfg.validate(); err != nil {
+			return cfg, fmt.Errorf("config %s: %w", path, err)
+		}
+	}
+	return cfg, nil
+}
+
+// decodeConfig decodes data over cfg, so only the keys present change.
+func decodeConfig(data []byte, cfg *Config) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("unexpected data after the config object")
+	}
+	return nil
+}
+
+// checkProjectArgs reports the args in a project config that aren't in
+// projectConfigArgs.
+func checkProjectArgs(data []byte) error {
+	var project struct {
+		Args map[string]any `json:"args"`
+	}
+	if json.Unmarshal(data, &project) != nil {
+		return nil // decodeConfig reports it
+	}
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(project.Args)) {
+		if !slices.Contains(projectConfigArgs, name) {
+			errs = append(errs, fmt.Errorf("args: %q can only be set in the global config or on the command line", name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// configFlagsExcluded are flags that make no sense as defaults.
+var configFlagsExcluded = []string{"permission-mcp", "print-config", "export", "p"}
+
+// validate reports every invalid value in cfg. Args are checked when they
+// are applied to the flags.
+func (cfg *Config) validate() error {
+	var errs []error
+	if cfg.UI.MaxCollapsedLines < 2 {
+		errs = append(errs, fmt.Errorf("ui: max_collapsed_lines must be at least 2, got %d", cfg.UI.MaxCollapsedLines))
+	}
+	if cfg.UI.ToolResultLines < 1 {
+		errs = append(errs, fmt.Errorf("ui: tool_result_lines must be at least 1, got %d", cfg.UI.ToolResultLines))
+	}
+	if cfg.UI.Padding < 0 || cfg.UI.Padding > 20 {
+		errs = append(errs, fmt.Errorf("ui: padding must be between 0 and 20, got %d", cfg.UI.Padding))
+	}
+	if !slices.Contains(themeNames(), cfg.Theme) {
+		errs = append(errs, fmt.Errorf("theme: %q is not one of %s", cfg.Theme, strings.Join(themeNames(), ", ")))
+	}
+	var palette Palette
+	known := palette.colors()
+	for _, name := range slices.Sorted(maps.Keys(cfg.Colors)) {
+		if known[name] == nil {
+			errs = append(errs, fmt.Errorf("colors: unknown color %q", name))
+		} else if err := validColor(cfg.Colors[name]); err != nil {
+			errs = append(errs, fmt.Errorf("colors: %s: %w", name, err))
+		}
+	}
+	bound := make(map[string]string)
+	keys := cfg.Keys.bindings()
+	for _, name := range slices.Sorted(maps.Keys(keys)) {
+		key := *keys[name]
+		action, reserved := reservedKeys[key]
+		switch {
+		case key == "":
+			errs = append(errs, fmt.Errorf("keys: %s has no key", name))
+		case utf8.RuneCountInString(key) == 1:
+			errs = append(errs, fmt.Errorf("keys: %s: %q would be typed into the input", name, key))
+		case reserved && action != name:
+			errs = append(errs, fmt.Errorf("keys: %s: %q is reserved for the pickers and popups", name, key))
+		case bound[key] != "":
+			errs = append(errs, fmt.Errorf("keys: %s and %s are both bound to %q", bound[key], name, key))
+		default:
+			bound[key] = name
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ApplyArgs sets the flags of fs named in cfg.Args that weren't given on
+// the command line. Call it after fs.Parse.
+func (cfg *Config) ApplyArgs(fs *flag.FlagSet) error {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(cfg.Args)) {
+		switch {
+		case fs.Lookup(name) == nil || slices.Contains(configFlagsExcluded, name):
+			errs = append(errs, fmt.Errorf("config args: unknown flag %q", name))
+		case given[name]:
+		default:
+			if err := fs.Set(name, fmt.Sprint(cfg.Args[name])); err != nil {
+				errs = append(errs, fmt.Errorf("config args: %s=%v: %w", name, cfg.Args[name], err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// PrintConfig writes the effective config as JSON, with args holding every
+// flag's value after the config files and the command line.
+func (cfg Config) PrintConfig(w io.Writer, fs *flag.FlagSet) error {
+	cfg.Args = make(map[string]any)
+	fs.VisitAll(func(f *flag.Flag) {
+		if slices.Contains(configFlagsExcluded, f.Name) {
+			return
+		}
+		if g, ok := f.Value.(flag.Getter); ok {
+			cfg.Args[f.Name] = g.Get()
+		} else {
+			cfg.Args[f.Name] = f.Value.String()
+		}
+	})
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// applyConfig applies the UI settings of cfg to the chat.
+func (m *ChatModel) applyConfig(cfg Config) {
+	m.ui = cfg.UI
+	m.padH = cfg.UI.Padding
+	m.keys = cfg.Keys
+	m.themeName = cfg.Theme
+	m.colorOverrides = cfg.Colors
+	t, ok := themeByName(cfg.Theme)
+	if !ok {
+		t = themeForBackground(true) // until the terminal answers
+	}
+	m.setTheme(t)
+}
+
+// validPermMode reports whether name is a permission mode.
+func validPermMode(name string) bool {
+	return slices.Contains(permModes, PermissionMode(name))
+}
+
+// permModeNames lists the permission modes for error messages.
+func permModeNames() string {
+	var names []string
+	for _, p := range permModes {
+		names = append(names, string(p))
+	}
+	return strings.Join(names, ", ")
+}
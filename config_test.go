@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "charm.land/bubbletea/v2"
+)
+
+// writeConfigs writes a global and a project config (skipped when empty)
+// and returns the project directory.
+func writeConfigs(t *testing.T, global, project string) string {
+	t.Helper()
+	root := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(root, "config"))
+	cwd := filepath.Join(root, "project")
+	files := map[string]string{}
+	if global != "" {
+		files["config/flawdcode/config.json"] = global
+	}
+	if project != "" {
+		files["project/"+projectConfigName] = project
+	}
+	writeTree(t, root, files)
+	if err := os.MkdirAll(cwd, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return cwd
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Run("defaults without files", func(t *testing.T) {
+		cfg, err := LoadConfig(writeConfigs(t, "", ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		def := defaultConfig()
+		if cfg.UI != def.UI || cfg.Keys != def.Keys || cfg.Theme != autoTheme || len(cfg.Colors) != 0 || len(cfg.Args) != 0 {
+			t.Errorf("config = %+v, want the defaults", cfg)
+		}
+	})
+
+	t.Run("project overrides global key by key", func(t *testing.T) {
+		cwd := writeConfigs(t,
+			`{"args": {"perm-mode": "plan", "model": "opus"}, "ui": {"padding": 4, "tool_result_lines": 30}, "keys": {"interrupt": "ctrl+k"}}`,
+			`{"args": {"model": "haiku"}, "ui": {"padding": 1}, "theme": "light", "colors": {"assistant": "#ff8700"}}`)
+		cfg, err := LoadConfig(cwd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Args["perm-mode"] != "plan" || cfg.Args["model"] != "haiku" {
+			t.Errorf("args = %v", cfg.Args)
+		}
+		if cfg.UI.Padding != 1 || cfg.UI.ToolResultLines != 30 || cfg.UI.MaxCollapsedLines != 5 {
+			t.Errorf("ui = %+v", cfg.UI)
+		}
+		if cfg.Keys.Interrupt != "ctrl+k" || cfg.Keys.PermMode != "ctrl+p" {
+			t.Errorf("keys = %+v", cfg.Keys)
+		}
+		if cfg.Theme != "light" || len(cfg.Colors) != 1 || cfg.Colors["assistant"] != "#ff8700" {
+			t.Errorf("theme = %q, colors = %v", cfg.Theme, cfg.Colors)
+		}
+	})
+
+	errTests := []struct {
+		name    string
+		project string
+		want    []string
+	}{
+		{"project args", `{"args": {"claude-bin": "./evil.sh", "perm-mode": "bypassPermissions", "perm-prompt": false, "wire-log": true, "replay": "x.jsonl", "model": "opus"}}`, []string{
+			`"claude-bin" can only be set in the global config`,
+			`"perm-mode" can only be set`,
+			`"perm-prompt" can only be set`,
+			`"wire-log" can only be set`,
+			`"replay" can only be set`,
+		}},
+		{"unknown key", `{"ui": {"width": 3}}`, []string{projectConfigName, `unknown field "width"`}},
+		{"syntax", `{"ui": `, []string{projectConfigName, "unexpected EOF"}},
+		{"invalid values", `{"ui": {"padding": -1}, "theme": "neon", "colors": {"user": "blue", "sky": "4"}, "keys": {"todos": "ctrl+p", "editor": ""}}`, []string{
+			"padding must be between 0 and 20",
+			`theme: "neon" is not one of auto, dark, light`,
+			`colors: user: "blue" is not an ANSI color number`,
+			`colors: unknown color "sky"`,
+			`perm_mode and todos are both bound to "ctrl+p"`,
+			"keys: editor has no key",
+		}},
+		{"reserved keys", `{"keys": {"todos": "y", "interrupt": "esc", "editor": "tab", "submit": "enter"}}`, []string{
+			`keys: todos: "y" would be typed into the input`,
+			`keys: interrupt: "esc" is reserved for the pickers and popups`,
+			`keys: editor: "tab" is reserved`,
+		}},
+	}
+	t.Run("global config sets any flag", func(t *testing.T) {
+		cfg, err := LoadConfig(writeConfigs(t, `{"args": {"claude-bin": "/opt/claude", "perm-mode": "plan", "perm-prompt": false}}`, ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Args["claude-bin"] != "/opt/claude" || cfg.Args["perm-mode"] != "plan" || cfg.Args["perm-prompt"] != false {
+			t.Errorf("args = %v", cfg.Args)
+		}
+	})
+
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadConfig(writeConfigs(t, "", tt.project))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q should contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func testFlagSet(t *testing.T, args ...string) *flag.FlagSet {
+	t.Helper()
+	fs := flag.NewFlagSet("flawdcode", flag.ContinueOnError)
+	fs.String("perm-mode", "acceptEdits", "")
+	fs.String("model", "", "")
+	fs.Bool("persistent", false, "")
+	fs.String("permission-mcp", "", "")
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return fs
+}
+
+func TestConfigApplyArgs(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Args = map[string]any{"perm-mode": "plan", "model": "opus", "persistent": true}
+	fs := testFlagSet(t, "-model", "haiku")
+	if err := cfg.ApplyArgs(fs); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"perm-mode": "plan", "model": "haiku", "persistent": "true"} {
+		if got := fs.Lookup(name).Value.String(); got != want {
+			t.Errorf("-%s = %q, want %q (command line wins over the config)", name, got, want)
+		}
+	}
+
+	cfg.Args = map[string]any{"nope": 1, "permission-mcp": "/tmp/sock", "persistent": "maybe"}
+	err := cfg.ApplyArgs(testFlagSet(t))
+	for _, want := range []string{`unknown flag "nope"`, `unknown flag "permission-mcp"`, "persistent=maybe"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("error %v should contain %q", err, want)
+		}
+	}
+}
+
+func TestPrintConfig(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.UI.ToolResultLines = 30
+	var buf bytes.Buffer
+	if err := cfg.PrintConfig(&buf, testFlagSet(t, "-persistent")); err != nil {
+		t.Fatal(err)
+	}
+	// The output is itself a valid config
+	printed := defaultConfig()
+	if err := decodeConfig(buf.Bytes(), &printed); err != nil {
+		t.Fatalf("printed config doesn't load: %v\n%s", err, buf.String())
+	}
+	if printed.UI.ToolResultLines != 30 || printed.Args["persistent"] != true || printed.Args["perm-mode"] != "acceptEdits" {
+		t.Errorf("printed config = %+v", printed)
+	}
+	if _, ok := printed.Args["permission-mcp"]; ok {
+		t.Errorf("internal flags should not be printed: %v", printed.Args)
+	}
+	var raw map[string]json.RawMessage
+	if json.Unmarshal(buf.Bytes(), &raw) != nil || raw["keys"] == nil || raw["colors"] == nil {
+		t.Errorf("printed config should list keys and colors:\n%s", buf.String())
+	}
+}
+
+func TestConfiguredKeysAndLimits(t *testing.T) {
+	m := newTestChat(NewPrintBackend())
+	cfg := defaultConfig()
+	cfg.Keys.PermMode = "ctrl+o"
+	cfg.UI.ToolResultLines = 3
+	m.applyConfig(cfg)
+	m.textarea.Focus()
+
+	mode := m.permMode
+	m.Update(tea.KeyPressMsg{Code: 'p', Mod: tea.ModCtrl})
+	if m.permMode != mode {
+		t.Error("ctrl+p should no longer cycle the permission mode")
+	}
+	m.Update(tea.KeyPressMsg{Code: 'o', Mod: tea.ModCtrl})
+	if m.permMode == mode {
+		t.Error("ctrl+o should cycle the permission mode")
+	}
+	if !strings.Contains(m.dividerHint(), "ctrl+o:") {
+		t.Errorf("divider hint should show the configured key: %q", m.dividerHint())
+	}
+
+	var sb strings.Builder
+	m.renderToolResultCard(&sb, "1\n2\n3\n4\n5\n6", 60)
+	if out := stripANSI(sb.String()); !strings.Contains(out, "(3 more lines)") {
+		t.Errorf("tool result card should show 3 lines:\n%s", out)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// File-editing tools are shown as diffs instead of their result text. Edit
+// and MultiEdit carry old_string/new_string pairs, Write the whole file, so
+// the diff is computed from the tool input alone and works for calls that
+// are still pending or were loaded from a transcript.
+
+const (
+	diffContext  = 3   // unchanged lines kept around each change
+	diffMaxLines = 200 // lines rendered in an expanded card
+	diffMaxCells = 4_000_000
+)
+
+// diffOp marks a diff line as unchanged, added or removed. diffGap stands
+// for unchanged lines left out between hunks.
+type diffOp byte
+
+const (
+	diffEqual  diffOp = ' '
+	diffInsert diffOp = '+'
+	diffDelete diffOp = '-'
+	diffGap    diffOp = '~'
+)
+
+// diffLine is one line of a unified diff. OldNo and NewNo are 1-based line
+// numbers in the old and new text; the side a line is missing from is 0, as
+// are both when the edit's position in the file is unknown.
+type diffLine struct {
+	Op    diffOp
+	OldNo int
+	NewNo int
+	Text  string
+}
+
+// toolDiff is the diff shown for an Edit, MultiEdit or Write call.
+type toolDiff struct {
+	Lines   []diffLine
+	Added   int
+	Removed int
+	NewFile bool // Write: every line is an addition
+}
+
+// Stat returns the "+12 −3" summary for the compact tool line.
+func (d *toolDiff) Stat() string {
+	if d.NewFile {
+		return fmt.Sprintf("+%d", d.Added)
+	}
+	return fmt.Sprintf("+%d −%d", d.Added, d.Removed)
+}
+
+// parseToolDiff returns the diff for a file-editing tool call, or nil if the
+// tool doesn't edit files or its input is incomplete (e.g. still streaming).
+// output is the tool result, used to place edits at their real line numbers.
+func parseToolDiff(toolName, input, output string) *toolDiff {
+	type edit struct {
+		OldString string `json:"old_string"`
+		NewString string `json:"new_string"`
+	}
+	var fields struct {
+		edit
+		Content *string `json:"content"`
+		Edits   []edit  `json:"edits"`
+	}
+	if json.Unmarshal([]byte(input), &fields) != nil {
+		return nil
+	}
+
+	d := &toolDiff{}
+	switch toolName {
+	case "Edit":
+		if fields.OldString == "" && fields.NewString == "" {
+			return nil
+		}
+		start := editStartLine(output, fields.NewString)
+		d.Lines = diffHunks(diffLines(fields.OldString, fields.NewString, start))
+	case "MultiEdit":
+		if len(fields.Edits) == 0 {
+			return nil
+		}
+		for i, e := range fields.Edits {
+			if i > 0 {
+				d.Lines = append(d.Lines, diffLine{Op: diffGap})
+			}
+			start := editStartLine(output, e.NewString)
+			d.Lines = append(d.Lines, diffHunks(diffLines(e.OldString, e.NewString, start))...)
+		}
+	case "Write":
+		if fields.Content == nil {
+			return nil
+		}
+		d.NewFile = true
+		for i, line := range splitLines(*fields.Content) {
+			d.Lines = append(d.Lines, diffLine{Op: diffInsert, NewNo: i + 1, Text: line})
+		}
+	default:
+		return nil
+	}
+
+	for _, l := range d.Lines {
+		switch l.Op {
+		case diffInsert:
+			d.Added++
+		case diffDelete:
+			d.Removed++
+		}
+	}
+	return d
+}
+
+// splitLines splits text into lines, ignoring a trailing newline.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines computes a line diff of a and b with a longest common
+// subsequence, numbering lines from start, or not at all when start is 0.
+// Inputs too large for the table are shown as a full replacement.
+func diffLines(a, b string, start int) []diffLine {
+	al, bl := splitLines(a), splitLines(b)
+	n, m := len(al), len(bl)
+
+	var out []diffLine
+	oldNo, newNo := start, start
+	emit := func(op diffOp, text string) {
+		l := diffLine{Op: op, Text: text}
+		if op != diffInsert && start > 0 {
+			l.OldNo = oldNo
+			oldNo++
+		}
+		if op != diffDelete && start > 0 {
+			l.NewNo = newNo
+			newNo++
+		}
+		out = append(out, l)
+	}
+
+	if (n+1)*(m+1) > diffMaxCells {
+		for _, l := range al {
+			emit(diffDelete, l)
+		}
+		for _, l := range bl {
+			emit(diffInsert, l)
+		}
+		return out
+	}
+
+	// lcs[i][j] is the LCS length of al[i:] and bl[j:]
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if al[i] == bl[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case al[i] == bl[j]:
+			emit(diffEqual, al[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			emit(diffDelete, al[i])
+			i++
+		default:
+			emit(diffInsert, bl[j])
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		emit(diffDelete, al[i])
+	}
+	for ; j < m; j++ {
+		emit(diffInsert, bl[j])
+	}
+	return out
+}
+
+// diffHunks drops unchanged lines further than diffContext from any change,
+// replacing each dropped run with a single diffGap line.
+func diffHunks(lines []diffLine) []diffLine {
+	keep := make([]bool, len(lines))
+	for i, l := range lines {
+		if l.Op == diffEqual {
+			continue
+		}
+		for k := max(0, i-diffContext); k <= min(len(lines)-1, i+diffContext); k++ {
+			keep[k] = true
+		}
+	}
+	var out []diffLine
+	gap := false
+	for i, l := range lines {
+		if !keep[i] {
+			gap = true
+			continue
+		}
+		if gap && len(out) > 0 {
+			out = append(out, diffLine{Op: diffGap})
+		}
+		gap = false
+		out = append(out, l)
+	}
+	return out
+}
+
+// editStartLine finds where newString starts in the numbered snippet the
+// Edit tool returns, so the diff shows real line numbers. It returns 0 when
+// the snippet is missing or doesn't contain the new text.
+func editStartLine(output, newString string) int {
+	want := splitLines(newString)
+	if len(want) == 0 || strings.TrimSpace(want[0]) == "" {
+		return 0
+	}
+	want = want[:min(len(want), 3)]
+
+	nums, texts := numberedLines(output)
+	for i := 0; i+len(want) <= len(texts); i++ {
+		found := true
+		for k, w := range want {
+			if texts[i+k] != w {
+				found = false
+				break
+			}
+		}
+		if found {
+			return nums[i]
+		}
+	}
+	return 0
+}
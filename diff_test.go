@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// diffString renders diff lines compactly as "op old new text" rows.
+func diffString(lines []diffLine) string {
+	var rows []string
+	for _, l := range lines {
+		if l.Op == diffGap {
+			rows = append(rows, "~")
+			continue
+		}
+		rows = append(rows, strings.TrimRight(string(l.Op)+" "+itoa(l.OldNo)+" "+itoa(l.NewNo)+" "+l.Text, " "))
+	}
+	return strings.Join(rows, "\n")
+}
+
+func itoa(n int) string {
+	if n == 0 {
+		return "."
+	}
+	return strconv.Itoa(n)
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  string
+		start int
+		want  string
+	}{
+		{
+			name: "replace one line",
+			a:    "a\nb\nc", b: "a\nB\nc", start: 1,
+			want: "  1 1 a\n- 2 . b\n+ . 2 B\n  3 3 c",
+		},
+		{
+			name: "insert with offset",
+			a:    "x\nz\n", b: "x\ny\nz\n", start: 10,
+			want: "  10 10 x\n+ . 11 y\n  11 12 z",
+		},
+		{
+			name: "delete all",
+			a:    "gone", b: "", start: 1,
+			want: "- 1 . gone",
+		},
+		{
+			name: "unknown position",
+			a:    "a\nb", b: "a\nB", start: 0,
+			want: "  . . a\n- . . b\n+ . . B",
+		},
+		{
+			name: "identical",
+			a:    "same", b: "same", start: 1,
+			want: "  1 1 same",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffString(diffLines(tt.a, tt.b, tt.start)); got != tt.want {
+				t.Errorf("diffLines =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffHunks(t *testing.T) {
+	var oldLines, newLines []string
+	for i := range 20 {
+		line := string(rune('a' + i))
+		oldLines = append(oldLines, line)
+		if i == 2 || i == 16 {
+			line = strings.ToUpper(line)
+		}
+		newLines = append(newLines, line)
+	}
+	lines := diffHunks(diffLines(strings.Join(oldLines, "\n"), strings.Join(newLines, "\n"), 1))
+
+	var ops []string
+	for _, l := range lines {
+		ops = append(ops, string(l.Op))
+	}
+	// Two hunks with up to three lines of context, split by one gap
+	if got, want := strings.Join(ops, ""), "  -+   ~   -+   "; got != want {
+		t.Errorf("ops = %q, want %q", got, want)
+	}
+	if lines[0].OldNo != 1 || lines[len(lines)-1].NewNo != 20 {
+		t.Errorf("hunks should keep real line numbers: %+v", lines)
+	}
+}
+
+func TestParseToolDiff(t *testing.T) {
+	editOutput := "The file /tmp/p/main.go has been updated. Here's the result of running `cat -n` on a snippet of the edited file:\n" +
+		"    40→}\n    41→\n    42→func main() {\n    43→\trun()\n    44→}"
+	tests := []struct {
+		name      string
+		tool      string
+		input     string
+		output    string
+		wantStat  string
+		wantFirst int // NewNo or OldNo of the first line
+	}{
+		{
+			name:  "edit",
+			tool:  "Edit",
+			input: `{"file_path":"main.go","old_string":"func main() {\n\tstart()\n}","new_string":"func main() {\n\trun()\n}"}`,
+			// No result yet: the position is unknown
+			wantStat: "+1 −1", wantFirst: 0,
+		},
+		{
+			name:     "edit placed by result snippet",
+			tool:     "Edit",
+			input:    `{"file_path":"main.go","old_string":"func main() {\n\tstart()\n}","new_string":"func main() {\n\trun()\n}"}`,
+			output:   editOutput,
+			wantStat: "+1 −1", wantFirst: 42,
+		},
+		{
+			name:     "multiedit",
+			tool:     "MultiEdit",
+			input:    `{"file_path":"a.go","edits":[{"old_string":"a","new_string":"b"},{"old_string":"c\nd","new_string":"c"}]}`,
+			wantStat: "+1 −2", wantFirst: 0,
+		},
+		{
+			name:     "multiedit placed by result snippet",
+			tool:     "MultiEdit",
+			input:    `{"file_path":"main.go","edits":[{"old_string":"func main() {\n\tstart()","new_string":"func main() {\n\trun()"}]}`,
+			output:   editOutput,
+			wantStat: "+1 −1", wantFirst: 42,
+		},
+		{
+			name:     "write",
+			tool:     "Write",
+			input:    `{"file_path":"NOTES.md","content":"# Notes\n\n- one\n"}`,
+			wantStat: "+3", wantFirst: 1,
+		},
+		{name: "partial input", tool: "Edit", input: `{"file_path":"main.go","old_str`},
+		{name: "other tool", tool: "Read", input: `{"file_path":"main.go"}`},
+		{name: "write without content", tool: "Write", input: `{"file_path":"x"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := parseToolDiff(tt.tool, tt.input, tt.output)
+			if tt.wantStat == "" {
+				if d != nil {
+					t.Errorf("parseToolDiff = %+v, want nil", d)
+				}
+				return
+			}
+			if d == nil {
+				t.Fatal("parseToolDiff = nil")
+			}
+			if got := d.Stat(); got != tt.wantStat {
+				t.Errorf("Stat() = %q, want %q", got, tt.wantStat)
+			}
+			if first := max(d.Lines[0].OldNo, d.Lines[0].NewNo); first != tt.wantFirst {
+				t.Errorf("first line number = %d, want %d", first, tt.wantFirst)
+			}
+		})
+	}
+}
+
+func TestRenderEditDiff(t *testing.T) {
+	m := newTestChat(NewPrintBackend())
+	var content []string
+	for i := range 12 {
+		content = append(content, "line "+string(rune('a'+i)))
+	}
+	input, _ := json.Marshal(map[string]string{"file_path": "/tmp/p/notes.txt", "content": strings.Join(content, "\n")})
+	m.appendEntries([]chatEntry{
+		{role: "user", text: "write notes"},
+		{role: "assistant", blocks: []ChatBlock{
+			{Kind: BlockToolUse, ToolName: "Write", ToolID: "w1", ToolInput: string(input)},
+			{Kind: BlockToolResult, ToolID: "w1", ToolOutput: "File created successfully at: /tmp/p/notes.txt"},
+		}},
+	}...)
+	m.refreshViewport()
+
+	view := stripANSI(m.viewport.View())
+	if !strings.Contains(view, "⚙ Write /tmp/p/notes.txt +12") {
+		t.Errorf("compact line missing stat:\n%s", view)
+	}
+	if !strings.Contains(view, " 1 + line a") {
+		t.Errorf("diff preview missing:\n%s", view)
+	}
+	if strings.Contains(view, "line l") || strings.Contains(view, "File created") {
+		t.Errorf("collapsed card should truncate the preview and hide the result:\n%s", view)
+	}
+
+	m.expandedCards[fmt.Sprintf("tool-%d-0", m.entries[1].id)] = true
+	m.refreshViewport()
+	if view := stripANSI(m.viewport.View()); !strings.Contains(view, "12 + line l") {
+		t.Errorf("expanded card should show the whole file:\n%s", view)
+	}
+}
+
+func TestRenderDiffUnknownPosition(t *testing.T) {
+	m := newTestChat(NewPrintBackend())
+	d := parseToolDiff("MultiEdit", `{"file_path":"a.go","edits":[{"old_string":"x := 1","new_string":"x := 2"}]}`, "Applied 1 edit to a.go")
+	var sb strings.Builder
+	m.renderDiff(&sb, d, 60)
+	got := stripANSI(sb.String())
+	if want := "    - x := 1\n    + x := 2\n"; got != want {
+		t.Errorf("unplaced edit should render without a number gutter:\n%q\nwant\n%q", got, want)
+	}
+}
@@ -0,0 +1,544 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+)
+
+// newTestChat returns a sized ChatModel using backend.
+func newTestChat(backend Backend) *ChatModel {
+	m := NewChatModel()
+	m.backend = backend
+	m.SetSize(100, 40)
+	return m
+}
+
+// drive runs cmd and every command it produces, feeding the resulting
+// streaming messages back into m.Update, until no work is left. Messages the
+// harness doesn't know about (cursor blinks etc.) are dropped.
+func drive(t *testing.T, m *ChatModel, cmd tea.Cmd) {
+	t.Helper()
+	driveWith(t, m, cmd, nil)
+}
+
+// driveWith is drive, calling after (if set) with every message fed to m.
+// The command it returns is driven too.
+func driveWith(t *testing.T, m *ChatModel, cmd tea.Cmd, after func(tea.Msg) tea.Cmd) {
+	t.Helper()
+	queue := []tea.Cmd{cmd}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		if c == nil {
+			continue
+		}
+
+		msgCh := make(chan tea.Msg, 1)
+		go func() { msgCh <- c() }()
+		var msg tea.Msg
+		select {
+		case msg = <-msgCh:
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for command")
+		}
+
+		switch msg := msg.(type) {
+		case tea.BatchMsg:
+			queue = append(queue, msg...)
+		case ClaudeStreamStartMsg, ClaudeStreamChunkMsg, ClaudeStreamDoneMsg, scriptedPromptMsg:
+			queue = append(queue, m.Update(msg))
+			if after != nil {
+				queue = append(queue, after(msg))
+			}
+		}
+	}
+}
+
+// sendPrompt types prompt into the textarea, presses enter and drives the
+// resulting turn to completion.
+func sendPrompt(t *testing.T, m *ChatModel, prompt string) {
+	t.Helper()
+	m.textarea.SetValue(prompt)
+	drive(t, m, m.Update(tea.KeyPressMsg{Code: tea.KeyEnter}))
+}
+
+func TestE2EPrintModeTextTurn(t *testing.T) {
+	argsFile := useFakeClaude(t, fakeClaude{Fixture: "testdata/text_turn.jsonl", Delay: time.Millisecond})
+	m := newTestChat(NewPrintBackend())
+
+	sendPrompt(t, m, "say hi")
+
+	if len(m.entries) != 2 {
+		t.Fatalf("got %d entries, want 2: %+v", len(m.entries), m.entries)
+	}
+	if m.entries[0].role != "user" || m.entries[0].text != "say hi" {
+		t.Errorf("entry[0] = %+v, want user 'say hi'", m.entries[0])
+	}
+	last := m.entries[1]
+	if last.role != "assistant" || last.streaming {
+		t.Fatalf("entry[1] role=%q streaming=%v, want finalized assistant", last.role, last.streaming)
+	}
+	if last.text != "hey! what's up? 👋" {
+		t.Errorf("text = %q", last.text)
+	}
+	if !last.hasResult || last.model != "claude-opus-4-6" {
+		t.Errorf("hasResult=%v model=%q, want result from claude-opus-4-6", last.hasResult, last.model)
+	}
+	if m.sessionID != "dc8ffc51-d9d7-4241-83b4-9fdb7b953aab" {
+		t.Errorf("sessionID = %q", m.sessionID)
+	}
+	if m.totalRequests != 1 || m.totalCost != 0.042463 {
+		t.Errorf("totalRequests=%d totalCost=%v", m.totalRequests, m.totalCost)
+	}
+	if !m.initReceived || m.initModel == "" {
+		t.Error("init event not parsed")
+	}
+	if view := m.viewport.View(); !strings.Contains(view, "hey! what's up?") {
+		t.Errorf("viewport missing response text:\n%s", view)
+	}
+
+	// Second turn resumes the session
+	sendPrompt(t, m, "again")
+	calls := fakeClaudeInvocations(t, argsFile)
+	if len(calls) != 2 {
+		t.Fatalf("got %d invocations, want 2", len(calls))
+	}
+	if slices.Contains(calls[0], "--resume") {
+		t.Errorf("first turn should not resume: %v", calls[0])
+	}
+	idx := slices.Index(calls[1], "--resume")
+	if idx < 0 || calls[1][idx+1] != "dc8ffc51-d9d7-4241-83b4-9fdb7b953aab" {
+		t.Errorf("second turn should resume the session: %v", calls[1])
+	}
+	if calls[1][len(calls[1])-1] != "again" {
+		t.Errorf("prompt should be the last arg: %v", calls[1])
+	}
+}
+
+func TestE2EPrintModeToolTurn(t *testing.T) {
+	useFakeClaude(t, fakeClaude{Fixture: "testdata/tool_turn.jsonl"})
+	m := newTestChat(NewPrintBackend())
+
+	sendPrompt(t, m, "list files")
+
+	if len(m.entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(m.entries))
+	}
+	blocks := m.entries[1].blocks
+	toolIdx := lastBlockIndex(blocks, BlockToolUse)
+	if toolIdx < 0 {
+		t.Fatalf("no tool_use block in %+v", blocks)
+	}
+	tool := blocks[toolIdx]
+	if tool.ToolName != "Bash" || tool.ToolID != "toolu_01Bash" {
+		t.Errorf("tool = %s/%s, want Bash/toolu_01Bash", tool.ToolName, tool.ToolID)
+	}
+	if tool.ToolInput != "{\n  \"command\": \"ls -1\",\n  \"description\": \"List files\"\n}" {
+		t.Errorf("tool input not assembled and pretty-printed: %q", tool.ToolInput)
+	}
+	resIdx := lastBlockIndex(blocks, BlockToolResult)
+	if resIdx < 0 || blocks[resIdx].ToolOutput != "go.mod\nmain.go\nREADME.md" {
+		t.Errorf("missing tool result in %+v", blocks)
+	}
+	textIdx := lastBlockIndex(blocks, BlockText)
+	if textIdx < toolIdx || blocks[textIdx].Text != "There are three files: go.mod, main.go and README.md." {
+		t.Errorf("text block should follow the tool call: %+v", blocks)
+	}
+
+	view := m.viewport.View()
+	for _, want := range []string{"⚙ Bash", "ls -1", "README.md"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("viewport missing %q:\n%s", want, view)
+		}
+	}
+}
+
+func TestE2EPrintModeProcessError(t *testing.T) {
+	useFakeClaude(t, fakeClaude{
+		Fixture:  "testdata/text_turn.jsonl",
+		MaxLines: 3,
+		Stderr:   "API Error: overloaded",
+		ExitCode: 1,
+	})
+	m := newTestChat(NewPrintBackend())
+
+	sendPrompt(t, m, "hi")
+
+	if len(m.entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(m.entries))
+	}
+	e := m.entries[1]
+	if e.role != "error" {
+		t.Fatalf("role = %q, want error", e.role)
+	}
+	if !strings.Contains(e.text, "exit status 1") || !strings.Contains(e.text, "API Error: overloaded") {
+		t.Errorf("error text should carry exit status and stderr: %q", e.text)
+	}
+	if m.streamCh != nil {
+		t.Error("streamCh should be cleared after an error")
+	}
+	if m.totalRequests != 0 {
+		t.Errorf("failed turn should not count toward stats, got %d", m.totalRequests)
+	}
+}
+
+func TestE2EPersistentMode(t *testing.T) {
+	argsFile := useFakeClaude(t, fakeClaude{Fixture: "testdata/text_turn.jsonl"})
+	session := NewPersistentSession("")
+	t.Cleanup(func() { session.Close() })
+	m := newTestChat(session)
+
+	sendPrompt(t, m, "one")
+	sendPrompt(t, m, "two")
+
+	if got := len(fakeClaudeInvocations(t, argsFile)); got != 1 {
+		t.Fatalf("got %d invocations, want one long-lived process", got)
+	}
+	if len(m.entries) != 4 || m.entries[3].text != "hey! what's up? 👋" || !m.entries[3].hasResult {
+		t.Fatalf("second turn not finalized: %+v", m.entries)
+	}
+	if m.totalRequests != 2 {
+		t.Errorf("totalRequests = %d, want 2", m.totalRequests)
+	}
+
+	// Kill the child between turns; the next prompt restarts it with --resume.
+	session.mu.Lock()
+	proc := session.proc
+	session.mu.Unlock()
+	_ = proc.cmd.Process.Kill()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		session.mu.Lock()
+		gone := session.proc == nil
+		session.mu.Unlock()
+		if gone {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("read loop did not notice the dead process")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	sendPrompt(t, m, "three")
+
+	calls := fakeClaudeInvocations(t, argsFile)
+	if len(calls) != 2 {
+		t.Fatalf("got %d invocations, want a restart", len(calls))
+	}
+	idx := slices.Index(calls[1], "--resume")
+	if idx < 0 || calls[1][idx+1] != "dc8ffc51-d9d7-4241-83b4-9fdb7b953aab" {
+		t.Errorf("restart should resume the session: %v", calls[1])
+	}
+	if last := m.entries[len(m.entries)-1]; last.role != "assistant" || !last.hasResult {
+		t.Errorf("turn after restart not finalized: %+v", last)
+	}
+}
+
+func TestE2EPersistentModeCrashMidTurn(t *testing.T) {
+	useFakeClaude(t, fakeClaude{Fixture: "testdata/text_turn.jsonl", MaxLines: 4, Stderr: "segfault"})
+	session := NewPersistentSession("")
+	t.Cleanup(func() { session.Close() })
+	m := newTestChat(session)
+
+	sendPrompt(t, m, "hi")
+
+	e := m.entries[len(m.entries)-1]
+	if e.role != "error" || !strings.Contains(e.text, "segfault") {
+		t.Errorf("crash mid-turn should surface an error with stderr, got %+v", e)
+	}
+	if m.streamCh != nil {
+		t.Error("streamCh should be cleared after a crash")
+	}
+}
+
+func TestE2EPersistentModeCrashWithInjectedPrompt(t *testing.T) {
+	useFakeClaude(t, fakeClaude{Fixture: "testdata/text_turn.jsonl", MaxLines: 7, Delay: 50 * time.Millisecond, Stderr: "segfault"})
+	session := NewPersistentSession("")
+	t.Cleanup(func() { session.Close() })
+	m := newTestChat(session)
+
+	m.textarea.SetValue("hi")
+	driveWith(t, m, m.Update(tea.KeyPressMsg{Code: tea.KeyEnter}), onFirstText(func() tea.Cmd {
+		m.textarea.SetValue("and then?")
+		cmd := m.Update(tea.KeyPressMsg{Code: tea.KeyEnter, Mod: tea.ModAlt})
+		if len(m.queued) != 1 || !m.queued[0].injected {
+			t.Errorf("prompt should be injected: %+v", m.queued)
+		}
+		m.textarea.SetValue("draft")
+		return cmd
+	}))
+
+	if len(m.queued) != 0 || m.streamCh != nil {
+		t.Errorf("queue should be empty after the crash: %+v", m.queued)
+	}
+	if got := m.textarea.Value(); got != "and then?\n\ndraft" {
+		t.Errorf("input = %q, want the unanswered prompt before the draft", got)
+	}
+	last := m.entries[len(m.entries)-1]
+	if last.role != "error" || !strings.Contains(last.text, "back in the input") {
+		t.Errorf("last entry = %+v, want a notice about the unanswered prompt", last)
+	}
+}
+
+func TestE2EPermissionDenials(t *testing.T) {
+	argsFile := useFakeClaude(t, fakeClaude{Fixture: "testdata/denied_turn.jsonl"})
+	m := newTestChat(NewPrintBackend())
+	m.permMode = PermPlan
+
+	sendPrompt(t, m, "write some notes")
+
+	last := m.entries[len(m.entries)-1]
+	if len(last.denials) != 1 || last.denials[0].ToolName != "Write" || last.denials[0].ToolUseID != "toolu_01Write" {
+		t.Fatalf("denials = %+v, want one Write denial", last.denials)
+	}
+	view := m.viewport.View()
+	for _, want := range []string{"1 tool call denied", "(plan)", "/tmp/project/NOTES.md", "alt+r: retry in edits mode", "alt+a: retry allowing Write"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("viewport missing %q:\n%s", want, view)
+		}
+	}
+
+	// A notice after the turn doesn't stop the retry keys
+	m.textarea.SetValue("/perm")
+	m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	if last := m.entries[len(m.entries)-1]; last.role != "notice" {
+		t.Fatalf("last entry = %+v, want the /perm notice", last)
+	}
+
+	// alt+a re-runs the prompt with an allow rule for the denied tool
+	drive(t, m, m.Update(tea.KeyPressMsg{Code: 'a', Mod: tea.ModAlt}))
+	// alt+r re-runs it with a more permissive mode
+	drive(t, m, m.Update(tea.KeyPressMsg{Code: 'r', Mod: tea.ModAlt}))
+
+	calls := fakeClaudeInvocations(t, argsFile)
+	if len(calls) != 3 {
+		t.Fatalf("got %d invocations, want 3", len(calls))
+	}
+	for i, call := range calls[1:] {
+		if call[len(call)-1] != "write some notes" {
+			t.Errorf("retry %d should re-send the prompt: %v", i+1, call)
+		}
+	}
+	if !slices.Contains(calls[1], "--allowedTools=Write") {
+		t.Errorf("alt+a retry should allow Write: %v", calls[1])
+	}
+	if idx := slices.Index(calls[2], "--permission-mode"); idx < 0 || calls[2][idx+1] != "acceptEdits" {
+		t.Errorf("alt+r retry should escalate plan to acceptEdits: %v", calls[2])
+	}
+	if m.permMode != PermAcceptEdits {
+		t.Errorf("permMode = %q, want acceptEdits", m.permMode)
+	}
+	if n := len(m.entries); n != 7 || m.entries[n-2].role != "user" {
+		t.Errorf("each retry should add a user and assistant entry, got %d entries", n)
+	}
+
+	// Denial cards keep the mode their turn ran in after a re-render
+	m.SetSize(100, 200)
+	m.viewport.GotoTop()
+	view = stripANSI(m.viewport.View())
+	for _, want := range []string{"denied (plan)", "denied (edits)"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("viewport missing %q:\n%s", want, view)
+		}
+	}
+	if saved := newSavedEntry(m.entries[1]); saved.PermMode != PermPlan {
+		t.Errorf("saved entry mode = %q, want plan", saved.PermMode)
+	}
+}
+
+// onFirstText returns a driveWith hook that runs fn once, when the first
+// text of a turn has streamed in.
+func onFirstText(fn func() tea.Cmd) func(tea.Msg) tea.Cmd {
+	done := false
+	return func(msg tea.Msg) tea.Cmd {
+		if chunk, ok := msg.(ClaudeStreamChunkMsg); ok && chunk.TextDelta != "" && !done {
+			done = true
+			return fn()
+		}
+		return nil
+	}
+}
+
+func TestE2EInterrupt(t *testing.T) {
+	const session = "dc8ffc51-d9d7-4241-83b4-9fdb7b953aab"
+	tests := []struct {
+		name      string
+		backend   func() Backend
+		processes int // claude invocations for the interrupted turn and the next one
+	}{
+		{name: "print mode", backend: func() Backend { return NewPrintBackend() }, processes: 2},
+		{name: "persistent mode", backend: func() Backend { return NewPersistentSession("") }, processes: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			argsFile := useFakeClaude(t, fakeClaude{Fixture: "testdata/text_turn.jsonl", Delay: 20 * time.Millisecond})
+			backend := tt.backend()
+			t.Cleanup(func() { backend.Close() })
+			m := newTestChat(backend)
+
+			m.textarea.SetValue("hi")
+			driveWith(t, m, m.Update(tea.KeyPressMsg{Code: tea.KeyEnter}), onFirstText(func() tea.Cmd {
+				return m.Update(tea.KeyPressMsg{Code: 'x', Mod: tea.ModCtrl})
+			}))
+
+			last := m.entries[len(m.entries)-1]
+			if last.role != "assistant" || !last.interrupted || last.streaming {
+				t.Fatalf("interrupted turn should be finalized with a marker, got %+v", last)
+			}
+			if len(last.blocks) == 0 || last.text == "" {
+				t.Errorf("streamed blocks should be kept: %+v", last)
+			}
+			if m.streamCh != nil || m.interrupting {
+				t.Error("the turn should be over")
+			}
+			if m.sessionID != session {
+				t.Errorf("sessionID = %q, want %q from the init event", m.sessionID, session)
+			}
+			if view := m.viewport.View(); !strings.Contains(view, "⏹ interrupted") {
+				t.Errorf("viewport missing the interrupted marker:\n%s", view)
+			}
+
+			// The next prompt carries on with the same session
+			sendPrompt(t, m, "again")
+			if last := m.entries[len(m.entries)-1]; last.interrupted || !last.hasResult {
+				t.Errorf("next turn should complete normally: %+v", last)
+			}
+			calls := fakeClaudeInvocations(t, argsFile)
+			if len(calls) != tt.processes {
+				t.Fatalf("got %d invocations, want %d", len(calls), tt.processes)
+			}
+			if tt.processes > 1 {
+				if idx := slices.Index(calls[1], "--resume"); idx < 0 || calls[1][idx+1] != session {
+					t.Errorf("next turn should resume the session: %v", calls[1])
+				}
+			}
+		})
+	}
+}
+
+func TestE2EPromptQueue(t *testing.T) {
+	const session = "dc8ffc51-d9d7-4241-83b4-9fdb7b953aab"
+	tests := []struct {
+		name      string
+		backend   func() Backend
+		key       tea.KeyPressMsg
+		processes int
+	}{
+		{
+			name:      "queued until the turn ends",
+			backend:   func() Backend { return NewPrintBackend() },
+			key:       tea.KeyPressMsg{Code: tea.KeyEnter},
+			processes: 2,
+		},
+		{
+			name:      "injected mid-turn",
+			backend:   func() Backend { return NewPersistentSession("") },
+			key:       tea.KeyPressMsg{Code: tea.KeyEnter, Mod: tea.ModAlt},
+			processes: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			argsFile := useFakeClaude(t, fakeClaude{Fixture: "testdata/text_turn.jsonl", Delay: 20 * time.Millisecond})
+			backend := tt.backend()
+			t.Cleanup(func() { backend.Close() })
+			m := newTestChat(backend)
+
+			m.textarea.SetValue("hi")
+			driveWith(t, m, m.Update(tea.KeyPressMsg{Code: tea.KeyEnter}), onFirstText(func() tea.Cmd {
+				m.textarea.SetValue("and then?")
+				cmd := m.Update(tt.key)
+				if len(m.queued) != 1 || m.textarea.Value() != "" {
+					t.Errorf("prompt should be queued while streaming: %+v", m.queued)
+				}
+				if view := stripANSI(m.viewport.View()); !strings.Contains(view, "and then?") {
+					t.Errorf("queued prompt not shown:\n%s", view)
+				}
+				return cmd
+			}))
+
+			if len(m.entries) != 4 || m.entries[2].role != "user" || m.entries[2].text != "and then?" {
+				t.Fatalf("queued prompt should follow the first reply: %+v", m.entries)
+			}
+			for _, i := range []int{1, 3} {
+				if e := m.entries[i]; e.role != "assistant" || e.streaming || !e.hasResult {
+					t.Errorf("entry %d not finalized: %+v", i, e)
+				}
+			}
+			if e := m.entries[1]; e.model != "claude-opus-4-6" {
+				t.Errorf("first reply model = %q, want claude-opus-4-6", e.model)
+			}
+			if len(m.queued) != 0 || m.streamCh != nil {
+				t.Errorf("queue should be drained: %+v", m.queued)
+			}
+			if m.totalRequests != 2 {
+				t.Errorf("totalRequests = %d, want 2", m.totalRequests)
+			}
+			calls := fakeClaudeInvocations(t, argsFile)
+			if len(calls) != tt.processes {
+				t.Fatalf("got %d invocations, want %d", len(calls), tt.processes)
+			}
+			if tt.processes > 1 {
+				if idx := slices.Index(calls[1], "--resume"); idx < 0 || calls[1][idx+1] != session {
+					t.Errorf("queued prompt should resume the session: %v", calls[1])
+				}
+			}
+		})
+	}
+}
+
+func TestE2EClaudeSlashCommand(t *testing.T) {
+	argsFile := useFakeClaude(t, fakeClaude{Fixture: "testdata/text_turn.jsonl"})
+	m := newTestChat(NewPrintBackend())
+
+	sendPrompt(t, m, "hi")
+	if !slices.Contains(m.initSlashCommands, "compact") {
+		t.Fatalf("init slash commands not parsed: %v", m.initSlashCommands)
+	}
+	m.model = "sonnet"
+	sendPrompt(t, m, "/compact")
+
+	calls := fakeClaudeInvocations(t, argsFile)
+	if len(calls) != 2 || calls[1][len(calls[1])-1] != "/compact" {
+		t.Fatalf("claude's commands should be sent verbatim: %v", calls)
+	}
+	if idx := slices.Index(calls[1], "--model"); idx < 0 || calls[1][idx+1] != "sonnet" {
+		t.Errorf("turn should use the chosen model: %v", calls[1])
+	}
+}
+
+func TestE2EModelSwitch(t *testing.T) {
+	argsFile := useFakeClaude(t, fakeClaude{Fixture: "testdata/text_turn.jsonl"})
+	m := newTestChat(NewPrintBackend())
+	m.fallbackModel = "sonnet"
+
+	sendPrompt(t, m, "hi")
+	m.modelCommand("haiku")
+	sendPrompt(t, m, "again")
+
+	calls := fakeClaudeInvocations(t, argsFile)
+	if len(calls) != 2 {
+		t.Fatalf("got %d invocations, want 2", len(calls))
+	}
+	if slices.Contains(calls[0], "--model") {
+		t.Errorf("first turn should use claude's default: %v", calls[0])
+	}
+	if idx := slices.Index(calls[1], "--model"); idx < 0 || calls[1][idx+1] != "haiku" {
+		t.Errorf("second turn should use the new model: %v", calls[1])
+	}
+	if idx := slices.Index(calls[1], "--resume"); idx < 0 || calls[1][idx+1] != "dc8ffc51-d9d7-4241-83b4-9fdb7b953aab" {
+		t.Errorf("second turn should resume the session: %v", calls[1])
+	}
+	if idx := slices.Index(calls[1], "--fallback-model"); idx < 0 || calls[1][idx+1] != "sonnet" {
+		t.Errorf("turns should pass the fallback model: %v", calls[1])
+	}
+	// The fixture is answered by opus
+	if got := m.modelMismatch(); got != "haiku → claude-opus-4-6" {
+		t.Errorf("modelMismatch() = %q", got)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	tea "charm.land/bubbletea/v2"
+)
+
+// ctrl+g opens the input in $VISUAL or $EDITOR for prompts too long for the
+// three-line textarea. The TUI is suspended while the editor runs and the
+// edited text replaces the input when it exits; if the editor fails, the
+// draft is left as it was.
+
+// editorDoneMsg reports that the editor opened on Path has exited.
+type editorDoneMsg struct {
+	Path string
+	Err  error
+}
+
+// editorArgs returns the editor command line: $VISUAL, else $EDITOR, else
+// vi. The variable may carry arguments, e.g. "code --wait".
+func editorArgs() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if args := strings.Fields(os.Getenv(env)); len(args) > 0 {
+			return args
+		}
+	}
+	return []string{"vi"}
+}
+
+// composeInEditor writes the input to a temp file and runs the editor on it.
+func (m *ChatModel) composeInEditor() tea.Cmd {
+	f, err := os.CreateTemp("", "flawdcode-prompt-*.md")
+	if err != nil {
+		m.addNotice("error", fmt.Sprintf("editor: %v", err))
+		return nil
+	}
+	_, err = f.WriteString(m.textarea.Value())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		m.addNotice("error", fmt.Sprintf("editor: %v", err))
+		return nil
+	}
+	args := editorArgs()
+	cmd := exec.Command(args[0], append(args[1:], f.Name())...)
+	path := f.Name()
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return editorDoneMsg{Path: path, Err: err}
+	})
+}
+
+// finishEditing loads the edited text into the input and removes the temp
+// file. The draft is kept when the editor failed.
+func (m *ChatModel) finishEditing(msg editorDoneMsg) {
+	defer os.Remove(msg.Path)
+	if msg.Err != nil {
+		m.addNotice("error", fmt.Sprintf("editor %s: %v (draft kept)", editorArgs()[0], msg.Err))
+		return
+	}
+	data, err := os.ReadFile(msg.Path)
+	if err != nil {
+		m.addNotice("error", fmt.Sprintf("editor: %v (draft kept)", err))
+		return
+	}
+	m.textarea.SetValue(strings.TrimRight(string(data), "\n"))
+	m.historyPos = -1
+}
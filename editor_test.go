@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestEditorArgs(t *testing.T) {
+	tests := []struct {
+		name           string
+		visual, editor string
+		want           []string
+	}{
+		{"visual first", "nvim", "nano", []string{"nvim"}},
+		{"editor", "", "nano", []string{"nano"}},
+		{"with arguments", "", "code --wait", []string{"code", "--wait"}},
+		{"default", "", "", []string{"vi"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("VISUAL", tt.visual)
+			t.Setenv("EDITOR", tt.editor)
+			if got := editorArgs(); !slices.Equal(got, tt.want) {
+				t.Errorf("editorArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFinishEditing(t *testing.T) {
+	edited := func(t *testing.T, text string) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "prompt.md")
+		if err := os.WriteFile(path, []byte(text), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	t.Run("loads the edited text", func(t *testing.T) {
+		m := newTestChat(NewPrintBackend())
+		m.textarea.SetValue("draft")
+		path := edited(t, "first paragraph\n\nsecond paragraph\n")
+		m.Update(editorDoneMsg{Path: path})
+		if got, want := m.textarea.Value(), "first paragraph\n\nsecond paragraph"; got != want {
+			t.Errorf("input = %q, want %q", got, want)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("temp file not removed: %v", err)
+		}
+	})
+
+	t.Run("keeps the draft when the editor fails", func(t *testing.T) {
+		m := newTestChat(NewPrintBackend())
+		m.textarea.SetValue("draft")
+		path := edited(t, "half-written")
+		m.Update(editorDoneMsg{Path: path, Err: errors.New("exit status 1")})
+		if got := m.textarea.Value(); got != "draft" {
+			t.Errorf("input = %q, want the draft kept", got)
+		}
+		if n := len(m.entries); n != 1 || m.entries[0].role != "error" {
+			t.Errorf("entries = %+v, want one error notice", m.entries)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("temp file not removed: %v", err)
+		}
+	})
+}
@@ -0,0 +1,488 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// Export formats, chosen by file extension.
+const (
+	ExportMarkdown = "md"
+	ExportHTML     = "html"
+	ExportJSON     = "json"
+)
+
+// exportFormatFor picks the export format from path's extension, defaulting to Markdown.
+func exportFormatFor(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html", ".htm":
+		return ExportHTML
+	case ".json":
+		return ExportJSON
+	default:
+		return ExportMarkdown
+	}
+}
+
+// ExportSessionFile writes sess to path in the format its extension names.
+func ExportSessionFile(path string, sess *SavedSession) error {
+	var buf bytes.Buffer
+	if err := ExportSession(&buf, exportFormatFor(path), sess); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// ExportSession writes sess to w as Markdown, HTML or JSON.
+func ExportSession(w io.Writer, format string, sess *SavedSession) error {
+	sess = withoutNotices(sess)
+	switch format {
+	case ExportMarkdown:
+		_, err := io.WriteString(w, exportMarkdown(sess))
+		return err
+	case ExportHTML:
+		return exportHTML(w, sess)
+	case ExportJSON:
+		return exportJSON(w, sess)
+	}
+	return fmt.Errorf("unknown export format %q", format)
+}
+
+// withoutNotices returns sess without the notice entries that sessions
+// saved before notices were transient may hold.
+func withoutNotices(sess *SavedSession) *SavedSession {
+	if !slices.ContainsFunc(sess.Entries, func(e savedEntry) bool { return e.Role == "notice" }) {
+		return sess
+	}
+	out := *sess
+	out.Entries = slices.DeleteFunc(slices.Clone(sess.Entries), func(e savedEntry) bool { return e.Role == "notice" })
+	return &out
+}
+
+// exportSummary is the one-line header under the title: session, date and totals.
+func exportSummary(sess *SavedSession) string {
+	var parts []string
+	if sess.SessionID != "" {
+		parts = append(parts, "session "+sess.SessionID)
+	}
+	if !sess.UpdatedAt.IsZero() {
+		parts = append(parts, sess.UpdatedAt.Local().Format("2006-01-02 15:04"))
+	}
+	parts = append(parts, fmt.Sprintf("%d turns", sess.Turns))
+	parts = append(parts, fmt.Sprintf("$%.4f", sess.CostUSD))
+	if tok := sess.InputTokens + sess.OutputTokens; tok > 0 {
+		parts = append(parts, formatTokens(tok)+" tokens")
+	}
+	return strings.Join(parts, " · ")
+}
+
+// --- Markdown ---
+
+// mdFence returns a backtick fence longer than any backtick run in s.
+func mdFence(s string) string {
+	longest, run := 0, 0
+	for _, r := range s {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+// mdCode returns s as a fenced code block.
+func mdCode(s, lang string) string {
+	fence := mdFence(s)
+	return fence + lang + "\n" + strings.TrimRight(s, "\n") + "\n" + fence + "\n"
+}
+
+// mdQuote prefixes every line of s with "> ".
+func mdQuote(s string) string {
+	return "> " + strings.ReplaceAll(strings.TrimRight(s, "\n"), "\n", "\n> ") + "\n"
+}
+
+func exportMarkdown(sess *SavedSession) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n_%s_\n", sess.Topic, exportSummary(sess))
+
+	for _, se := range sess.Entries {
+		e := se.chatEntry()
+		switch e.role {
+		case "user":
+			sb.WriteString("\n## User\n\n" + e.text + "\n")
+
+		case "assistant":
+			sb.WriteString("\n## Assistant\n\n")
+			if len(e.blocks) == 0 && e.text != "" {
+				sb.WriteString(e.text + "\n\n")
+			}
+			writeMarkdownBlocks(&sb, e.blocks)
+			if len(e.denials) > 0 {
+				var lines []string
+				lines = append(lines, fmt.Sprintf("**⚠ %d tool call(s) denied**", len(e.denials)))
+				for _, d := range e.denials {
+					lines = append(lines, fmt.Sprintf("- `%s` %s", d.ToolName,
+						toolInputSummary(d.ToolName, string(d.ToolInput), 200)))
+				}
+				sb.WriteString(mdQuote(strings.Join(lines, "\n")) + "\n")
+			}
+			if e.interrupted {
+				sb.WriteString("_⏹ interrupted_\n\n")
+			}
+			if e.hasResult {
+				sb.WriteString("_" + strings.Join(messageMetaParts(e), " · ") + "_\n")
+			}
+
+		case "error":
+			sb.WriteString("\n## Error\n\n" + mdCode(e.text, ""))
+		}
+	}
+	return sb.String()
+}
+
+// writeMarkdownBlocks writes text as-is, thinking as a quote and each tool
+// call as a collapsible <details> section with its input and output.
+func writeMarkdownBlocks(sb *strings.Builder, blocks []ChatBlock) {
+	results := make(map[string]*ChatBlock)
+	for i := range blocks {
+		if blocks[i].Kind == BlockToolResult {
+			results[blocks[i].ToolID] = &blocks[i]
+		}
+	}
+
+	for _, b := range blocks {
+		switch b.Kind {
+		case BlockThinking:
+			if b.Text != "" {
+				sb.WriteString(mdQuote("_Thinking:_ "+b.Text) + "\n")
+			}
+		case BlockText:
+			if b.Text != "" {
+				sb.WriteString(b.Text + "\n\n")
+			}
+		case BlockToolUse:
+			writeMarkdownTool(sb, b, results[b.ToolID])
+		}
+	}
+}
+
+func writeMarkdownTool(sb *strings.Builder, b ChatBlock, result *ChatBlock) {
+	name := b.ToolName
+	if b.IsTask && b.TaskSubagentType != "" {
+		name = b.TaskSubagentType
+	}
+	summary := toolInputSummary(b.ToolName, b.ToolInput, 120)
+	if b.IsTask && b.TaskDescription != "" {
+		summary = b.TaskDescription
+	}
+	marker := ""
+	if result != nil && result.IsError {
+		marker = " ✗"
+	}
+	fmt.Fprintf(sb, "<details>\n<summary>⚙ %s: %s%s</summary>\n\n",
+		template.HTMLEscapeString(name), template.HTMLEscapeString(summary), marker)
+
+	sb.WriteString("**Input**\n\n" + mdCode(b.ToolInput, "json") + "\n")
+
+	if b.IsTask && len(b.TaskSubBlocks) > 0 {
+		sb.WriteString("**Activity**\n\n")
+		subResults := make(map[string]*ChatBlock)
+		for i := range b.TaskSubBlocks {
+			if b.TaskSubBlocks[i].Kind == BlockToolResult {
+				subResults[b.TaskSubBlocks[i].ToolID] = &b.TaskSubBlocks[i]
+			}
+		}
+		for _, sub := range b.TaskSubBlocks {
+			if sub.Kind != BlockToolUse {
+				continue
+			}
+			fmt.Fprintf(sb, "- `%s` %s", sub.ToolName, toolInputSummary(sub.ToolName, sub.ToolInput, 120))
+			if res, ok := subResults[sub.ToolID]; ok {
+				mark := "✓"
+				if res.IsError {
+					mark = "✗"
+				}
+				fmt.Fprintf(sb, " — %s %s", mark, firstLine(cleanToolOutput(res.ToolOutput), 120))
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\n")
+	}
+
+	if result != nil {
+		label := "**Output**"
+		if result.IsError {
+			label = "**Error**"
+		}
+		sb.WriteString(label + "\n\n" + mdCode(cleanToolOutput(result.ToolOutput), "") + "\n")
+	}
+	if parts := taskMetaParts(b.TaskMeta); len(parts) > 0 {
+		sb.WriteString("_" + strings.Join(parts, " · ") + "_\n\n")
+	}
+	sb.WriteString("</details>\n\n")
+}
+
+// --- HTML ---
+
+// htmlBlock is one card in the HTML export.
+type htmlBlock struct {
+	Kind    string // "text", "thinking", "tool"
+	HTML    template.HTML
+	Text    string
+	Summary string
+	Name    string
+	Input   string
+	Output  string
+	IsError bool
+	Sub     []htmlBlock
+	Meta    string
+}
+
+// htmlEntry is one message in the HTML export.
+type htmlEntry struct {
+	Role        string
+	Text        string
+	Blocks      []htmlBlock
+	Denials     []htmlBlock
+	Interrupted bool
+	Meta        string
+}
+
+var markdownToHTML = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// renderMarkdownHTML converts assistant markdown to HTML. Raw HTML in the
+// source is escaped by goldmark's default (unsafe rendering off).
+func renderMarkdownHTML(src string) template.HTML {
+	var buf bytes.Buffer
+	if err := markdownToHTML.Convert([]byte(src), &buf); err != nil {
+		return template.HTML("<pre>" + template.HTMLEscapeString(src) + "</pre>")
+	}
+	return template.HTML(buf.String())
+}
+
+func htmlToolBlocks(blocks []ChatBlock) []htmlBlock {
+	results := make(map[string]*ChatBlock)
+	for i := range blocks {
+		if blocks[i].Kind == BlockToolResult {
+			results[blocks[i].ToolID] = &blocks[i]
+		}
+	}
+	var out []htmlBlock
+	for _, b := range blocks {
+		switch b.Kind {
+		case BlockThinking:
+			if b.Text != "" {
+				out = append(out, htmlBlock{Kind: "thinking", Text: b.Text})
+			}
+		case BlockText:
+			if b.Text != "" {
+				out = append(out, htmlBlock{Kind: "text", HTML: renderMarkdownHTML(b.Text)})
+			}
+		case BlockToolUse:
+			hb := htmlBlock{
+				Kind:    "tool",
+				Name:    b.ToolName,
+				Summary: toolInputSummary(b.ToolName, b.ToolInput, 120),
+				Input:   b.ToolInput,
+				Meta:    strings.Join(taskMetaParts(b.TaskMeta), " · "),
+			}
+			if b.IsTask {
+				if b.TaskSubagentType != "" {
+					hb.Name = b.TaskSubagentType
+				}
+				if b.TaskDescription != "" {
+					hb.Summary = b.TaskDescription
+				}
+				hb.Sub = htmlToolBlocks(b.TaskSubBlocks)
+			}
+			if res := results[b.ToolID]; res != nil {
+				hb.Output = cleanToolOutput(res.ToolOutput)
+				hb.IsError = res.IsError
+			}
+			out = append(out, hb)
+		}
+	}
+	return out
+}
+
+func exportHTML(w io.Writer, sess *SavedSession) error {
+	var entries []htmlEntry
+	for _, se := range sess.Entries {
+		e := se.chatEntry()
+		he := htmlEntry{Role: e.role, Text: e.text, Interrupted: e.interrupted}
+		if e.role == "assistant" {
+			if len(e.blocks) == 0 && e.text != "" {
+				he.Blocks = []htmlBlock{{Kind: "text", HTML: renderMarkdownHTML(e.text)}}
+			} else {
+				he.Blocks = htmlToolBlocks(e.blocks)
+			}
+			for _, d := range e.denials {
+				he.Denials = append(he.Denials, htmlBlock{
+					Name:    d.ToolName,
+					Summary: toolInputSummary(d.ToolName, string(d.ToolInput), 120),
+					Input:   prettyJSON(d.ToolInput),
+				})
+			}
+			if e.hasResult {
+				he.Meta = strings.Join(messageMetaParts(e), " · ")
+			}
+		}
+		entries = append(entries, he)
+	}
+	return htmlTemplate.Execute(w, map[string]any{
+		"Topic":   sess.Topic,
+		"Summary": exportSummary(sess),
+		"Entries": entries,
+	})
+}
+
+// htmlTemplate mirrors the TUI cards: a thick left border colored by role
+// on a dark background. The page has no external assets.
+var htmlTemplate = template.Must(template.New("export").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Topic}}</title>
+<style>
+body { background: #1c1c1c; color: #d0d0d0; font: 14px/1.5 ui-monospace, SFMono-Regular, Menlo, monospace; max-width: 960px; margin: 2em auto; padding: 0 1em; }
+h1 { font-size: 1.3em; margin-bottom: 0; }
+.summary, .meta { color: #808080; }
+.meta { margin: .2em 0 1.2em 1em; }
+.card { border-left: 4px solid; padding: .4em 1em; margin: .6em 0; }
+.user { border-color: #000080; background: #303030; }
+.user .label { color: #5f87ff; font-weight: bold; }
+.assistant { border-color: #ff8700; }
+.thinking { border-color: #767676; background: #303030; color: #767676; font-style: italic; white-space: pre-wrap; }
+.tool { border-color: #808000; }
+.tool summary { cursor: pointer; }
+.tool .name { color: #d7af00; font-weight: bold; }
+.tool .input { color: #00afaf; }
+.error { border-color: #800000; background: #303030; color: #ff5f5f; }
+.denied { border-color: #ffff00; background: #303030; }
+.err { color: #ff5f5f; }
+.ok { color: #808080; }
+pre { background: #262626; padding: .5em; overflow-x: auto; white-space: pre-wrap; }
+code { background: #262626; }
+ul.activity { margin: .2em 0; }
+</style>
+</head>
+<body>
+<h1>{{.Topic}}</h1>
+<p class="summary">{{.Summary}}</p>
+{{range .Entries}}
+{{- if eq .Role "user"}}
+<div class="card user"><div class="label">User:</div><div style="white-space: pre-wrap">{{.Text}}</div></div>
+{{- else if eq .Role "error"}}
+<div class="card error"><pre>{{.Text}}</pre></div>
+{{- else if eq .Role "assistant"}}
+{{- range .Blocks}}{{template "block" .}}{{end}}
+{{- if .Denials}}
+<div class="card denied"><div class="name">⚠ {{len .Denials}} tool call(s) denied</div>
+{{- range .Denials}}
+<details><summary><span class="name">⚙ {{.Name}}</span> <span class="input">{{.Summary}}</span></summary><pre>{{.Input}}</pre></details>
+{{- end}}
+</div>
+{{- end}}
+{{- if .Interrupted}}
+<div class="meta">⏹ interrupted</div>
+{{- end}}
+{{- if .Meta}}
+<div class="meta">{{.Meta}}</div>
+{{- end}}
+{{- end}}
+{{end}}
+</body>
+</html>
+{{define "block"}}
+{{- if eq .Kind "text"}}
+<div class="card assistant">{{.HTML}}</div>
+{{- else if eq .Kind "thinking"}}
+<div class="card thinking">Thinking: {{.Text}}</div>
+{{- else if eq .Kind "tool"}}
+<div class="card tool"><details>
+<summary><span class="name">⚙ {{.Name}}</span> <span class="input">{{.Summary}}</span>{{if .IsError}} <span class="err">✗</span>{{end}}</summary>
+<pre>{{.Input}}</pre>
+{{- if .Sub}}
+<ul class="activity">
+{{- range .Sub}}{{if eq .Kind "tool"}}
+<li><span class="name">⚙ {{.Name}}</span> <span class="input">{{.Summary}}</span>{{if .Output}} <span class="{{if .IsError}}err{{else}}ok{{end}}">{{if .IsError}}✗{{else}}✓{{end}}</span>{{end}}</li>
+{{- end}}{{end}}
+</ul>
+{{- end}}
+{{- if .Output}}
+<pre{{if .IsError}} class="err"{{end}}>{{.Output}}</pre>
+{{- end}}
+{{- if .Meta}}
+<div class="ok">{{.Meta}}</div>
+{{- end}}
+</details></div>
+{{- end}}
+{{- end}}
+`))
+
+// --- JSON ---
+
+// jsonExport is the structured export: the session summary plus its entries.
+type jsonExport struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	*SavedSession
+}
+
+func exportJSON(w io.Writer, sess *SavedSession) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(jsonExport{Version: 1, ExportedAt: time.Now(), SavedSession: sess})
+}
+
+// exportLatest writes the most recent session in the working directory
+// (the one -continue would open) to path.
+func exportLatest(path string) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	store, err := DefaultSessionStore()
+	if err != nil {
+		return err
+	}
+	sessions, err := FindSessions(store, cwd)
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		return fmt.Errorf("no saved session in %s", cwd)
+	}
+	sess, err := OpenSession(store, sessions[0])
+	if err != nil {
+		return err
+	}
+	return ExportSessionFile(path, sess)
+}
+
+// exportCommand handles "/export [path]" typed in the chat: it writes the
+// conversation and reports where it went.
+func (m *ChatModel) exportCommand(args string) {
+	path := strings.TrimSpace(args)
+	if path == "" {
+		path = fmt.Sprintf("flawdcode-%s.md", time.Now().Format("20060102-150405"))
+	}
+	if err := ExportSessionFile(path, m.currentSession()); err != nil {
+		m.addNotice("error", "export: "+err.Error())
+	} else {
+		m.addNotice("notice", "Exported to "+path)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	tea "charm.land/bubbletea/v2"
+)
+
+func TestExportFormatFor(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"out.md", ExportMarkdown},
+		{"out.markdown", ExportMarkdown},
+		{"no-extension", ExportMarkdown},
+		{"out.HTML", ExportHTML},
+		{"out.htm", ExportHTML},
+		{"dir/out.json", ExportJSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := exportFormatFor(tt.path); got != tt.want {
+				t.Errorf("exportFormatFor(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMdFence(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{name: "plain", s: "ls -1", want: "```"},
+		{name: "inline code", s: "use `go test`", want: "```"},
+		{name: "nested fence", s: "```go\nfmt.Println()\n```", want: "````"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mdFence(tt.s); got != tt.want {
+				t.Errorf("mdFence(%q) = %q, want %q", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+// exportFixture returns the transcript fixture (text, thinking, a Task with
+// subagent activity) plus a tool turn with result metadata.
+func exportFixture(t *testing.T) *SavedSession {
+	t.Helper()
+	useFakeClaude(t, fakeClaude{Fixture: "testdata/tool_turn.jsonl"})
+	sess, err := LoadTranscript(filepath.Join("testdata", "claude", "projects", "-tmp-project", transcriptSession+".jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := newTestChat(NewPrintBackend())
+	m.LoadSession(sess)
+	sendPrompt(t, m, "list files")
+	return m.currentSession()
+}
+
+func TestExportMarkdown(t *testing.T) {
+	sess := exportFixture(t)
+	var buf bytes.Buffer
+	if err := ExportSession(&buf, ExportMarkdown, sess); err != nil {
+		t.Fatal(err)
+	}
+	md := buf.String()
+	for _, want := range []string{
+		"# find the config loader",
+		"## User\n\nfind the config loader",
+		"> _Thinking:_ I should search for it.",
+		"<summary>⚙ Explore: Find config loader</summary>",
+		"- `Grep` LoadConfig — ✓ config.go:12:func LoadConfig() {",
+		"<summary>⚙ Bash: ls -1</summary>",
+		"**Output**\n\n```\ngo.mod\nmain.go\nREADME.md\n```",
+		"There are three files: go.mod, main.go and README.md.",
+		"_claude-sonnet-4-5 · $0.0213 · ",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q:\n%s", want, md)
+		}
+	}
+}
+
+func TestExportHTML(t *testing.T) {
+	sess := exportFixture(t)
+	sess.Entries = append(sess.Entries, savedEntry{Role: "user", Text: "<script>alert(1)</script>"})
+	var buf bytes.Buffer
+	if err := ExportSession(&buf, ExportHTML, sess); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+	for _, want := range []string{
+		"<title>find the config loader</title>",
+		`<div class="card user">`,
+		`<span class="name">⚙ Explore</span>`,
+		`<span class="name">⚙ Grep</span>`,
+		"<p>There are three files: go.mod, main.go and README.md.</p>",
+		`<div class="meta">claude-sonnet-4-5 · $0.0213`,
+		"&lt;script&gt;alert(1)&lt;/script&gt;",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("html missing %q", want)
+		}
+	}
+	if strings.Contains(page, "<script>") || strings.Contains(page, "<link") {
+		t.Error("html should be self-contained and escape user text")
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	sess := exportFixture(t)
+	var buf bytes.Buffer
+	if err := ExportSession(&buf, ExportJSON, sess); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Version   int          `json:"version"`
+		SessionID string       `json:"session_id"`
+		Topic     string       `json:"topic"`
+		Entries   []savedEntry `json:"entries"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Version != 1 || doc.SessionID != "7f3c2a10-5b1e-4c8e-9a42-0d6e1f2b3c4d" || doc.Topic != "find the config loader" {
+		t.Errorf("doc = version %d session %q topic %q", doc.Version, doc.SessionID, doc.Topic)
+	}
+	if len(doc.Entries) != 6 {
+		t.Fatalf("got %d entries, want 6", len(doc.Entries))
+	}
+	last := doc.Entries[5]
+	if last.Result == nil || last.Result.CostUSD != 0.0213 || len(last.Blocks) != 3 {
+		t.Errorf("last entry = %+v", last)
+	}
+}
+
+func TestExportCommand(t *testing.T) {
+	sess := exportFixture(t)
+	m := newTestChat(NewPrintBackend())
+	m.LoadSession(sess)
+
+	path := filepath.Join(t.TempDir(), "out.html")
+	m.textarea.SetValue("/export " + path)
+	if cmd := m.Update(tea.KeyPressMsg{Code: tea.KeyEnter}); cmd != nil {
+		t.Error("/export should not start a turn")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "<!DOCTYPE html>") {
+		t.Errorf("export is not HTML: %.60s", data)
+	}
+	last := m.entries[len(m.entries)-1]
+	if last.role != "notice" || !strings.Contains(last.text, path) {
+		t.Errorf("last entry = %+v, want a notice naming the file", last)
+	}
+	if !strings.Contains(m.viewport.View(), "Exported to") {
+		t.Error("notice not rendered")
+	}
+
+	// Notices are left out of the saved session and later exports
+	if n := len(m.currentSession().Entries); n != len(sess.Entries) {
+		t.Errorf("session has %d entries, want %d without the notice", n, len(sess.Entries))
+	}
+	withNotice := *sess
+	withNotice.Entries = append(slices.Clone(sess.Entries), savedEntry{Role: "notice", Text: "Theme set to light"})
+	var buf bytes.Buffer
+	if err := ExportSession(&buf, ExportJSON, &withNotice); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "Theme set to") {
+		t.Error("JSON export should skip notices saved by older versions")
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/goterm/term"
+)
+
+// The test binary doubles as a fake claude executable: when fakeClaudeEnv is
+// set, TestMain replays a recorded NDJSON fixture instead of running tests.
+// Behaviour is scripted through FAKE_CLAUDE_* environment variables, which
+// reach the child because claudeEnv inherits the test's environment.
+const fakeClaudeEnv = "FLAWDCODE_FAKE_CLAUDE"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(fakeClaudeEnv) == "1" {
+		os.Exit(runFakeClaude(os.Args[1:]))
+	}
+	os.Exit(m.Run())
+}
+
+// fakeClaude scripts one fake claude run.
+type fakeClaude struct {
+	Fixture  string        // NDJSON file replayed to stdout (once per turn in stream-json mode)
+	Delay    time.Duration // pause before each replayed line
+	Stderr   string        // written to stderr on startup
+	ExitCode int           // process exit code
+	MaxLines int           // stop replaying after this many lines (simulates a crash); 0 = all
+	// TUI, when set, makes the fake run as claude's interactive TUI instead,
+	// answering every prompt with this text.
+	TUI string
+}
+
+// useFakeClaude points claudeBin at the test binary scripted by fc for the
+// duration of the test. It returns the path of a file recording the argv of
+// every invocation, one JSON array per line.
+func useFakeClaude(t *testing.T, fc fakeClaude) string {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("os.Executable: %v", err)
+	}
+	old := claudeBin
+	claudeBin = exe
+	t.Cleanup(func() { claudeBin = old })
+
+	argsFile := filepath.Join(t.TempDir(), "args.jsonl")
+	t.Setenv(fakeClaudeEnv, "1")
+	t.Setenv("FAKE_CLAUDE_ARGS_FILE", argsFile)
+	t.Setenv("FAKE_CLAUDE_FIXTURE", fc.Fixture)
+	t.Setenv("FAKE_CLAUDE_DELAY", fc.Delay.String())
+	t.Setenv("FAKE_CLAUDE_STDERR", fc.Stderr)
+	t.Setenv("FAKE_CLAUDE_EXIT", strconv.Itoa(fc.ExitCode))
+	t.Setenv("FAKE_CLAUDE_MAX_LINES", strconv.Itoa(fc.MaxLines))
+	t.Setenv("FAKE_CLAUDE_TUI", fc.TUI)
+	return argsFile
+}
+
+// fakeClaudeInvocations reads back the argv recorded by each fake claude run.
+func fakeClaudeInvocations(t *testing.T, argsFile string) [][]string {
+	t.Helper()
+	f, err := os.Open(argsFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("open args file: %v", err)
+	}
+	defer f.Close()
+
+	var calls [][]string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var args []string
+		if err := json.Unmarshal(scanner.Bytes(), &args); err != nil {
+			t.Fatalf("bad args line %q: %v", scanner.Text(), err)
+		}
+		calls = append(calls, args)
+	}
+	return calls
+}
+
+// runFakeClaude is the fake claude entry point. It returns the exit code.
+func runFakeClaude(args []string) int {
+	if path := os.Getenv("FAKE_CLAUDE_ARGS_FILE"); path != "" {
+		if f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644); err == nil {
+			line, _ := json.Marshal(args)
+			fmt.Fprintf(f, "%s\n", line)
+			f.Close()
+		}
+	}
+	if s := os.Getenv("FAKE_CLAUDE_STDERR"); s != "" {
+		fmt.Fprint(os.Stderr, s)
+	}
+
+	if reply := os.Getenv("FAKE_CLAUDE_TUI"); reply != "" {
+		return runFakeTUI(reply)
+	}
+
+	delay, _ := time.ParseDuration(os.Getenv("FAKE_CLAUDE_DELAY"))
+	maxLines, _ := strconv.Atoi(os.Getenv("FAKE_CLAUDE_MAX_LINES"))
+	exitCode, _ := strconv.Atoi(os.Getenv("FAKE_CLAUDE_EXIT"))
+
+	lines, err := readFixtureLines(os.Getenv("FAKE_CLAUDE_FIXTURE"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fake claude: %v\n", err)
+		return 2
+	}
+	if maxLines > 0 && maxLines < len(lines) {
+		lines = lines[:maxLines]
+	}
+
+	// replay writes the fixture, stopping early if stop fires between lines.
+	replay := func(stop <-chan string) (interruptID string) {
+		for _, line := range lines {
+			if delay > 0 {
+				select {
+				case <-time.After(delay):
+				case id := <-stop:
+					return id
+				}
+			}
+			fmt.Fprintln(os.Stdout, line)
+		}
+		return ""
+	}
+
+	idx := slices.Index(args, "--input-format")
+	if idx >= 0 && idx+1 < len(args) && args[idx+1] == "stream-json" {
+		// One replay per user message on stdin, until stdin is closed.
+		// Interrupt control requests cut the replay short, as claude does.
+		prompts := make(chan struct{}, 16)
+		interrupts := make(chan string, 16)
+		go func() {
+			defer close(prompts)
+			scanner := bufio.NewScanner(os.Stdin)
+			scanner.Buffer(make([]byte, 0, 1024*1024), 1024*1024)
+			for scanner.Scan() {
+				var msg struct {
+					Type      string `json:"type"`
+					RequestID string `json:"request_id"`
+					Request   struct {
+						Subtype string `json:"subtype"`
+					} `json:"request"`
+				}
+				if json.Unmarshal(scanner.Bytes(), &msg) != nil {
+					continue
+				}
+				switch {
+				case msg.Type == "user":
+					prompts <- struct{}{}
+				case msg.Type == "control_request" && msg.Request.Subtype == "interrupt":
+					interrupts <- msg.RequestID
+				}
+			}
+		}()
+		for range prompts {
+			if id := replay(interrupts); id != "" {
+				fmt.Fprintf(os.Stdout, `{"type":"control_response","response":{"subtype":"success","request_id":%q}}`+"\n", id)
+				fmt.Fprintf(os.Stdout, `{"type":"result","subtype":"error_during_execution","is_error":true,"num_turns":1,"session_id":%q}`+"\n", fixtureSessionID(lines))
+				continue
+			}
+			if maxLines > 0 {
+				break // a truncated turn means the process "crashed"
+			}
+		}
+	} else {
+		replay(nil)
+	}
+	return exitCode
+}
+
+// runFakeTUI imitates claude's interactive TUI on the terminal it runs in:
+// an input box redrawn in place, a working status line while it "thinks",
+// and the reply as a "⏺" block above the box. Esc while thinking
+// interrupts; "/exit" quits.
+func runFakeTUI(reply string) int {
+	if tio, err := term.Attr(os.Stdin); err == nil {
+		tio.Raw()
+		tio.Set(os.Stdin)
+	}
+	keys := make(chan byte, 4096)
+	go func() {
+		defer close(keys)
+		buf := make([]byte, 1024)
+		for {
+			n, err := os.Stdin.Read(buf)
+			for _, b := range buf[:n] {
+				keys <- b
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	rule := strings.Repeat("─", 40)
+	// box draws the input box and its hint, leaving the cursor on the hint;
+	// up moves back over it (and n lines more) and clears, for a redraw.
+	box := func() {
+		fmt.Printf("╭%s╮\r\n│ > %s│\r\n╰%s╯\r\n  ? for shortcuts", rule, strings.Repeat(" ", 37), rule)
+	}
+	up := func(n int) { fmt.Printf("\r\x1b[%dA\x1b[J", 3+n) }
+
+	fmt.Print("\x1b[?2004h✻ Welcome to the fake claude!\r\n\r\n")
+	box()
+	var input []byte
+	for b := range keys {
+		if b != '\r' {
+			input = append(input, b)
+			continue
+		}
+		prompt := strings.NewReplacer("\x1b[200~", "", "\x1b[201~", "").Replace(string(input))
+		input = nil
+		if prompt == "/exit" {
+			return 0
+		}
+
+		up(0)
+		fmt.Printf("> %s\r\n\r\n", strings.ReplaceAll(prompt, "\n", "\r\n  "))
+		interrupted := false
+		for i := 0; i < 5 && !interrupted; i++ {
+			fmt.Printf("%c Thinking… (%ds · esc to interrupt)\r\n", []rune("✻✶✳✢")[i%4], i)
+			box()
+			select {
+			case b := <-keys:
+				interrupted = b == 0x1b
+			case <-time.After(100 * time.Millisecond):
+			}
+			up(1)
+		}
+		if interrupted {
+			fmt.Print("  ⎿  Interrupted by user\r\n\r\n")
+		} else {
+			fmt.Printf("⏺ %s\r\n\r\n", strings.ReplaceAll(reply, "\n", "\r\n  "))
+		}
+		box()
+	}
+	return 0
+}
+
+// fixtureSessionID returns the session ID of the fixture's init event.
+func fixtureSessionID(lines []string) string {
+	for _, line := range lines {
+		var ev struct {
+			SessionID string `json:"session_id"`
+		}
+		if json.Unmarshal([]byte(line), &ev) == nil && ev.SessionID != "" {
+			return ev.SessionID
+		}
+	}
+	return ""
+}
+
+// readFixtureLines returns the non-empty lines of an NDJSON fixture.
+func readFixtureLines(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+)
+
+// Headless mode (-p) runs prompts without the TUI. Each turn goes through
+// the chat model exactly as if it had been typed, so sessions resume and
+// are saved the same way; only the finished messages are printed, in one
+// of the output formats below.
+
+// Headless output formats.
+const (
+	OutputRendered = "rendered" // the TUI's cards, colored when stdout is a terminal
+	OutputText     = "text"     // the reply's text only
+	OutputJSON     = "json"     // one headlessTurn object per line
+)
+
+var outputFormats = []string{OutputRendered, OutputText, OutputJSON}
+
+const headlessWidth = 100 // rendering width when $COLUMNS isn't set
+
+// headlessTurn is a turn's outcome in the json output format.
+type headlessTurn struct {
+	Prompt            string             `json:"prompt"`
+	Text              string             `json:"text"`
+	IsError           bool               `json:"is_error"`
+	Error             string             `json:"error,omitempty"` // the turn failed before claude answered
+	SessionID         string             `json:"session_id,omitempty"`
+	Model             string             `json:"model,omitempty"`
+	CostUSD           float64            `json:"total_cost_usd"`
+	DurationMs        int                `json:"duration_ms"`
+	DurationAPIMs     int                `json:"duration_api_ms"`
+	NumTurns          int                `json:"num_turns"`
+	Usage             TokenUsage         `json:"usage"`
+	PermissionDenials []PermissionDenial `json:"permission_denials,omitempty"`
+}
+
+// headlessPrompts returns the prompts to run: the arguments as one prompt,
+// or without arguments, each non-blank line of stdin.
+func headlessPrompts(args []string, stdin io.Reader) ([]string, error) {
+	if len(args) > 0 {
+		return []string{strings.Join(args, " ")}, nil
+	}
+	var prompts []string
+	scanner := bufio.NewScanner(stdin)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			prompts = append(prompts, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading prompts: %w", err)
+	}
+	if len(prompts) == 0 {
+		return nil, fmt.Errorf("no prompt: pass one as arguments or on stdin")
+	}
+	return prompts, nil
+}
+
+// headlessColumns returns the rendering width: $COLUMNS, else headlessWidth.
+func headlessColumns() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	return headlessWidth
+}
+
+// runHeadless runs prompts one after another in the same conversation and
+// writes each reply to w in format. It stops after the first turn that
+// fails or whose result is an error, and reports whether none did.
+func (m *ChatModel) runHeadless(prompts []string, format string, w io.Writer) (bool, error) {
+	m.SetSize(headlessColumns(), 40)
+	m.ui.MaxCollapsedLines = math.MaxInt // there's nothing to click to expand a card
+
+	for _, prompt := range prompts {
+		first := len(m.entries) + 1 // after the prompt's own user entry
+		m.runTurn(m.submitPrompt(prompt))
+
+		failed := false
+		for _, e := range m.entries[first:] {
+			failed = failed || e.role == "error" || e.hasResult && e.result.IsError
+		}
+		if err := m.writeHeadless(w, format, prompt, first); err != nil {
+			return false, err
+		}
+		if failed {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// runTurn runs the turn cmd starts, feeding its messages to Update until
+// it is done.
+func (m *ChatModel) runTurn(cmd tea.Cmd) {
+	for cmd != nil {
+		msg := cmd()
+		switch msg.(type) {
+		case ClaudeStreamStartMsg, ClaudeStreamChunkMsg:
+			cmd = m.Update(msg)
+		case ClaudeStreamDoneMsg:
+			m.Update(msg)
+			return
+		default:
+			return
+		}
+	}
+}
+
+// writeHeadless writes the entries from index first on, the reply to
+// prompt, in format.
+func (m *ChatModel) writeHeadless(w io.Writer, format, prompt string, first int) error {
+	entries := m.entries[first:]
+	switch format {
+	case OutputRendered:
+		var lines []string
+		for i := first; i < len(m.entries); i++ {
+			lines = append(lines, m.renderEntry(i).lines...)
+		}
+		for len(lines) > 0 && strings.TrimSpace(stripANSI(lines[0])) == "" {
+			lines = lines[1:]
+		}
+		_, err := lipgloss.Fprintln(w, strings.Join(lines, "\n"))
+		return err
+
+	case OutputText:
+		var texts []string
+		for _, e := range entries {
+			texts = append(texts, entryReplyText(e))
+		}
+		_, err := fmt.Fprintln(w, strings.Join(texts, "\n"))
+		return err
+
+	case OutputJSON:
+		turn := headlessTurn{Prompt: prompt}
+		for _, e := range entries {
+			if e.role == "error" {
+				turn.Error = e.text
+				continue
+			}
+			turn.Text = entryReplyText(e)
+			turn.Model = e.model
+			if e.hasResult {
+				r := e.result
+				turn.IsError = r.IsError
+				turn.SessionID = r.SessionID
+				turn.CostUSD = r.CostUSD
+				turn.DurationMs = r.DurationMs
+				turn.DurationAPIMs = r.DurationAPIMs
+				turn.NumTurns = r.NumTurns
+				turn.Usage = r.Usage
+				turn.PermissionDenials = r.PermissionDenials
+			}
+		}
+		if turn.SessionID == "" {
+			turn.SessionID = m.sessionID
+		}
+		return json.NewEncoder(w).Encode(turn)
+	}
+	return fmt.Errorf("unknown output format %q, want one of %s", format, strings.Join(outputFormats, ", "))
+}
+
+// entryReplyText is the plain text of an assistant or error entry: the
+// result's final message when there is one, else everything streamed.
+func entryReplyText(e chatEntry) string {
+	if e.hasResult && e.result.Result != "" {
+		return e.result.Result
+	}
+	return e.text
+}
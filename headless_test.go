@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestHeadlessPrompts(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		stdin   string
+		want    []string
+		wantErr bool
+	}{
+		{"arguments are one prompt", []string{"explain", "main.go"}, "ignored\n", []string{"explain main.go"}, false},
+		{"one prompt per stdin line", nil, "first\n\n  second  \nthird", []string{"first", "second", "third"}, false},
+		{"nothing to run", nil, "\n \n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := headlessPrompts(tt.args, strings.NewReader(tt.stdin))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("prompts = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeadlessOutput(t *testing.T) {
+	tests := []struct {
+		format string
+		check  func(t *testing.T, out string)
+	}{
+		{OutputText, func(t *testing.T, out string) {
+			if want := "hey! what's up? 👋\nhey! what's up? 👋\n"; out != want {
+				t.Errorf("output = %q, want %q", out, want)
+			}
+		}},
+		{OutputRendered, func(t *testing.T, out string) {
+			if strings.Contains(out, "\x1b[") {
+				t.Error("output to a non-terminal should have no colors")
+			}
+			if strings.Contains(out, "User:") {
+				t.Error("the prompts should not be echoed")
+			}
+			if strings.Count(out, "what's up?") != 2 || strings.Count(out, "$0.0425") != 2 {
+				t.Errorf("want both replies with their stats:\n%s", out)
+			}
+		}},
+		{OutputJSON, func(t *testing.T, out string) {
+			lines := strings.Split(strings.TrimSpace(out), "\n")
+			if len(lines) != 2 {
+				t.Fatalf("want one line per turn:\n%s", out)
+			}
+			var turn headlessTurn
+			if err := json.Unmarshal([]byte(lines[1]), &turn); err != nil {
+				t.Fatal(err)
+			}
+			if turn.Prompt != "second" || turn.Text != "hey! what's up? 👋" || turn.IsError ||
+				turn.SessionID != "dc8ffc51-d9d7-4241-83b4-9fdb7b953aab" || turn.Model != "claude-opus-4-6" ||
+				turn.CostUSD != 0.042463 || turn.Usage.CacheReadInputTokens != 21506 {
+				t.Errorf("turn = %+v", turn)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			argsFile := useFakeClaude(t, fakeClaude{Fixture: "testdata/text_turn.jsonl"})
+			m := NewChatModel()
+			var out bytes.Buffer
+			ok, err := m.runHeadless([]string{"first", "second"}, tt.format, &out)
+			if err != nil || !ok {
+				t.Fatalf("runHeadless = %v, %v", ok, err)
+			}
+			tt.check(t, out.String())
+
+			// The prompts are one conversation
+			calls := fakeClaudeInvocations(t, argsFile)
+			if len(calls) != 2 || !slices.Contains(calls[1], "dc8ffc51-d9d7-4241-83b4-9fdb7b953aab") {
+				t.Errorf("second turn should resume the session: %q", calls)
+			}
+		})
+	}
+}
+
+func TestHeadlessErrors(t *testing.T) {
+	t.Run("error result", func(t *testing.T) {
+		argsFile := useFakeClaude(t, fakeClaude{Fixture: "testdata/error_turn.jsonl"})
+		var out bytes.Buffer
+		ok, err := NewChatModel().runHeadless([]string{"first", "never sent"}, OutputJSON, &out)
+		if err != nil || ok {
+			t.Fatalf("runHeadless = %v, %v, want a failure", ok, err)
+		}
+		var turn headlessTurn
+		if err := json.Unmarshal(out.Bytes(), &turn); err != nil {
+			t.Fatal(err)
+		}
+		if !turn.IsError || !strings.Contains(turn.Text, "Overloaded") {
+			t.Errorf("turn = %+v", turn)
+		}
+		if calls := fakeClaudeInvocations(t, argsFile); len(calls) != 1 {
+			t.Errorf("claude ran %d times, want to stop after the error", len(calls))
+		}
+	})
+
+	t.Run("failed turn", func(t *testing.T) {
+		useFakeClaude(t, fakeClaude{Stderr: "boom", ExitCode: 3})
+		var out bytes.Buffer
+		ok, err := NewChatModel().runHeadless([]string{"hi"}, OutputText, &out)
+		if err != nil || ok {
+			t.Fatalf("runHeadless = %v, %v, want a failure", ok, err)
+		}
+		if !strings.Contains(out.String(), "boom") {
+			t.Errorf("output should carry the error: %q", out.String())
+		}
+	})
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"charm.land/lipgloss/v2"
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/rivo/uniseg"
+)
+
+// Read results come back in `cat -n` style ("    12→code"). The line numbers
+// are kept for the gutter and the code is highlighted with chroma, picking
+// the lexer from the file name.
+
+const readPreviewMaxLines = 200 // lines rendered in an expanded card
+
+// catNumberedLine matches a line of `cat -n` style output ("    12→text").
+var catNumberedLine = regexp.MustCompile(`^\s*(\d+)→(.*)$`)
+
+// numberedLines parses `cat -n` style output into line numbers and text.
+// Lines without a number prefix (e.g. trailing system reminders) are skipped.
+func numberedLines(output string) (nums []int, texts []string) {
+	for _, line := range strings.Split(output, "\n") {
+		match := catNumberedLine.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(match[1])
+		nums = append(nums, n)
+		texts = append(texts, match[2])
+	}
+	return nums, texts
+}
+
+// readInput returns the file a Read call reads and its offset/limit as a
+// range like "lines 10–59", or "" when the whole file was read.
+func readInput(input string) (path, lineRange string) {
+	var fields struct {
+		FilePath string `json:"file_path"`
+		Offset   int    `json:"offset"`
+		Limit    int    `json:"limit"`
+	}
+	if json.Unmarshal([]byte(input), &fields) != nil {
+		return "", ""
+	}
+	switch {
+	case fields.Offset > 0 && fields.Limit > 0:
+		lineRange = fmt.Sprintf("lines %d–%d", fields.Offset, fields.Offset+fields.Limit-1)
+	case fields.Offset > 0:
+		lineRange = fmt.Sprintf("from line %d", fields.Offset)
+	case fields.Limit > 0:
+		lineRange = fmt.Sprintf("lines 1–%d", fields.Limit)
+	}
+	return fields.FilePath, lineRange
+}
+
+// highlightLines syntax-highlights lines of the file at path with style,
+// truncating each to width cells. Only the style's foreground colors and
+// font attributes are used so previews sit on the card background. Files
+// without a matching lexer are returned plain.
+func highlightLines(path string, lines []string, width int, style *chroma.Style) []string {
+	lines = slices.Clone(lines)
+	for i, l := range lines {
+		lines[i] = strings.ReplaceAll(l, "\t", "    ")
+	}
+	out := make([]string, len(lines))
+
+	lexer := lexers.Match(filepath.Base(path))
+	if lexer == nil {
+		for i, l := range lines {
+			out[i] = truncateRunes(l, width)
+		}
+		return out
+	}
+	// Lex the whole snippet so multi-line strings and comments keep their state
+	it, err := chroma.Coalesce(lexer).Tokenise(nil, strings.Join(lines, "\n"))
+	if err != nil {
+		for i, l := range lines {
+			out[i] = truncateRunes(l, width)
+		}
+		return out
+	}
+
+	tokenStyles := make(map[chroma.TokenType]lipgloss.Style)
+	for i, tokens := range chroma.SplitTokensIntoLines(it.Tokens()) {
+		if i >= len(out) {
+			break
+		}
+		// Long lines are cut to leave room for "...", like truncateRunes
+		remaining := width
+		truncated := uniseg.GraphemeClusterCount(lines[i]) > width && width > 3
+		if truncated {
+			remaining = width - 3
+		}
+		var sb strings.Builder
+		for _, tok := range tokens {
+			if remaining == 0 {
+				break
+			}
+			text := strings.TrimRight(tok.Value, "\n")
+			if text == "" {
+				continue
+			}
+			text = cutGraphemes(text, remaining)
+			remaining -= uniseg.GraphemeClusterCount(text)
+			st, ok := tokenStyles[tok.Type]
+			if !ok {
+				st = chromaLipgloss(style.Get(tok.Type))
+				tokenStyles[tok.Type] = st
+			}
+			sb.WriteString(st.Render(text))
+		}
+		if truncated {
+			sb.WriteString("...")
+		}
+		out[i] = sb.String()
+	}
+	return out
+}
+
+// cutGraphemes returns the first n grapheme clusters of s.
+func cutGraphemes(s string, n int) string {
+	gr := uniseg.NewGraphemes(s)
+	end := 0
+	for i := 0; i < n && gr.Next(); i++ {
+		_, end = gr.Positions()
+	}
+	return s[:end]
+}
+
+// chromaLipgloss converts a chroma style entry to a lipgloss style,
+// ignoring its background.
+func chromaLipgloss(e chroma.StyleEntry) lipgloss.Style {
+	st := lipgloss.NewStyle()
+	if e.Colour.IsSet() {
+		st = st.Foreground(lipgloss.Color(e.Colour.String()))
+	}
+	if e.Bold == chroma.Yes {
+		st = st.Bold(true)
+	}
+	if e.Italic == chroma.Yes {
+		st = st.Italic(true)
+	}
+	if e.Underline == chroma.Yes {
+		st = st.Underline(true)
+	}
+	return st
+}
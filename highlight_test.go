@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+func TestNumberedLines(t *testing.T) {
+	output := "    9→package main\n   10→\n   11→\tfmt.Println(\"a→b\")\n\n<system-reminder>\nbe careful\n</system-reminder>"
+	nums, texts := numberedLines(output)
+	if !slices.Equal(nums, []int{9, 10, 11}) {
+		t.Errorf("nums = %v", nums)
+	}
+	if !slices.Equal(texts, []string{"package main", "", "\tfmt.Println(\"a→b\")"}) {
+		t.Errorf("texts = %q", texts)
+	}
+}
+
+func TestReadInput(t *testing.T) {
+	tests := []struct {
+		input     string
+		wantRange string
+	}{
+		{`{"file_path":"/p/main.go"}`, ""},
+		{`{"file_path":"/p/main.go","offset":10,"limit":50}`, "lines 10–59"},
+		{`{"file_path":"/p/main.go","offset":200}`, "from line 200"},
+		{`{"file_path":"/p/main.go","limit":20}`, "lines 1–20"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			path, got := readInput(tt.input)
+			if path != "/p/main.go" || got != tt.wantRange {
+				t.Errorf("readInput = %q, %q; want /p/main.go, %q", path, got, tt.wantRange)
+			}
+		})
+	}
+}
+
+func TestHighlightLines(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		lines     []string
+		width     int
+		want      []string // after stripping ANSI
+		wantColor bool
+	}{
+		{
+			name:  "go",
+			path:  "/p/main.go",
+			lines: []string{"/* a", "b */", "func main() {}"},
+			width: 40, want: []string{"/* a", "b */", "func main() {}"}, wantColor: true,
+		},
+		{
+			name:  "truncated with tabs",
+			path:  "main.go",
+			lines: []string{"\treturn someLongIdentifier"},
+			width: 15, want: []string{"    return s..."}, wantColor: true,
+		},
+		{
+			name:  "unknown extension",
+			path:  "notes.unknownext",
+			lines: []string{"plain text"},
+			width: 40, want: []string{"plain text"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := highlightLines(tt.path, tt.lines, tt.width, styles.Get("monokai"))
+			var plain []string
+			for _, l := range got {
+				plain = append(plain, stripANSI(l))
+			}
+			if !slices.Equal(plain, tt.want) {
+				t.Errorf("highlightLines = %q, want %q", plain, tt.want)
+			}
+			if colored := strings.Contains(strings.Join(got, ""), "\x1b["); colored != tt.wantColor {
+				t.Errorf("colored = %v, want %v", colored, tt.wantColor)
+			}
+		})
+	}
+}
+
+func TestRenderReadPreview(t *testing.T) {
+	m := newTestChat(NewPrintBackend())
+	var out []string
+	for i := 10; i < 30; i++ {
+		out = append(out, "    "+itoa(i)+"→// line "+itoa(i))
+	}
+	m.appendEntries([]chatEntry{
+		{role: "user", text: "read it"},
+		{role: "assistant", blocks: []ChatBlock{
+			{Kind: BlockToolUse, ToolName: "Read", ToolID: "r1", ToolInput: `{"file_path":"/p/main.go","offset":10,"limit":20}`},
+			{Kind: BlockToolResult, ToolID: "r1", ToolOutput: strings.Join(out, "\n")},
+		}},
+	}...)
+	m.refreshViewport()
+
+	view := stripANSI(m.viewport.View())
+	for _, want := range []string{"⚙ Read /p/main.go · lines 10–29", "10 // line 10"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("view missing %q:\n%s", want, view)
+		}
+	}
+	if strings.Contains(view, "// line 29") {
+		t.Errorf("collapsed card should only show the first lines:\n%s", view)
+	}
+
+	m.expandedCards[fmt.Sprintf("tool-%d-0", m.entries[1].id)] = true
+	m.refreshViewport()
+	if view := stripANSI(m.viewport.View()); !strings.Contains(view, "29 // line 29") {
+		t.Errorf("expanded card should show every line:\n%s", view)
+	}
+}
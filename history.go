@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+)
+
+// Submitted prompts are remembered per project directory. ↑ on the first
+// line of the input recalls older prompts and ↓ on the last line newer ones,
+// back to the draft being typed; ctrl+r searches them, newest first. A prompt
+// submitted again moves to the end rather than being recorded twice.
+
+const historyMax = 1000 // prompts kept per project
+
+// historyRecord is one line of a history file.
+type historyRecord struct {
+	Prompt string    `json:"prompt"`
+	Time   time.Time `json:"time"`
+}
+
+// PromptHistory is the prompt history of one project directory, kept in a
+// JSONL file that new prompts are appended to.
+type PromptHistory struct {
+	path    string
+	prompts []string // oldest first, without duplicates
+}
+
+// LoadPromptHistory reads the history in path; a missing file is an empty
+// history. A file that has grown well past historyMax records is compacted.
+func LoadPromptHistory(path string) (*PromptHistory, error) {
+	h := &PromptHistory{path: path}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load history: %w", err)
+	}
+	defer f.Close()
+
+	records := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var rec historyRecord
+		if json.Unmarshal(scanner.Bytes(), &rec) != nil || rec.Prompt == "" {
+			continue
+		}
+		records++
+		h.remember(rec.Prompt)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("load history: %w", err)
+	}
+	if records > 2*historyMax {
+		if err := h.compact(); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// DefaultPromptHistory returns the history of cwd under
+// $XDG_DATA_HOME/flawdcode/history.
+func DefaultPromptHistory(cwd string) (*PromptHistory, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+	return LoadPromptHistory(filepath.Join(dir, "history", projectSlug(cwd)+".jsonl"))
+}
+
+// Prompts returns the remembered prompts, oldest first.
+func (h *PromptHistory) Prompts() []string {
+	return h.prompts
+}
+
+// remember adds prompt as the newest entry, dropping an earlier copy.
+func (h *PromptHistory) remember(prompt string) {
+	if i := slices.Index(h.prompts, prompt); i >= 0 {
+		h.prompts = slices.Delete(h.prompts, i, i+1)
+	}
+	h.prompts = append(h.prompts, prompt)
+	if len(h.prompts) > historyMax {
+		h.prompts = slices.Delete(h.prompts, 0, len(h.prompts)-historyMax)
+	}
+}
+
+// Add records prompt and appends it to the history file.
+func (h *PromptHistory) Add(prompt string) error {
+	if prompt == "" {
+		return nil
+	}
+	h.remember(prompt)
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o700); err != nil {
+		return fmt.Errorf("save history: %w", err)
+	}
+	line, err := json.Marshal(historyRecord{Prompt: prompt, Time: time.Now()})
+	if err != nil {
+		return fmt.Errorf("save history: %w", err)
+	}
+	f, err := os.OpenFile(h.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("save history: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("save history: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("save history: %w", err)
+	}
+	return nil
+}
+
+// compact rewrites the history file with one record per remembered prompt,
+// through a temp file so a crash never truncates it.
+func (h *PromptHistory) compact() error {
+	var sb strings.Builder
+	for _, p := range h.prompts {
+		line, err := json.Marshal(historyRecord{Prompt: p})
+		if err != nil {
+			return fmt.Errorf("compact history: %w", err)
+		}
+		sb.Write(line)
+		sb.WriteByte('\n')
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(h.path), filepath.Base(h.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("compact history: %w", err)
+	}
+	if _, err := tmp.WriteString(sb.String()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("compact history: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("compact history: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), h.path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("compact history: %w", err)
+	}
+	return nil
+}
+
+// recordPrompt adds a submitted prompt to the history and ends any browsing.
+func (m *ChatModel) recordPrompt(text string) {
+	m.historyPos = -1
+	m.historyDraft = ""
+	if m.history == nil {
+		return
+	}
+	if err := m.history.Add(text); err != nil {
+		m.addNotice("error", err.Error())
+	}
+}
+
+// historyUp shows the prompt before the one shown, saving the draft when
+// browsing starts. It reports whether there was one.
+func (m *ChatModel) historyUp() bool {
+	if m.history == nil {
+		return false
+	}
+	prompts := m.history.Prompts()
+	pos := m.historyPos
+	if pos < 0 {
+		pos = len(prompts)
+	}
+	if pos == 0 {
+		return false
+	}
+	if m.historyPos < 0 {
+		m.historyDraft = m.textarea.Value()
+	}
+	m.historyPos = pos - 1
+	m.textarea.SetValue(prompts[m.historyPos])
+	return true
+}
+
+// historyDown shows the prompt after the one shown, or the draft after the
+// newest. It reports whether the history was being browsed.
+func (m *ChatModel) historyDown() bool {
+	if m.history == nil || m.historyPos < 0 {
+		return false
+	}
+	prompts := m.history.Prompts()
+	m.historyPos++
+	if m.historyPos >= len(prompts) {
+		m.historyPos = -1
+		m.textarea.SetValue(m.historyDraft)
+		m.historyDraft = ""
+		return true
+	}
+	m.textarea.SetValue(prompts[m.historyPos])
+	return true
+}
+
+// historyMatches returns the prompts containing the search query,
+// case-insensitively, newest first.
+func (m *ChatModel) historyMatches() []string {
+	if m.history == nil {
+		return nil
+	}
+	query := strings.ToLower(m.historyQuery)
+	prompts := m.history.Prompts()
+	var matches []string
+	for i := len(prompts) - 1; i >= 0; i-- {
+		if strings.Contains(strings.ToLower(prompts[i]), query) {
+			matches = append(matches, prompts[i])
+		}
+	}
+	return matches
+}
+
+// startHistorySearch opens the ctrl+r search.
+func (m *ChatModel) startHistorySearch() {
+	if m.history == nil || len(m.history.Prompts()) == 0 {
+		return
+	}
+	m.historySearching = true
+	m.historyQuery = ""
+	m.historySel = 0
+}
+
+// handleHistorySearchKey handles keys during a ctrl+r search: typing narrows
+// the matches, ctrl+r or ↑ moves to an older one and ↓ to a newer one, enter
+// or tab puts the selected prompt in the input, esc or ctrl+g cancels.
+func (m *ChatModel) handleHistorySearchKey(msg tea.KeyPressMsg) tea.Cmd {
+	matches := m.historyMatches()
+	switch msg.String() {
+	case "ctrl+r", "up":
+		if len(matches) > 0 {
+			m.historySel = min(m.historySel+1, len(matches)-1)
+		}
+	case "down":
+		m.historySel = max(m.historySel-1, 0)
+	case "enter", "tab":
+		if m.historySel < len(matches) {
+			m.textarea.SetValue(matches[m.historySel])
+			m.historyPos = -1
+		}
+		m.historySearching = false
+	case "esc", "ctrl+g":
+		m.historySearching = false
+	case "backspace":
+		if q := []rune(m.historyQuery); len(q) > 0 {
+			m.historyQuery = string(q[:len(q)-1])
+			m.historySel = 0
+		}
+	default:
+		if msg.Text != "" {
+			m.historyQuery += msg.Text
+			m.historySel = 0
+		}
+	}
+	return nil
+}
+
+// renderHistorySearch renders the ctrl+r search shown above the input.
+func (m *ChatModel) renderHistorySearch(width int) string {
+	var rows []popupRow
+	for _, p := range m.historyMatches() {
+		label, rest, multiline := strings.Cut(p, "\n")
+		if multiline {
+			label += fmt.Sprintf(" (+%d lines)", strings.Count(rest, "\n")+1)
+		}
+		rows = append(rows, popupRow{label: label})
+	}
+	hint := fmt.Sprintf("search: %s▏ · ctrl+r older · enter use · esc cancel", m.historyQuery)
+	if len(rows) == 0 {
+		rows = append(rows, popupRow{label: m.styleDim.Render("no matching prompt")})
+	}
+	return m.renderPopup(width, rows, m.historySel, hint)
+}
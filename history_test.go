@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	tea "charm.land/bubbletea/v2"
+)
+
+func TestPromptHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history", "project.jsonl")
+	h, err := LoadPromptHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"fix the tests", "run go vet", "fix the tests", "", "explain\nthis"} {
+		if err := h.Add(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"run go vet", "fix the tests", "explain\nthis"}
+	if !slices.Equal(h.Prompts(), want) {
+		t.Errorf("prompts = %q, want %q", h.Prompts(), want)
+	}
+
+	reloaded, err := LoadPromptHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(reloaded.Prompts(), want) {
+		t.Errorf("reloaded prompts = %q, want %q", reloaded.Prompts(), want)
+	}
+
+	t.Run("compacts", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "big.jsonl")
+		var sb strings.Builder
+		for i := range 2*historyMax + 10 {
+			fmt.Fprintf(&sb, "{\"prompt\":\"p%d\"}\n", i%(historyMax+5))
+		}
+		if err := os.WriteFile(path, []byte(sb.String()), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		h, err := LoadPromptHistory(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(h.Prompts()) != historyMax {
+			t.Errorf("loaded %d prompts, want %d", len(h.Prompts()), historyMax)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := strings.Count(string(data), "\n"); n != historyMax {
+			t.Errorf("compacted file has %d records, want %d", n, historyMax)
+		}
+	})
+}
+
+func newHistoryChat(t *testing.T, prompts ...string) *ChatModel {
+	t.Helper()
+	m := newTestChat(NewPrintBackend())
+	m.textarea.Focus()
+	h, err := LoadPromptHistory(filepath.Join(t.TempDir(), "history.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range prompts {
+		h.Add(p)
+	}
+	m.history = h
+	return m
+}
+
+func TestHistoryNavigation(t *testing.T) {
+	m := newHistoryChat(t, "first", "second\nline two", "third")
+	up := tea.KeyPressMsg{Code: tea.KeyUp}
+	down := tea.KeyPressMsg{Code: tea.KeyDown}
+
+	typeText(m, "draft")
+	steps := []struct {
+		key  tea.KeyPressMsg
+		want string
+	}{
+		{up, "third"},
+		{up, "second\nline two"},
+		{up, "second\nline two"}, // cursor moves to the first line
+		{up, "first"},
+		{up, "first"}, // oldest
+		{down, "second\nline two"},
+		{down, "third"},
+		{down, "draft"},
+	}
+	for i, s := range steps {
+		m.Update(s.key)
+		if got := m.textarea.Value(); got != s.want {
+			t.Fatalf("step %d: input = %q, want %q", i, got, s.want)
+		}
+	}
+
+	// Submitting a recalled prompt moves it to the end
+	m.Update(up)
+	m.Update(up)
+	m.recordPrompt(strings.TrimSpace(m.textarea.Value()))
+	want := []string{"first", "third", "second\nline two"}
+	if !slices.Equal(m.history.Prompts(), want) {
+		t.Errorf("prompts = %q, want %q", m.history.Prompts(), want)
+	}
+}
+
+func TestHistorySearch(t *testing.T) {
+	m := newHistoryChat(t, "run the tests", "fix lint", "Run it again", "write docs")
+	ctrlR := tea.KeyPressMsg{Code: 'r', Mod: tea.ModCtrl}
+
+	m.Update(ctrlR)
+	typeText(m, "run")
+	view := stripANSI(m.View())
+	for _, want := range []string{"search: run", "Run it again", "run the tests"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("search popup missing %q:\n%s", want, view)
+		}
+	}
+	if strings.Contains(view, "fix lint") {
+		t.Errorf("search popup shows a prompt that doesn't match:\n%s", view)
+	}
+
+	// ctrl+r again selects the older match, enter puts it in the input
+	m.Update(ctrlR)
+	m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	if m.historySearching {
+		t.Error("search still open after enter")
+	}
+	if got := m.textarea.Value(); got != "run the tests" {
+		t.Errorf("input = %q, want %q", got, "run the tests")
+	}
+
+	// esc cancels without touching the input
+	m.Update(ctrlR)
+	typeText(m, "docs")
+	m.Update(tea.KeyPressMsg{Code: tea.KeyEscape})
+	if m.historySearching || m.textarea.Value() != "run the tests" {
+		t.Errorf("after esc: searching=%v input=%q", m.historySearching, m.textarea.Value())
+	}
+}
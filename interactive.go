@@ -2,114 +2,261 @@ package main
 
 import (
 	"fmt"
-	"os"
+	"os/exec"
 	"regexp"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
-	expect "github.com/google/goexpect"
+	"github.com/google/goterm/term"
 )
 
-// ansiRe matches common ANSI escape sequences for stripping from PTY output.
+// ansiRe matches common ANSI escape sequences, for measuring and comparing
+// rendered text.
 var ansiRe = regexp.MustCompile(`\x1b\[[0-9;]*[a-zA-Z]|\x1b\][^\x07]*\x07|\x1b\[\?[0-9;]*[hl]`)
 
-// promptRe matches Claude Code's input prompt at end of output.
-// Matches lines ending with common prompt characters.
-var promptRe = regexp.MustCompile(`(?m)[❯>]\s*$`)
+// stripANSI removes ANSI escape sequences from s.
+func stripANSI(s string) string {
+	return ansiRe.ReplaceAllString(s, "")
+}
+
+// The interactive backend drives claude's own TUI over a PTY. Its output is
+// fed into a vtScreen, and everything is read off the emulated screen the
+// way a user would read it: the input box at the bottom means claude is
+// waiting for a prompt, "esc to interrupt" means it is working, and the
+// reply is the "⏺" blocks below the echoed prompt.
+
+const (
+	interactiveCols = 160 // wide, so claude wraps replies as little as possible
+	interactiveRows = 50
+
+	interactiveStartTimeout = 30 * time.Second
+	interactiveIdleTimeout  = 5 * time.Minute // no output at all for this long ends the turn
+	// interactiveSettle is how long the screen must stay unchanged before
+	// a turn that showed it was working counts as finished.
+	interactiveSettle = 300 * time.Millisecond
+	// interactiveSlowSettle is the same for turns that never did, such as
+	// slash commands.
+	interactiveSlowSettle = 2 * time.Second
+	interactiveKeyDelay   = 50 * time.Millisecond // between a pasted prompt and its Enter
+)
 
-// InteractiveSession manages a persistent interactive Claude Code process via goexpect PTY.
-// This is a proof of concept for replacing the print-mode (-p) subprocess approach
-// with a single long-lived interactive session.
+// responseMarkers start claude's message blocks; "●" is used where "⏺"
+// doesn't render.
+var responseMarkers = []string{"⏺", "●"}
+
+// InteractiveSession is a long-lived claude TUI process on a PTY.
 type InteractiveSession struct {
-	exp   *expect.GExpect
-	errCh <-chan error
-	mu    sync.Mutex // serializes Send calls
-}
-
-// StartInteractive spawns claude in interactive mode and waits for the initial prompt.
-func StartInteractive() (*InteractiveSession, error) {
-	// Build environment: inherit all except CLAUDECODE (avoid recursion)
-	env := os.Environ()
-	filtered := make([]string, 0, len(env))
-	for _, e := range env {
-		if !strings.HasPrefix(e, "CLAUDECODE=") {
-			filtered = append(filtered, e)
-		}
-	}
-	// Use dumb terminal to reduce escape sequences from the inner TUI
-	filtered = append(filtered, "TERM=dumb")
+	cmd   *exec.Cmd
+	pty   *term.PTY
+	errCh chan error
+
+	turnMu sync.Mutex // one prompt at a time
+
+	mu         sync.Mutex // guards screen and lastOutput
+	screen     *vtScreen
+	lastOutput time.Time
+
+	updates chan struct{} // signaled after each read of output
+	exited  chan struct{} // closed when the PTY stops producing output
+	done    chan struct{} // closed when claude has exited
+}
 
-	e, errCh, err := expect.SpawnWithArgs(
-		[]string{"claude"},
-		-1, // no global timeout
-		expect.SetEnv(filtered),
-	)
+// StartInteractive spawns claude in interactive mode and waits for its
+// input box. model is passed as --model unless empty.
+func StartInteractive(model string) (*InteractiveSession, error) {
+	pty, err := term.OpenPTY()
 	if err != nil {
+		return nil, fmt.Errorf("open pty: %w", err)
+	}
+	var tio term.Termios
+	tio.Wz = term.Winsize{WsRow: interactiveRows, WsCol: interactiveCols}
+	if err := tio.Setwinsz(pty.Slave); err != nil {
+		pty.Close()
+		return nil, fmt.Errorf("set pty size: %w", err)
+	}
+
+	var args []string
+	if model != "" {
+		args = append(args, "--model", model)
+	}
+	cmd := exec.Command(claudeBin, args...)
+	cmd.Env = append(claudeEnv(), "TERM=xterm-256color")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = pty.Slave, pty.Slave, pty.Slave
+	// claude must lead its own session with the PTY as controlling terminal
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	if err := cmd.Start(); err != nil {
+		pty.Close()
 		return nil, fmt.Errorf("spawn claude: %w", err)
 	}
+	// Only the child uses the slave; once it exits, reads from the master fail
+	pty.Slave.Close()
 
-	// Wait for initial prompt (up to 30s for startup)
-	_, _, err = e.Expect(promptRe, 30*time.Second)
-	if err != nil {
-		e.Close()
-		return nil, fmt.Errorf("waiting for initial prompt: %w", err)
+	s := &InteractiveSession{
+		cmd:        cmd,
+		pty:        pty,
+		errCh:      make(chan error, 1),
+		screen:     newVTScreen(interactiveCols, interactiveRows),
+		lastOutput: time.Now(),
+		updates:    make(chan struct{}, 1),
+		exited:     make(chan struct{}),
+		done:       make(chan struct{}),
 	}
+	go s.readLoop()
+	go func() {
+		s.errCh <- cmd.Wait()
+		close(s.done)
+	}()
 
-	return &InteractiveSession{exp: e, errCh: errCh}, nil
+	deadline := time.Now().Add(interactiveStartTimeout)
+	for {
+		v, alive := s.wait(s.Scrolled())
+		if v.ready() {
+			return s, nil
+		}
+		if !alive || time.Now().After(deadline) {
+			s.Close()
+			return nil, fmt.Errorf("waiting for claude's input prompt; screen:\n%s", strings.Join(v.lines, "\n"))
+		}
+	}
 }
 
-// SendPrompt sends a user prompt and returns a channel that streams response text chunks.
-// The channel is closed when Claude's prompt reappears (indicating the response is complete).
-// Only one SendPrompt may be active at a time.
-func (s *InteractiveSession) SendPrompt(prompt string) (<-chan string, error) {
+// readLoop feeds the PTY's output into the screen until it fails.
+func (s *InteractiveSession) readLoop() {
+	defer close(s.exited)
+	buf := make([]byte, 4096)
+	for {
+		n, err := s.pty.Master.Read(buf)
+		if n > 0 {
+			s.mu.Lock()
+			s.screen.Write(buf[:n])
+			s.lastOutput = time.Now()
+			replies := s.screen.TakeReplies()
+			s.mu.Unlock()
+			if len(replies) > 0 {
+				s.pty.Master.Write(replies)
+			}
+			select {
+			case s.updates <- struct{}{}:
+			default:
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Scrolled returns the screen's scrollback mark, see vtScreen.Scrolled.
+func (s *InteractiveSession) Scrolled() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.screen.Scrolled()
+}
+
+// wait returns a snapshot of the screen after the next output or a short
+// tick, whichever comes first. alive is false once claude's output ended.
+func (s *InteractiveSession) wait(mark int) (v screenView, alive bool) {
+	alive = true
+	select {
+	case <-s.updates:
+	case <-s.exited:
+		alive = false
+	case <-time.After(interactiveSettle / 3):
+	}
+	return s.view(mark), alive
+}
+
+// view returns a snapshot of the screen with the rows scrolled off since
+// mark.
+func (s *InteractiveSession) view(mark int) screenView {
 	s.mu.Lock()
+	defer s.mu.Unlock()
+	return screenView{
+		lines:    s.screen.Lines(),
+		scrolled: s.screen.ScrolledSince(mark),
+		quiet:    time.Since(s.lastOutput),
+	}
+}
+
+// SendPrompt types a prompt into claude's input box and returns a channel
+// streaming the reply text as it appears on screen. The channel is closed
+// once claude is idle again. Only one SendPrompt may be active at a time.
+func (s *InteractiveSession) SendPrompt(prompt string) (<-chan string, error) {
+	s.turnMu.Lock()
 
-	if err := s.exp.Send(prompt + "\n"); err != nil {
-		s.mu.Unlock()
+	s.mu.Lock()
+	mark := s.screen.Scrolled()
+	paste := s.screen.bracketedPaste
+	s.mu.Unlock()
+	// Transcript rows keep their index as the screen scrolls, so the echo
+	// is searched for only below what was already there.
+	from := len(s.view(mark).transcript())
+	if err := s.send(prompt, paste); err != nil {
+		s.turnMu.Unlock()
 		return nil, fmt.Errorf("send prompt: %w", err)
 	}
 
 	ch := make(chan string, 64)
 	go func() {
-		defer s.mu.Unlock()
+		defer s.turnMu.Unlock()
 		defer close(ch)
 
-		buf := make([]byte, 4096)
-		var acc strings.Builder
-		idleDeadline := time.Now().Add(5 * time.Minute)
-
-		for time.Now().Before(idleDeadline) {
-			n, err := s.exp.Read(buf)
-			if n > 0 {
-				raw := string(buf[:n])
-				clean := stripANSI(raw)
-				if clean != "" {
-					ch <- clean
-					acc.WriteString(clean)
-				}
-				// Reset idle deadline on any output
-				idleDeadline = time.Now().Add(5 * time.Minute)
-				// Check if prompt has reappeared (response complete)
-				if promptRe.MatchString(acc.String()) {
-					return
-				}
+		var sent string
+		worked := false
+		for {
+			v, alive := s.wait(mark)
+			echo := v.echoRow(prompt, from)
+			text := v.response(echo)
+			// Only growth can be streamed; a reply redrawn differently
+			// is left as first sent.
+			if len(text) > len(sent) && strings.HasPrefix(text, sent) {
+				ch <- text[len(sent):]
+				sent = text
 			}
-			if err != nil {
+			worked = worked || v.busy() || text != ""
+			settled := v.quiet >= interactiveSlowSettle || worked && v.quiet >= interactiveSettle
+			if !alive || v.quiet >= interactiveIdleTimeout || echo >= 0 && settled && v.idle() {
 				return
 			}
 		}
 	}()
-
 	return ch, nil
 }
 
-// Close terminates the interactive session gracefully.
+// send types prompt and presses Enter. Multi-line prompts are pasted when
+// claude accepts bracketed paste, since a newline would submit early.
+func (s *InteractiveSession) send(prompt string, paste bool) error {
+	input := strings.ReplaceAll(prompt, "\n", " ")
+	if paste {
+		input = "\x1b[200~" + prompt + "\x1b[201~"
+	}
+	if _, err := s.pty.Master.WriteString(input); err != nil {
+		return err
+	}
+	time.Sleep(interactiveKeyDelay)
+	_, err := s.pty.Master.WriteString("\r")
+	return err
+}
+
+// Interrupt presses Esc, which stops claude's reply; the turn's channel
+// closes once the input box is back.
+func (s *InteractiveSession) Interrupt() error {
+	_, err := s.pty.Master.WriteString("\x1b")
+	return err
+}
+
+// Close asks claude to exit, kills it if it doesn't, and releases the PTY.
 func (s *InteractiveSession) Close() error {
-	// Try graceful exit first
-	_ = s.exp.Send("/exit\n")
-	time.Sleep(500 * time.Millisecond)
-	return s.exp.Close()
+	if _, err := s.pty.Master.WriteString("/exit\r"); err == nil {
+		select {
+		case <-s.done:
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+	s.cmd.Process.Kill() // fails harmlessly if claude already exited
+	return s.pty.Master.Close()
 }
 
 // Err returns the error channel for the underlying process.
@@ -118,7 +265,201 @@ func (s *InteractiveSession) Err() <-chan error {
 	return s.errCh
 }
 
-// stripANSI removes ANSI escape sequences from PTY output.
-func stripANSI(s string) string {
-	return ansiRe.ReplaceAllString(s, "")
+// screenView is a snapshot of the emulated screen during a turn.
+type screenView struct {
+	lines    []string      // the screen's rows
+	scrolled []string      // rows scrolled off the top since the turn started
+	quiet    time.Duration // since the last output
+}
+
+// inputRow returns the screen row of claude's input box prompt, or -1. The
+// box's top border must sit right above it, so a "> " in a reply doesn't
+// count.
+func (v screenView) inputRow() int {
+	for i := len(v.lines) - 1; i > 0; i-- {
+		t := strings.TrimLeft(v.lines[i], " │")
+		if (strings.HasPrefix(t, ">") || strings.HasPrefix(t, "❯")) && strings.Contains(v.lines[i-1], "───") {
+			return i
+		}
+	}
+	return -1
+}
+
+// busy reports whether claude shows its working status line.
+func (v screenView) busy() bool {
+	for _, line := range v.lines {
+		if strings.Contains(strings.ToLower(line), "esc to interrupt") {
+			return true
+		}
+	}
+	return false
+}
+
+// idle reports whether claude is waiting for input.
+func (v screenView) idle() bool {
+	return v.inputRow() >= 0 && !v.busy()
+}
+
+// ready reports whether claude is idle and has stopped drawing.
+func (v screenView) ready() bool {
+	return v.idle() && v.quiet >= interactiveSettle
+}
+
+// transcript returns the turn's rows: the scrolled-off ones followed by the
+// screen's rows above the input box, trailing blank rows left out.
+func (v screenView) transcript() []string {
+	lines := v.lines
+	if row := v.inputRow(); row >= 0 {
+		lines = lines[:row-1]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return append(append([]string(nil), v.scrolled...), lines...)
+}
+
+// echoRow returns the transcript row, from row from on, where claude
+// echoed prompt, or -1. Long pastes are echoed as a "[Pasted text ...]"
+// placeholder.
+func (v screenView) echoRow(prompt string, from int) int {
+	first, _, _ := strings.Cut(strings.TrimSpace(prompt), "\n")
+	first = strings.Join(strings.Fields(first), " ")
+	lines := v.transcript()
+	for i := len(lines) - 1; i >= from; i-- {
+		echo, ok := strings.CutPrefix(lines[i], "> ")
+		if !ok {
+			continue
+		}
+		echo = strings.Join(strings.Fields(echo), " ")
+		if echo != "" && strings.HasPrefix(first, echo) || strings.Contains(echo, "[Pasted text") {
+			return i
+		}
+	}
+	return -1
+}
+
+// response returns the text of the message blocks after transcript row
+// echo, without their markers and indentation. Tool results ("⎿") are
+// left out.
+func (v screenView) response(echo int) string {
+	if echo < 0 {
+		return ""
+	}
+	var out []string
+	in := false
+	for _, line := range v.transcript()[echo+1:] {
+		if text, ok := cutResponseMarker(line); ok {
+			for len(out) > 0 && out[len(out)-1] == "" {
+				out = out[:len(out)-1]
+			}
+			if len(out) > 0 {
+				out = append(out, "")
+			}
+			out = append(out, text)
+			in = true
+			continue
+		}
+		switch {
+		case !in:
+		case line == "":
+			out = append(out, "")
+		case strings.HasPrefix(line, "  ") && !strings.HasPrefix(strings.TrimSpace(line), "⎿"):
+			out = append(out, strings.TrimPrefix(line, "  "))
+		default:
+			in = false
+		}
+	}
+	return strings.TrimRight(strings.Join(out, "\n"), "\n")
+}
+
+// cutResponseMarker returns line without the marker starting a message
+// block, if it has one.
+func cutResponseMarker(line string) (string, bool) {
+	for _, m := range responseMarkers {
+		if text, ok := strings.CutPrefix(line, m); ok {
+			return strings.TrimPrefix(text, " "), true
+		}
+	}
+	return "", false
+}
+
+// InteractiveBackend adapts an InteractiveSession to the Backend interface.
+// The session is spawned on the first turn and kept alive across turns.
+type InteractiveBackend struct {
+	mu      sync.Mutex
+	session *InteractiveSession
+	model   string // --model the session was spawned with
+}
+
+// NewInteractiveBackend creates a backend driving claude's interactive TUI over a PTY.
+func NewInteractiveBackend() *InteractiveBackend {
+	return &InteractiveBackend{}
+}
+
+// StartTurn sends the prompt to the interactive session, starting it if needed.
+// The session keeps its own conversation, so req.SessionID and req.PermMode are ignored.
+// The model is fixed at spawn, so a different req.Model starts a new session,
+// and with it a new conversation.
+func (b *InteractiveBackend) StartTurn(req TurnRequest) (<-chan StreamMsg, error) {
+	b.mu.Lock()
+	session := b.session
+	if session != nil && b.model != req.Model {
+		b.session = nil
+		b.mu.Unlock()
+		session.Close()
+		session = nil
+	} else {
+		b.mu.Unlock()
+	}
+
+	if session == nil {
+		s, err := StartInteractive(req.Model)
+		if err != nil {
+			return nil, err
+		}
+		b.mu.Lock()
+		b.session = s
+		b.model = req.Model
+		b.mu.Unlock()
+		session = s
+	}
+
+	ch, err := session.SendPrompt(req.Prompt)
+	if err != nil {
+		return nil, err
+	}
+	return adaptTextStream(req.Prompt, ch), nil
+}
+
+// Interrupt implements interrupter: the reply stops, the session lives on.
+func (b *InteractiveBackend) Interrupt() error {
+	b.mu.Lock()
+	session := b.session
+	b.mu.Unlock()
+	if session == nil {
+		return nil
+	}
+	return session.Interrupt()
+}
+
+// Cancel tears down the session; the next turn starts a fresh one.
+func (b *InteractiveBackend) Cancel() {
+	b.mu.Lock()
+	session := b.session
+	b.session = nil
+	b.mu.Unlock()
+	if session != nil {
+		session.Close()
+	}
+}
+
+// ResetSession implements sessionResetter; the next turn starts a fresh session.
+func (b *InteractiveBackend) ResetSession() {
+	b.Cancel()
+}
+
+// Close terminates the interactive session.
+func (b *InteractiveBackend) Close() error {
+	b.Cancel()
+	return nil
 }
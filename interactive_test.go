@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestScreenView(t *testing.T) {
+	box := []string{"╭──────────╮", "│ >        │", "╰──────────╯", "  ? for shortcuts"}
+	screen := func(rows ...string) []string { return append(rows, box...) }
+
+	tests := []struct {
+		name     string
+		lines    []string
+		scrolled []string
+		prompt   string
+		from     int
+		busy     bool
+		want     string // the reply; "-" when the prompt's echo isn't there
+	}{
+		{
+			name:   "reply blocks",
+			lines:  screen("> hi there", "", "⏺ Hello!", "  Second line", "", "  after a blank", "", "⏺ Read(main.go)", "  ⎿  Read 40 lines", "", "● Done."),
+			prompt: "hi there",
+			want:   "Hello!\nSecond line\n\nafter a blank\n\nRead(main.go)\n\nDone.",
+		},
+		{
+			name:   "working",
+			lines:  screen("> hi", "", "⏺ Partial", "", "✻ Thinking… (3s · esc to interrupt)"),
+			prompt: "hi",
+			busy:   true,
+			want:   "Partial",
+		},
+		{
+			name:     "prompt scrolled off",
+			scrolled: []string{"> a long question", "", "⏺ line 1"},
+			lines:    screen("  line 2 >", "  line 3 ❯"),
+			prompt:   "a long question\nwith details",
+			want:     "line 1\nline 2 >\nline 3 ❯",
+		},
+		{
+			name:   "older echo of the same prompt",
+			lines:  screen("> again", "", "⏺ old answer", "", "> again", "", "⏺ new answer"),
+			prompt: "again",
+			from:   3,
+			want:   "new answer",
+		},
+		{
+			name:   "echo not drawn yet",
+			lines:  screen("> again", "", "⏺ old answer"),
+			prompt: "again",
+			from:   3,
+			want:   "-",
+		},
+		{
+			name:   "pasted text placeholder",
+			lines:  screen("> [Pasted text #1 +40 lines]", "", "⏺ Got it."),
+			prompt: strings.Repeat("data\n", 40),
+			want:   "Got it.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := screenView{lines: tt.lines, scrolled: tt.scrolled}
+			if v.busy() != tt.busy || v.idle() == tt.busy {
+				t.Errorf("busy = %v, idle = %v, want busy %v", v.busy(), v.idle(), tt.busy)
+			}
+			echo := v.echoRow(tt.prompt, tt.from)
+			got := v.response(echo)
+			if echo < 0 {
+				got = "-"
+			}
+			if got != tt.want {
+				t.Errorf("response = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScreenViewInputRow(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  int
+	}{
+		{"boxed prompt", []string{"⏺ hi", "╭────╮", "│ > x │", "╰────╯"}, 2},
+		{"ruled prompt", []string{"⏺ hi", "─────────", "❯ ", "─────────"}, 2},
+		{"prompt character in a reply", []string{"⏺ a > b", "  > quoted", "> echo"}, -1},
+		{"empty screen", make([]string, 5), -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (screenView{lines: tt.lines}).inputRow(); got != tt.want {
+				t.Errorf("inputRow = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// collectTurn runs one turn on b and returns the streamed text.
+func collectTurn(t *testing.T, b Backend, prompt string) string {
+	t.Helper()
+	ch, err := b.StartTurn(TurnRequest{Prompt: prompt, Model: "haiku"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var text strings.Builder
+	for msg := range ch {
+		if msg.Event != nil {
+			text.WriteString(extractDeltas(msg.Event.Raw).Text)
+		}
+	}
+	return text.String()
+}
+
+func TestInteractiveBackend(t *testing.T) {
+	var reply []string
+	reply = append(reply, "Here is the list >")
+	for i := range interactiveRows + 10 {
+		reply = append(reply, fmt.Sprintf("  item %d ❯", i))
+	}
+	reply = append(reply, "", "That's all.")
+	argsFile := useFakeClaude(t, fakeClaude{TUI: strings.Join(reply, "\n")})
+
+	b := NewInteractiveBackend()
+	defer b.Close()
+	// The reply scrolls past the screen and has prompt characters at line
+	// ends; all of it arrives, and only once claude is done.
+	for _, prompt := range []string{"list things\nplease", "list things\nplease"} {
+		if got := collectTurn(t, b, prompt); got != strings.Join(reply, "\n") {
+			t.Errorf("reply = %q, want %q", got, strings.Join(reply, "\n"))
+		}
+	}
+
+	calls := fakeClaudeInvocations(t, argsFile)
+	if want := [][]string{{"--model", "haiku"}}; len(calls) != 1 || !slices.Equal(calls[0], want[0]) {
+		t.Errorf("claude runs = %q, want one with %q", calls, want)
+	}
+}
+
+func TestInteractiveBackendInterrupt(t *testing.T) {
+	useFakeClaude(t, fakeClaude{TUI: "answer"})
+	b := NewInteractiveBackend()
+	defer b.Close()
+	if got := collectTurn(t, b, "warm up"); got != "answer" {
+		t.Fatalf("reply = %q, want %q", got, "answer")
+	}
+
+	ch, err := b.StartTurn(TurnRequest{Prompt: "stop me"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Interrupt(); err != nil {
+		t.Fatal(err)
+	}
+	for msg := range ch {
+		if msg.Event != nil {
+			t.Errorf("interrupted turn streamed %q", extractDeltas(msg.Event.Raw).Text)
+		}
+	}
+
+	if got := collectTurn(t, b, "next"); got != "answer" {
+		t.Errorf("reply after an interrupt = %q, want %q", got, "answer")
+	}
+}
+
+func TestInteractiveBackendModelSwitch(t *testing.T) {
+	argsFile := useFakeClaude(t, fakeClaude{TUI: "answer"})
+	b := NewInteractiveBackend()
+	defer b.Close()
+	collectTurn(t, b, "first")
+
+	// The model is fixed at spawn, so /model restarts claude with the new one
+	ch, err := b.StartTurn(TurnRequest{Prompt: "second", Model: "opus"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range ch {
+	}
+
+	calls := fakeClaudeInvocations(t, argsFile)
+	want := [][]string{{"--model", "haiku"}, {"--model", "opus"}}
+	if len(calls) != 2 || !slices.Equal(calls[0], want[0]) || !slices.Equal(calls[1], want[1]) {
+		t.Errorf("claude runs = %q, want %q", calls, want)
+	}
+}
@@ -3,21 +3,159 @@ package main
 import (
 	"flag"
 	"log"
+	"os"
+	"slices"
+	"strings"
 
 	tea "charm.land/bubbletea/v2"
 )
 
 func main() {
 	wireLog := flag.Bool("wire-log", false, "write raw wire log to /tmp/flawdcode-*.jsonl")
-	interactive := flag.Bool("interactive", false, "use goexpect-based interactive session (experimental)")
+	interactive := flag.Bool("interactive", false, "drive claude's interactive TUI on an emulated terminal (experimental)")
+	persistent := flag.Bool("persistent", false, "keep one claude process alive across turns (stream-json input)")
+	bin := flag.String("claude-bin", "claude", "path to the claude executable")
+	replay := flag.String("replay", "", "replay a wire log recorded with -wire-log instead of running claude")
+	replaySpeed := flag.Float64("replay-speed", 1, "replay speed multiplier (0 = no delays)")
+	permPromptFlag := flag.Bool("perm-prompt", true, "ask in the TUI when claude needs permission for a tool call")
+	permissionMCP := flag.String("permission-mcp", "", "internal: serve the permission prompt MCP tool on stdio, forwarding to this socket")
+	model := flag.String("model", "", "model for the conversation, an alias (opus, sonnet, haiku) or a full name")
+	fallbackModel := flag.String("fallback-model", "", "model to fall back to when the main one is overloaded")
 	permMode := flag.String("perm-mode", "acceptEdits", "initial permission mode (plan, acceptEdits, bypassPermissions, dontAsk)")
+	resume := flag.Bool("resume", false, "pick a saved session in this directory to reopen")
+	continueFlag := flag.Bool("continue", false, "reopen the most recent saved session in this directory")
+	noSave := flag.Bool("no-save", false, "don't save this session for -resume/-continue")
+	inlineMentions := flag.Bool("inline-mentions", false, "append the contents of small @-mentioned files to the prompt")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration (config files and flags merged) and exit")
+	headless := flag.Bool("p", false, "headless: run the prompt given as arguments (or one per line of stdin), print the replies and exit")
+	outputFormat := flag.String("output-format", OutputRendered, "headless output: rendered, text or json")
+	exportPath := flag.String("export", "", "write the most recent session in this directory to a .md, .html or .json file and exit")
 	flag.Parse()
 
+	if *permissionMCP != "" {
+		if err := RunPermissionMCPServer(*permissionMCP); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	cwd, _ := os.Getwd()
+	cfg, err := LoadConfig(cwd)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := cfg.ApplyArgs(flag.CommandLine); err != nil {
+		log.Fatal(err)
+	}
+	if !validPermMode(*permMode) {
+		log.Fatalf("-perm-mode %q is not one of %s", *permMode, permModeNames())
+	}
+	if !slices.Contains(outputFormats, *outputFormat) {
+		log.Fatalf("-output-format %q is not one of %s", *outputFormat, strings.Join(outputFormats, ", "))
+	}
+	if *printConfig {
+		if err := cfg.PrintConfig(os.Stdout, flag.CommandLine); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *exportPath != "" {
+		if err := exportLatest(*exportPath); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	SetWireLogEnabled(*wireLog)
+	claudeBin = *bin
 
 	m := NewModel()
-	m.chat.interactive = *interactive
+	m.chat.applyConfig(cfg)
+	switch {
+	case *replay != "":
+		rb, err := LoadReplay(*replay, *replaySpeed)
+		if err != nil {
+			log.Fatal(err)
+		}
+		m.chat.backend = rb
+	case *interactive:
+		m.chat.backend = NewInteractiveBackend()
+	case *persistent:
+		m.chat.backend = NewPersistentSession("")
+	}
 	m.chat.permMode = PermissionMode(*permMode)
+	m.chat.model = *model
+	m.chat.fallbackModel = *fallbackModel
+	m.chat.inlineMentions = *inlineMentions
+
+	// Replayed sessions are recordings, not conversations to come back to
+	if *replay == "" {
+		store, err := DefaultSessionStore()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !*noSave {
+			m.chat.store = store
+		}
+		switch {
+		case *continueFlag:
+			sessions, err := FindSessions(store, m.chat.cwd)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if len(sessions) == 0 {
+				log.Printf("no saved session in %s, starting a new one", m.chat.cwd)
+				break
+			}
+			sess, err := OpenSession(store, sessions[0])
+			if err != nil {
+				log.Fatal(err)
+			}
+			m.chat.LoadSession(sess)
+		case *resume:
+			m.picker, err = NewSessionPicker(store, m.chat.cwd)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	// Headless runs have no one to answer permission prompts or pick a session
+	if *headless {
+		if *resume {
+			log.Fatal("-resume needs the TUI to pick a session; use -continue with -p")
+		}
+		prompts, err := headlessPrompts(flag.Args(), os.Stdin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		ok, err := m.chat.runHeadless(prompts, *outputFormat, os.Stdout)
+		m.chat.backend.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if history, err := DefaultPromptHistory(m.chat.cwd); err != nil {
+		log.Printf("prompt history disabled: %v", err)
+	} else {
+		m.chat.history = history
+	}
+
+	if *permPromptFlag {
+		if broker, err := startPermissionPrompts(); err != nil {
+			log.Printf("permission prompts disabled: %v", err)
+		} else {
+			defer broker.Close()
+			m.chat.permRequests = broker.Requests()
+		}
+	}
+
 	p := tea.NewProgram(m)
 	if _, err := p.Run(); err != nil {
 		log.Fatal(err)
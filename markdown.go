@@ -0,0 +1,127 @@
+package main
+
+import "strings"
+
+// Assistant text is rendered with glamour one top-level block at a time, so
+// a streaming answer can show every finished paragraph, list or code fence
+// as markdown while only the block still being written stays raw. Finished
+// text goes through the same chunking, so nothing reflows when a turn ends,
+// and each chunk's rendering is cached for the next refresh.
+
+const markdownCacheMax = 4096 // chunks kept before the cache is reset
+
+// markdownChunks splits markdown at blank lines into blocks that render the
+// same on their own as inside the whole document. Code fences are never
+// split, and list items stay together with their continuation lines.
+func markdownChunks(text string) []string {
+	var chunks []string
+	var cur []string
+	var fence string // opening marker of the fence we're in, e.g. "```"
+	blank := false   // a blank line followed the current block
+
+	flush := func() {
+		for len(cur) > 0 && strings.TrimSpace(cur[len(cur)-1]) == "" {
+			cur = cur[:len(cur)-1]
+		}
+		if len(cur) > 0 {
+			chunks = append(chunks, strings.Join(cur, "\n"))
+		}
+		cur = nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		if fence != "" {
+			cur = append(cur, line)
+			if t := strings.TrimSpace(line); strings.HasPrefix(t, fence) && strings.Trim(t, fence[:1]) == "" {
+				fence = ""
+			}
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			if len(cur) > 0 {
+				blank = true
+				cur = append(cur, line)
+			}
+			continue
+		}
+		indented := strings.HasPrefix(line, "  ") || strings.HasPrefix(line, "\t")
+		if blank && !indented && !(isListItem(cur[0]) && isListItem(line)) {
+			flush()
+		}
+		blank = false
+		if marker := fenceMarker(line); marker != "" {
+			fence = marker
+		}
+		cur = append(cur, line)
+	}
+	flush()
+	return chunks
+}
+
+// fenceMarker returns the ``` or ~~~ run opening a code fence, or "".
+func fenceMarker(line string) string {
+	t := strings.TrimLeft(line, " ")
+	if len(line)-len(t) > 3 {
+		return ""
+	}
+	for _, c := range []string{"`", "~"} {
+		if strings.HasPrefix(t, c+c+c) {
+			return c + c + c + strings.Repeat(c, len(t)-len(strings.TrimLeft(t, c))-3)
+		}
+	}
+	return ""
+}
+
+// isListItem reports whether line starts a bullet or numbered list item.
+func isListItem(line string) bool {
+	t := strings.TrimLeft(line, " ")
+	if len(t) >= 2 && strings.ContainsRune("-*+", rune(t[0])) && t[1] == ' ' {
+		return true
+	}
+	digits := len(t) - len(strings.TrimLeft(t, "0123456789"))
+	return digits > 0 && digits < len(t)-1 && (t[digits] == '.' || t[digits] == ')') && t[digits+1] == ' '
+}
+
+// renderMarkdown renders assistant text block by block. While partial, the
+// last block may still grow and is shown raw, indented like glamour output.
+func (m *ChatModel) renderMarkdown(text string, partial bool) string {
+	if m.renderer == nil {
+		return m.styleText.Render(text)
+	}
+	chunks := markdownChunks(text)
+	parts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		if partial && i == len(chunks)-1 {
+			parts[i] = m.styleText.Render("  " + strings.ReplaceAll(chunk, "\n", "\n  "))
+			continue
+		}
+		parts[i] = m.renderMarkdownChunk(chunk)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// renderMarkdownChunk renders one block with glamour, without the blank
+// lines glamour puts around a document.
+func (m *ChatModel) renderMarkdownChunk(chunk string) string {
+	if out, ok := m.markdownCache[chunk]; ok {
+		return out
+	}
+	rendered, err := m.renderer.Render(chunk)
+	if err != nil {
+		return m.styleText.Render(chunk)
+	}
+	lines := strings.Split(rendered, "\n")
+	for len(lines) > 0 && strings.TrimSpace(stripANSI(lines[0])) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(stripANSI(lines[len(lines)-1])) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	out := strings.Join(lines, "\n")
+
+	if m.markdownCache == nil || len(m.markdownCache) >= markdownCacheMax {
+		m.markdownCache = make(map[string]string)
+	}
+	m.markdownCache[chunk] = out
+	return out
+}
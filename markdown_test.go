@@ -0,0 +1,98 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdownChunks(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "paragraphs", text: "one\nstill one\n\ntwo\n", want: []string{"one\nstill one", "two"}},
+		{name: "partial trailing block", text: "# Title\n\nHalf a sent", want: []string{"# Title", "Half a sent"}},
+		{
+			name: "fence with blank lines",
+			text: "Code:\n\n```go\nfunc a() {}\n\nfunc b() {}\n```\n\nAfter",
+			want: []string{"Code:", "```go\nfunc a() {}\n\nfunc b() {}\n```", "After"},
+		},
+		{
+			name: "unclosed fence",
+			text: "```\nline\n\nmore",
+			want: []string{"```\nline\n\nmore"},
+		},
+		{
+			name: "longer fence needs a longer close",
+			text: "````\n```\n\n````\n\nx",
+			want: []string{"````\n```\n\n````", "x"},
+		},
+		{
+			name: "loose list stays together",
+			text: "1. first\n\n2. second\n   more\n\n   indented para\n\nDone.",
+			want: []string{"1. first\n\n2. second\n   more\n\n   indented para", "Done."},
+		},
+		{name: "leading blank lines", text: "\n\nhi", want: []string{"hi"}},
+		{name: "empty", text: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := markdownChunks(tt.text)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+				t.Errorf("markdownChunks(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsListItem(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"- item", true},
+		{"* item", true},
+		{"  + nested", true},
+		{"12. twelve", true},
+		{"3) three", true},
+		{"-not a list", false},
+		{"2024 was a year", false},
+		{"1.5 is a number", false},
+		{"3.", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			if got := isListItem(tt.line); got != tt.want {
+				t.Errorf("isListItem(%q) = %v, want %v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderMarkdownPartial(t *testing.T) {
+	m := newTestChat(NewPrintBackend())
+	full := "Some **bold** text.\n\n```go\nx := 1\n```\n\nThe end."
+
+	// Mid-stream: finished blocks are rendered, the last one is raw
+	partial := m.renderMarkdown(strings.TrimSuffix(full, "nd."), true)
+	if strings.Contains(partial, "**bold**") || strings.Contains(partial, "```") {
+		t.Errorf("finished blocks should be rendered:\n%s", partial)
+	}
+	if !strings.HasSuffix(stripANSI(partial), "\n\n  The e") {
+		t.Errorf("trailing block should be raw and indented:\n%q", stripANSI(partial))
+	}
+
+	// Once done, the earlier blocks are unchanged, so nothing reflows
+	final := m.renderMarkdown(full, false)
+	head := partial[:strings.LastIndex(partial, "\n\n")]
+	if !strings.HasPrefix(final, head) {
+		t.Errorf("final rendering should extend the streamed one:\nstreamed:\n%s\nfinal:\n%s", partial, final)
+	}
+	if !strings.Contains(stripANSI(final), "The end.") || strings.Contains(final, "**") {
+		t.Errorf("final rendering = %q", stripANSI(final))
+	}
+	if len(m.markdownCache) != 3 {
+		t.Errorf("cache has %d chunks, want the 3 finished blocks", len(m.markdownCache))
+	}
+}
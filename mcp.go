@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// This file implements just enough of the MCP stdio protocol (newline
+// delimited JSON-RPC 2.0) to expose one tool, "approve", which claude calls
+// through --permission-prompt-tool. flawdcode runs it as a child of claude
+// (flawdcode -permission-mcp <socket>) and forwards each call to the TUI.
+
+// mcpRequest is an incoming JSON-RPC request or notification.
+type mcpRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"` // absent for notifications
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// mcpResponse is an outgoing JSON-RPC response.
+type mcpResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *mcpError       `json:"error,omitempty"`
+}
+
+type mcpError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// RunPermissionMCPServer serves the permission tool on stdin/stdout,
+// forwarding calls to the broker on socket. It returns when stdin closes.
+func RunPermissionMCPServer(socket string) error {
+	return serveMCP(os.Stdin, os.Stdout, func(q permissionQuery) (PermissionDecision, error) {
+		return askBroker(socket, q)
+	})
+}
+
+// serveMCP reads JSON-RPC requests from r and writes responses to w.
+// ask decides each approve call.
+func serveMCP(r io.Reader, w io.Writer, ask func(permissionQuery) (PermissionDecision, error)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var req mcpRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			writeMCP(w, mcpResponse{JSONRPC: "2.0", ID: json.RawMessage("null"),
+				Error: &mcpError{Code: -32700, Message: "parse error"}})
+			continue
+		}
+		if len(req.ID) == 0 {
+			continue // notification (e.g. notifications/initialized), no reply
+		}
+		resp := mcpResponse{JSONRPC: "2.0", ID: req.ID}
+		resp.Result, resp.Error = handleMCP(req, ask)
+		writeMCP(w, resp)
+	}
+	return scanner.Err()
+}
+
+// handleMCP dispatches one request by method.
+func handleMCP(req mcpRequest, ask func(permissionQuery) (PermissionDecision, error)) (any, *mcpError) {
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		_ = json.Unmarshal(req.Params, &params)
+		version := params.ProtocolVersion
+		if version == "" {
+			version = "2024-11-05"
+		}
+		return map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": "flawdcode", "version": "0.1.0"},
+		}, nil
+
+	case "ping":
+		return map[string]any{}, nil
+
+	case "tools/list":
+		return map[string]any{"tools": []any{map[string]any{
+			"name":        "approve",
+			"description": "Ask the flawdcode user to approve or deny a tool call.",
+			"inputSchema": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"tool_name":   map[string]any{"type": "string"},
+					"input":       map[string]any{"type": "object"},
+					"tool_use_id": map[string]any{"type": "string"},
+				},
+				"required": []string{"tool_name", "input"},
+			},
+		}}}, nil
+
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments permissionQuery `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &mcpError{Code: -32602, Message: "invalid params: " + err.Error()}
+		}
+		if params.Name != "approve" {
+			return nil, &mcpError{Code: -32602, Message: "unknown tool: " + params.Name}
+		}
+		d, err := ask(params.Arguments)
+		if err != nil {
+			// Fail closed: an unreachable TUI means nobody approved it.
+			return map[string]any{
+				"content": []any{map[string]any{"type": "text", "text": err.Error()}},
+				"isError": true,
+			}, nil
+		}
+		text, _ := json.Marshal(d)
+		return map[string]any{
+			"content": []any{map[string]any{"type": "text", "text": string(text)}},
+		}, nil
+	}
+	return nil, &mcpError{Code: -32601, Message: "method not found: " + req.Method}
+}
+
+func writeMCP(w io.Writer, resp mcpResponse) {
+	line, _ := json.Marshal(resp)
+	fmt.Fprintf(w, "%s\n", line)
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+)
+
+// Typing @ in the input opens a fuzzy finder over the files of the working
+// directory, the way claude's own TUI does. Choosing one inserts "@path"; claude
+// reads mentioned files itself, and with -inline-mentions small ones are also
+// appended to the prompt so the turn doesn't need a Read call.
+
+const (
+	mentionMaxFiles   = 20000            // files listed at most
+	mentionMaxMatches = 50               // matches ranked for the popup
+	mentionInlineMax  = 16 * 1024        // largest file inlined into a prompt
+	mentionRelist     = 10 * time.Second // file list age before a new @ lists again
+)
+
+// mentionFilesMsg carries the file list for the finder.
+type mentionFilesMsg struct {
+	Files []string
+}
+
+// listMentionFilesCmd lists the files under dir in the background.
+func listMentionFilesCmd(dir string) tea.Cmd {
+	return func() tea.Msg {
+		files, err := listMentionFiles(dir)
+		if err != nil {
+			return mentionFilesMsg{}
+		}
+		return mentionFilesMsg{Files: files}
+	}
+}
+
+// listMentionFiles returns the files under dir relative to it, plus their
+// directories with a trailing slash. Git decides what is ignored when dir is
+// in a repository; otherwise dir's .gitignore is applied.
+func listMentionFiles(dir string) ([]string, error) {
+	files, err := gitFiles(dir)
+	if err != nil {
+		files, err = walkFiles(dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(files) > mentionMaxFiles {
+		files = files[:mentionMaxFiles]
+	}
+	dirs := make(map[string]bool)
+	for _, f := range files {
+		for d := path.Dir(f); d != "."; d = path.Dir(d) {
+			dirs[d+"/"] = true
+		}
+	}
+	for d := range dirs {
+		files = append(files, d)
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+// gitFiles lists tracked and untracked files that .gitignore doesn't exclude.
+// Paths are NUL-separated (-z) so git doesn't quote unusual names.
+func gitFiles(dir string) ([]string, error) {
+	cmd := exec.Command("git", "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, f := range strings.Split(string(out), "\x00") {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+// walkFiles lists the files under dir, skipping hidden directories and what
+// the top-level .gitignore excludes.
+func walkFiles(dir string) ([]string, error) {
+	ignore := readGitignore(filepath.Join(dir, ".gitignore"))
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == dir {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, p)
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if strings.HasPrefix(d.Name(), ".") || gitignored(ignore, rel, true) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !gitignored(ignore, rel, false) {
+			files = append(files, rel)
+		}
+		if len(files) >= mentionMaxFiles {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return files, err
+}
+
+// readGitignore returns the patterns of a .gitignore file. Negations are
+// not supported and are dropped.
+func readGitignore(name string) []string {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil
+	}
+	var patterns []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns
+}
+
+// gitignored reports whether rel matches one of the patterns: a pattern with
+// a slash is matched against the whole path, one without against each name
+// in it, and a trailing slash only matches directories.
+func gitignored(patterns []string, rel string, isDir bool) bool {
+	for _, p := range patterns {
+		if strings.HasSuffix(p, "/") {
+			if !isDir {
+				continue
+			}
+			p = strings.TrimSuffix(p, "/")
+		}
+		if strings.Contains(p, "/") {
+			if ok, _ := path.Match(strings.TrimPrefix(p, "/"), rel); ok {
+				return true
+			}
+			continue
+		}
+		if ok, _ := path.Match(p, path.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// fuzzyScore scores path against query, matched case-insensitively as a
+// subsequence; ok is false if it doesn't match. Runs of matched characters,
+// matches at the start of a name or word, and matches in the file name
+// score higher.
+func fuzzyScore(query, p string) (score int, ok bool) {
+	q := []rune(strings.ToLower(query))
+	r := []rune(strings.ToLower(p))
+	base := len([]rune(p)) - len([]rune(path.Base(strings.TrimSuffix(p, "/"))))
+	if strings.HasSuffix(p, "/") {
+		base--
+	}
+	qi, prev := 0, -2
+	for i := 0; i < len(r) && qi < len(q); i++ {
+		if r[i] != q[qi] {
+			continue
+		}
+		score++
+		if i == prev+1 {
+			score += 5
+		}
+		if i == 0 || strings.ContainsRune("/_-. ", r[i-1]) {
+			score += 3
+		}
+		if i >= base {
+			score += 2
+		}
+		prev = i
+		qi++
+	}
+	return score, qi == len(q)
+}
+
+// mentionMatches returns the best matches for query, shortest first on ties.
+func mentionMatches(files []string, query string) []string {
+	type scored struct {
+		path  string
+		score int
+	}
+	var matches []scored
+	for _, f := range files {
+		if s, ok := fuzzyScore(query, f); ok {
+			matches = append(matches, scored{f, s})
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b scored) int {
+		if a.score != b.score {
+			return b.score - a.score
+		}
+		return len(a.path) - len(b.path)
+	})
+	out := make([]string, 0, min(len(matches), mentionMaxMatches))
+	for _, s := range matches[:min(len(matches), mentionMaxMatches)] {
+		out = append(out, s.path)
+	}
+	return out
+}
+
+// activeMention returns the "@query" being typed at the cursor: the row and
+// rune column of the @, and the query after it.
+func (m *ChatModel) activeMention() (row, col int, query string, ok bool) {
+	row = m.textarea.Line()
+	lines := strings.Split(m.textarea.Value(), "\n")
+	if row >= len(lines) {
+		return 0, 0, "", false
+	}
+	li := m.textarea.LineInfo()
+	line := []rune(lines[row])
+	cursor := min(li.StartColumn+li.ColumnOffset, len(line))
+	for i := cursor - 1; i >= 0; i-- {
+		switch {
+		case line[i] == '@' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return row, i, string(line[i+1 : cursor]), true
+		case line[i] == ' ' || line[i] == '\t':
+			return 0, 0, "", false
+		}
+	}
+	return 0, 0, "", false
+}
+
+// mentionPopupMatches returns the files offered for the mention being typed,
+// or nil when the finder is closed.
+func (m *ChatModel) mentionPopupMatches() []string {
+	row, col, query, ok := m.activeMention()
+	if !ok {
+		return nil
+	}
+	key := fmt.Sprintf("%d:%d:%s", row, col, query)
+	if key == m.mentionDismissed {
+		return nil
+	}
+	if key != m.mentionQuery {
+		m.mentionQuery = key
+		m.mentionMatched = mentionMatches(m.mentionFiles, query)
+		m.mentionSel = 0
+	}
+	m.mentionSel = max(0, min(m.mentionSel, len(m.mentionMatched)-1))
+	return m.mentionMatched
+}
+
+// refreshMentionFiles lists the files again when a mention is being typed
+// and the list is missing or old.
+func (m *ChatModel) refreshMentionFiles() tea.Cmd {
+	if _, _, _, ok := m.activeMention(); !ok || m.mentionListing || time.Since(m.mentionListedAt) < mentionRelist {
+		return nil
+	}
+	m.mentionListing = true
+	return listMentionFilesCmd(m.cwd)
+}
+
+// handleMentionKey handles the finder's keys: ↑/↓ select, tab or enter
+// insert the selected path, esc closes the finder. It reports whether the
+// key was consumed.
+func (m *ChatModel) handleMentionKey(msg tea.KeyPressMsg) bool {
+	matches := m.mentionPopupMatches()
+	if len(matches) == 0 {
+		return false
+	}
+	switch msg.String() {
+	case "up", "shift+tab":
+		m.mentionSel = (m.mentionSel + len(matches) - 1) % len(matches)
+	case "down":
+		m.mentionSel = (m.mentionSel + 1) % len(matches)
+	case "tab", "enter":
+		_, _, query, _ := m.activeMention()
+		for range []rune(query) {
+			m.textarea, _ = m.textarea.Update(tea.KeyPressMsg{Code: tea.KeyBackspace})
+		}
+		chosen := matches[m.mentionSel]
+		if !strings.HasSuffix(chosen, "/") {
+			chosen += " " // a directory stays open to pick a file in it
+		}
+		m.textarea.InsertString(chosen)
+	case "esc":
+		m.mentionDismissed = m.mentionQuery
+	default:
+		return false
+	}
+	return true
+}
+
+// renderMentionPopup renders the file finder shown above the input.
+func (m *ChatModel) renderMentionPopup(width int) string {
+	var rows []popupRow
+	for _, f := range m.mentionPopupMatches() {
+		rows = append(rows, popupRow{label: "@" + f})
+	}
+	return m.renderPopup(width, rows, m.mentionSel, "↑↓ select · tab insert · esc close")
+}
+
+var mentionRe = regexp.MustCompile(`(?:^|\s)@(\S+)`)
+
+// inlineMentions appends the contents of the small text files mentioned in
+// prompt, so claude has them without reading them.
+func inlineMentions(prompt, dir string) string {
+	var sb strings.Builder
+	sb.WriteString(prompt)
+	seen := make(map[string]bool)
+	for _, match := range mentionRe.FindAllStringSubmatch(prompt, -1) {
+		name := strings.TrimRight(match[1], ".,;:!?)\"'")
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		p := name
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+		info, err := os.Stat(p)
+		if err != nil || !info.Mode().IsRegular() || info.Size() > mentionInlineMax {
+			continue
+		}
+		data, err := os.ReadFile(p)
+		if err != nil || bytes.IndexByte(data, 0) >= 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "\n\n<file path=%q>\n%s\n</file>", name, strings.TrimRight(string(data), "\n"))
+	}
+	return sb.String()
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	tea "charm.land/bubbletea/v2"
+)
+
+// writeTree creates files (path → content) under dir.
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+var mentionTree = map[string]string{
+	".gitignore":          "build/\n*.log\n/secret.txt\n",
+	"main.go":             "package main\n",
+	"chat.go":             "package main\n",
+	"internal/chat/ui.go": "package chat\n",
+	"build/out.bin":       "x",
+	"debug.log":           "x",
+	"secret.txt":          "x",
+	"docs/secret.txt":     "x",
+	".hidden/config":      "x",
+}
+
+func TestListMentionFiles(t *testing.T) {
+	want := ".gitignore,chat.go,docs/,docs/secret.txt,internal/,internal/chat/,internal/chat/ui.go,main.go"
+
+	t.Run("walk", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTree(t, dir, mentionTree)
+		files, err := listMentionFiles(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(files, ","); got != want {
+			t.Errorf("files = %s\nwant    %s", got, want)
+		}
+	})
+
+	t.Run("git", func(t *testing.T) {
+		if _, err := exec.LookPath("git"); err != nil {
+			t.Skip("git not installed")
+		}
+		dir := t.TempDir()
+		writeTree(t, dir, mentionTree)
+		if out, err := exec.Command("git", "-C", dir, "init", "-q").CombinedOutput(); err != nil {
+			t.Fatalf("git init: %v\n%s", err, out)
+		}
+		files, err := listMentionFiles(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// git lists hidden files that aren't ignored
+		if got := strings.Join(files, ","); got != strings.Replace(want, ".gitignore,", ".gitignore,.hidden/,.hidden/config,", 1) {
+			t.Errorf("files = %s", got)
+		}
+
+		// Names git would C-quote come through as they are
+		writeTree(t, dir, map[string]string{"docs/café \"notes\".md": "x"})
+		if files, err = listMentionFiles(dir); err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Contains(files, "docs/café \"notes\".md") {
+			t.Errorf("files = %q", files)
+		}
+	})
+}
+
+func TestMentionMatches(t *testing.T) {
+	files := []string{"chat.go", "chat_test.go", "internal/", "internal/chat/", "internal/chat/ui.go", "cmd/mousetest/main.go", "main.go"}
+	tests := []struct {
+		query string
+		want  string // best match
+	}{
+		{"", "chat.go"},
+		{"main", "main.go"},
+		{"ui", "internal/chat/ui.go"},
+		{"chtest", "chat_test.go"},
+		{"int/ch", "internal/chat/"},
+		{"MAIN.GO", "main.go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got := mentionMatches(files, tt.query)
+			if len(got) == 0 || got[0] != tt.want {
+				t.Errorf("mentionMatches(%q) = %v, want %q first", tt.query, got, tt.want)
+			}
+		})
+	}
+	if got := mentionMatches(files, "zzz"); len(got) != 0 {
+		t.Errorf("no file should match zzz, got %v", got)
+	}
+}
+
+func TestMentionFinder(t *testing.T) {
+	m := newTestChat(NewPrintBackend())
+	m.cwd = t.TempDir()
+	writeTree(t, m.cwd, mentionTree)
+	m.textarea.Focus()
+
+	// Typing @ lists the files in the background
+	typeText(m, "look at ")
+	if cmd := m.Update(tea.KeyPressMsg{Code: '@', Text: "@"}); cmd == nil {
+		t.Fatal("@ should start listing files")
+	}
+	files, _ := listMentionFiles(m.cwd)
+	m.Update(mentionFilesMsg{Files: files})
+	typeText(m, "ui")
+	if view := stripANSI(m.View()); !strings.Contains(view, "@internal/chat/ui.go") {
+		t.Fatalf("finder should offer ui.go:\n%s", view)
+	}
+
+	m.Update(tea.KeyPressMsg{Code: tea.KeyTab})
+	if got := m.textarea.Value(); got != "look at @internal/chat/ui.go " {
+		t.Errorf("after tab input = %q", got)
+	}
+	if len(m.mentionPopupMatches()) != 0 {
+		t.Error("finder should close after inserting a file")
+	}
+
+	// A directory keeps the finder open inside it; esc closes it
+	typeText(m, "and @int")
+	m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	if got := m.textarea.Value(); !strings.HasSuffix(got, "@internal/") || len(m.entries) != 0 {
+		t.Errorf("enter should insert the directory, input %q", got)
+	}
+	m.Update(tea.KeyPressMsg{Code: tea.KeyEscape})
+	if len(m.mentionPopupMatches()) != 0 || m.scrollMode {
+		t.Error("esc should close the finder without entering scroll mode")
+	}
+
+	// An @ inside a word is not a mention
+	m.textarea.SetValue("mail me@exa")
+	if len(m.mentionPopupMatches()) != 0 {
+		t.Error("an address is not a mention")
+	}
+}
+
+func TestInlineMentions(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"main.go":  "package main\n",
+		"big.txt":  strings.Repeat("x", mentionInlineMax+1),
+		"blob.bin": "a\x00b",
+	})
+	got := inlineMentions("fix @main.go, not @big.txt @blob.bin @missing.go or @main.go again", dir)
+	if strings.Count(got, "<file") != 1 || !strings.Contains(got, "<file path=\"main.go\">\npackage main\n</file>") {
+		t.Errorf("inlineMentions = %q", got)
+	}
+}
@@ -1,10 +1,6 @@
 package main
 
-import (
-	"os/exec"
-
-	tea "charm.land/bubbletea/v2"
-)
+import tea "charm.land/bubbletea/v2"
 
 // ClaudeResponseMsg carries the result of a one-shot claude invocation.
 type ClaudeResponseMsg struct {
@@ -13,12 +9,10 @@ type ClaudeResponseMsg struct {
 	Err      error
 }
 
-// ClaudeStreamStartMsg is sent when StreamClaude starts, carries the channel.
+// ClaudeStreamStartMsg is sent when a backend starts a turn, carries the channel.
 type ClaudeStreamStartMsg struct {
 	Prompt string
 	Ch     <-chan StreamMsg
-	// Cmd is the underlying exec.Cmd, available for cancellation.
-	Cmd *exec.Cmd
 }
 
 // ClaudeStreamChunkMsg carries one event during streaming.
@@ -36,42 +30,32 @@ type ClaudeStreamDoneMsg struct {
 	Err      error
 }
 
-// StreamMsg is the internal channel type (not a tea.Msg).
-type StreamMsg struct {
-	Event    *StreamEvent
-	Done     bool
-	Response *ClaudeResponse
-	Err      error
-}
-
-// InteractiveStartMsg is sent when an interactive session starts successfully.
-type InteractiveStartMsg struct {
-	Session *InteractiveSession
-}
-
-// interactiveStreamStartMsg is sent when a prompt has been sent to the interactive session.
-type interactiveStreamStartMsg struct {
-	ch <-chan string
-}
-
-// InteractiveChunkMsg carries a text chunk from the interactive PTY session.
-type InteractiveChunkMsg struct {
-	Text string
+// scriptedPromptMsg submits a prompt supplied by a promptSource backend.
+type scriptedPromptMsg struct {
+	Prompt string
 }
 
-// InteractiveDoneMsg signals the interactive response is complete.
-type InteractiveDoneMsg struct {
-	Err error
+// PermissionRequestMsg is sent when claude asks the user to approve a tool call.
+type PermissionRequestMsg struct {
+	Req *PermissionRequest
 }
 
-// waitForInteractiveMsg returns a tea.Cmd that reads one text chunk from the
-// interactive session channel and converts it to the appropriate tea.Msg.
-func waitForInteractiveMsg(ch <-chan string) tea.Cmd {
+// waitForPermissionRequest returns a tea.Cmd that waits for the next
+// permission request from the broker.
+func waitForPermissionRequest(ch <-chan *PermissionRequest) tea.Cmd {
 	return func() tea.Msg {
-		text, ok := <-ch
+		req, ok := <-ch
 		if !ok {
-			return InteractiveDoneMsg{}
+			return nil
 		}
-		return InteractiveChunkMsg{Text: text}
+		return PermissionRequestMsg{Req: req}
 	}
 }
+
+// StreamMsg is the internal channel type emitted by every Backend (not a tea.Msg).
+type StreamMsg struct {
+	Event    *StreamEvent
+	Done     bool
+	Response *ClaudeResponse
+	Err      error
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"os/exec"
+	"strings"
 	"syscall"
 	"time"
 
@@ -28,6 +29,7 @@ type Model struct {
 	width  int
 	height int
 	chat   *ChatModel
+	picker *SessionPicker // shown instead of the chat until a session is picked
 }
 
 // NewModel creates the root model.
@@ -44,14 +46,8 @@ func (m Model) Init() tea.Cmd {
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyPressMsg:
-		switch msg.String() {
-		case "ctrl+c", "ctrl+q":
-			if m.chat.streamCmd != nil {
-				gracefulKill(m.chat.streamCmd)
-			}
-			if m.chat.iSession != nil {
-				m.chat.iSession.Close()
-			}
+		if k := msg.String(); k == "ctrl+c" || k == m.chat.keys.Quit {
+			m.chat.backend.Close()
 			return m, tea.Quit
 		}
 
@@ -60,6 +56,21 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.height = msg.Height
 		m.chat.SetSize(m.width, m.height)
 		return m, m.chat.textarea.Focus()
+
+	case tea.BackgroundColorMsg:
+		// The chat's theme follows it even while the picker is open
+		return m, m.chat.Update(msg)
+
+	case sessionPickedMsg:
+		m.picker = nil
+		if msg.Session != nil {
+			m.chat.LoadSession(msg.Session)
+		}
+		return m, nil
+	}
+
+	if m.picker != nil {
+		return m, m.picker.Update(msg)
 	}
 
 	cmd := m.chat.Update(msg)
@@ -74,7 +85,12 @@ func (m Model) View() tea.View {
 		return v
 	}
 
-	v := tea.NewView(m.chat.View())
+	content := m.chat.View()
+	if m.picker != nil {
+		pad := strings.Repeat(" ", m.chat.padH)
+		content = pad + strings.ReplaceAll(m.picker.View(m.width-m.chat.padH*2, m.height-1, m.chat.palette()), "\n", "\n"+pad)
+	}
+	v := tea.NewView(content)
 	v.AltScreen = true
 	v.MouseMode = tea.MouseModeCellMotion
 	return v
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"slices"
+	"strings"
+
+	tea "charm.land/bubbletea/v2"
+)
+
+// The model for the next turns is set with -model or "/model", which opens
+// a picker over claude's aliases. Turns resume the same session, so switching
+// keeps the conversation, except in -interactive mode where claude has to be
+// started over. When the model that answered differs from the one
+// asked for (a fallback, or claude resolving an alias unexpectedly) the header
+// shows both.
+
+// modelChoice is an entry of the /model picker.
+type modelChoice struct {
+	Name        string // --model value, empty for claude's default
+	Description string
+}
+
+// modelChoices are the aliases claude accepts for --model.
+var modelChoices = []modelChoice{
+	{"", "Claude's default model"},
+	{"opus", "Most capable, for complex work"},
+	{"sonnet", "Fast and capable, for everyday work"},
+	{"haiku", "Fastest, for quick answers"},
+	{"opusplan", "Opus in plan mode, Sonnet otherwise"},
+	{"sonnet[1m]", "Sonnet with a 1M token context window"},
+}
+
+// pickerModels returns the picker's choices, with the current model added
+// when it isn't one of the aliases.
+func (m *ChatModel) pickerModels() []modelChoice {
+	choices := slices.Clone(modelChoices)
+	if !slices.ContainsFunc(choices, func(c modelChoice) bool { return c.Name == m.model }) {
+		choices = append(choices, modelChoice{m.model, "Set with -model or /model"})
+	}
+	return choices
+}
+
+// openModelPicker shows the /model picker with the current model selected.
+func (m *ChatModel) openModelPicker() {
+	m.modelPicking = true
+	m.modelSel = slices.IndexFunc(m.pickerModels(), func(c modelChoice) bool { return c.Name == m.model })
+}
+
+// setModel switches the model for the next turns. The interactive backend
+// can only switch by starting claude over, so the notice says so.
+func (m *ChatModel) setModel(name string) {
+	m.model = name
+	label := name
+	if name == "" {
+		label = "claude's default"
+	}
+	text := "Model set to " + label + " for the next turns"
+	if _, ok := m.backend.(*InteractiveBackend); ok {
+		text += "; the interactive session restarts without the conversation so far"
+	}
+	m.addNotice("notice", text)
+}
+
+// handleModelPickerKey handles the picker's keys: ↑/↓ select, enter
+// switches to the selected model, esc closes the picker.
+func (m *ChatModel) handleModelPickerKey(msg tea.KeyPressMsg) tea.Cmd {
+	choices := m.pickerModels()
+	switch msg.String() {
+	case "up", "shift+tab":
+		m.modelSel = (m.modelSel + len(choices) - 1) % len(choices)
+	case "down", "tab":
+		m.modelSel = (m.modelSel + 1) % len(choices)
+	case "enter":
+		m.modelPicking = false
+		m.setModel(choices[m.modelSel].Name)
+	case "esc":
+		m.modelPicking = false
+	}
+	return nil
+}
+
+// renderModelPicker renders the /model picker shown above the input.
+func (m *ChatModel) renderModelPicker(width int) string {
+	var rows []popupRow
+	for _, c := range m.pickerModels() {
+		label := c.Name
+		if label == "" {
+			label = "default"
+		}
+		if c.Name == m.model {
+			label += " ✓"
+		}
+		rows = append(rows, popupRow{label: label, desc: c.Description})
+	}
+	return m.renderPopup(width, rows, m.modelSel, "↑↓ select · enter switch · esc close")
+}
+
+// modelSatisfies reports whether actual, the model id a turn reported, is
+// what requested (an alias or a model name) asks for.
+func modelSatisfies(requested, actual string) bool {
+	if requested == "" || actual == "" {
+		return true
+	}
+	requested, _, _ = strings.Cut(strings.ToLower(requested), "[")
+	actual = strings.ToLower(actual)
+	if requested == "opusplan" {
+		return strings.Contains(actual, "opus") || strings.Contains(actual, "sonnet")
+	}
+	return strings.HasPrefix(actual, requested) || strings.Contains(actual, "-"+requested)
+}
+
+// modelMismatch returns "requested → actual" when the last finished turn
+// was answered by another model than the one asked for, or "".
+func (m *ChatModel) modelMismatch() string {
+	if modelSatisfies(m.lastRequestedModel, m.lastModel) {
+		return ""
+	}
+	return m.lastRequestedModel + " → " + m.lastModel
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tea "charm.land/bubbletea/v2"
+)
+
+func TestModelSatisfies(t *testing.T) {
+	tests := []struct {
+		requested, actual string
+		want              bool
+	}{
+		{"", "claude-opus-4-6", true},
+		{"opus", "claude-opus-4-6", true},
+		{"sonnet", "claude-sonnet-4-5-20250929", true},
+		{"sonnet[1m]", "claude-sonnet-4-5-20250929", true},
+		{"opusplan", "claude-sonnet-4-5-20250929", true},
+		{"claude-opus-4-1", "claude-opus-4-1-20250805", true},
+		{"opus", "claude-sonnet-4-5-20250929", false},
+		{"haiku", "claude-opus-4-6", false},
+		{"opus", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.requested+"/"+tt.actual, func(t *testing.T) {
+			if got := modelSatisfies(tt.requested, tt.actual); got != tt.want {
+				t.Errorf("modelSatisfies(%q, %q) = %v, want %v", tt.requested, tt.actual, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestModelPicker(t *testing.T) {
+	m := newTestChat(NewPrintBackend())
+	m.model = "claude-opus-4-1"
+
+	m.modelCommand("")
+	view := stripANSI(m.View())
+	for _, want := range []string{"default", "opus", "haiku", "claude-opus-4-1 ✓", "enter switch"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("picker missing %q:\n%s", want, view)
+		}
+	}
+
+	// The current model is selected; ↓ wraps to the default, ↓ again is opus
+	m.Update(tea.KeyPressMsg{Code: tea.KeyDown})
+	m.Update(tea.KeyPressMsg{Code: tea.KeyDown})
+	m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	if m.modelPicking || m.model != "opus" {
+		t.Errorf("after enter: picking=%v model=%q, want opus", m.modelPicking, m.model)
+	}
+
+	m.modelCommand("")
+	m.Update(tea.KeyPressMsg{Code: tea.KeyUp})
+	m.Update(tea.KeyPressMsg{Code: tea.KeyEscape})
+	if m.modelPicking || m.model != "opus" {
+		t.Errorf("esc should close without switching: picking=%v model=%q", m.modelPicking, m.model)
+	}
+}
+
+func TestModelMismatchHeader(t *testing.T) {
+	m := newTestChat(NewPrintBackend())
+	sonnet := &ClaudeResponse{Model: "claude-sonnet-4-5-20250929"}
+
+	m.turnModel = "sonnet"
+	m.updateSessionStats(sonnet)
+	if header := stripANSI(m.renderHeaderCard(96)); strings.Contains(header, "→") {
+		t.Errorf("header shows a mismatch for the requested model: %q", header)
+	}
+	// A turn asking for opus is running: the last answer was still right
+	m.turnModel = "opus"
+	if header := stripANSI(m.renderHeaderCard(96)); strings.Contains(header, "→") {
+		t.Errorf("header shows a mismatch before the opus turn finished: %q", header)
+	}
+	m.updateSessionStats(sonnet)
+	if header := stripANSI(m.renderHeaderCard(96)); !strings.Contains(header, "opus → claude-sonnet-4-5-20250929") {
+		t.Errorf("header should show requested and actual model: %q", header)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// permissionToolName is the MCP tool claude calls (via --permission-prompt-tool)
+// whenever a tool use needs approval.
+const permissionToolName = "mcp__flawdcode__approve"
+
+// permissionQuery is what claude passes to the permission prompt tool.
+type permissionQuery struct {
+	ToolName  string          `json:"tool_name"`
+	Input     json.RawMessage `json:"input"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+}
+
+// PermissionDecision is the permission prompt tool's answer, in the shape the
+// CLI expects: allow (with the possibly updated input) or deny (with a message).
+type PermissionDecision struct {
+	Behavior     string          `json:"behavior"` // "allow" or "deny"
+	UpdatedInput json.RawMessage `json:"updatedInput,omitempty"`
+	Message      string          `json:"message,omitempty"`
+}
+
+// allowDecision approves a tool call with its input unchanged.
+func allowDecision(q permissionQuery) PermissionDecision {
+	input := q.Input
+	if len(input) == 0 {
+		input = json.RawMessage("{}")
+	}
+	return PermissionDecision{Behavior: "allow", UpdatedInput: input}
+}
+
+// denyDecision rejects a tool call; message is shown to Claude.
+func denyDecision(message string) PermissionDecision {
+	if message == "" {
+		message = "The user denied this tool call."
+	}
+	return PermissionDecision{Behavior: "deny", Message: message}
+}
+
+// PermissionRequest is a pending approval surfaced to the TUI.
+type PermissionRequest struct {
+	permissionQuery
+	reply chan PermissionDecision
+	once  sync.Once
+}
+
+// Respond answers the request. Only the first call has any effect.
+func (r *PermissionRequest) Respond(d PermissionDecision) {
+	r.once.Do(func() { r.reply <- d })
+}
+
+// PermissionBroker listens on a unix socket for queries from the
+// permission MCP shim (flawdcode -permission-mcp) that claude spawns, and
+// hands them to the TUI as PermissionRequests.
+type PermissionBroker struct {
+	dir       string // private directory holding the socket
+	socket    string
+	ln        net.Listener
+	requests  chan *PermissionRequest
+	done      chan struct{} // closed by Close
+	closeOnce sync.Once
+}
+
+// StartPermissionBroker creates the socket, in a directory only this user
+// can enter, and starts accepting queries.
+func StartPermissionBroker() (*PermissionBroker, error) {
+	dir, err := os.MkdirTemp("", "flawdcode-perm-")
+	if err != nil {
+		return nil, fmt.Errorf("permission broker: %w", err)
+	}
+	socket := filepath.Join(dir, "perm.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, fmt.Errorf("permission broker: %w", err)
+	}
+	b := &PermissionBroker{
+		dir:      dir,
+		socket:   socket,
+		ln:       ln,
+		requests: make(chan *PermissionRequest),
+		done:     make(chan struct{}),
+	}
+	go b.acceptLoop()
+	return b, nil
+}
+
+// Requests returns the channel of approvals waiting on the user.
+func (b *PermissionBroker) Requests() <-chan *PermissionRequest {
+	return b.requests
+}
+
+// Socket returns the path the shim should connect to.
+func (b *PermissionBroker) Socket() string {
+	return b.socket
+}
+
+// Close stops listening and removes the socket. Pending queries are
+// denied.
+func (b *PermissionBroker) Close() error {
+	var err error
+	b.closeOnce.Do(func() {
+		close(b.done)
+		err = b.ln.Close()
+		_ = os.RemoveAll(b.dir)
+	})
+	return err
+}
+
+func (b *PermissionBroker) acceptLoop() {
+	for {
+		conn, err := b.ln.Accept()
+		if err != nil {
+			return // listener closed
+		}
+		go b.handle(conn)
+	}
+}
+
+// handle serves one query per connection: a JSON line in, a JSON line out.
+func (b *PermissionBroker) handle(conn net.Conn) {
+	defer conn.Close()
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return
+	}
+	var q permissionQuery
+	if err := json.Unmarshal(line, &q); err != nil {
+		return
+	}
+	req := &PermissionRequest{permissionQuery: q, reply: make(chan PermissionDecision, 1)}
+	d := denyDecision("flawdcode closed before the user answered.")
+	select {
+	case b.requests <- req:
+		select {
+		case d = <-req.reply:
+		case <-b.done:
+		}
+	case <-b.done:
+	}
+	out, _ := json.Marshal(d)
+	fmt.Fprintf(conn, "%s\n", out)
+}
+
+// askBroker sends q to the broker listening on socket and waits for the
+// user's decision. Used by the MCP shim.
+func askBroker(socket string, q permissionQuery) (PermissionDecision, error) {
+	conn, err := net.DialTimeout("unix", socket, 5*time.Second)
+	if err != nil {
+		return PermissionDecision{}, fmt.Errorf("connect to flawdcode: %w", err)
+	}
+	defer conn.Close()
+
+	line, _ := json.Marshal(q)
+	if _, err := fmt.Fprintf(conn, "%s\n", line); err != nil {
+		return PermissionDecision{}, fmt.Errorf("send query: %w", err)
+	}
+	resp, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return PermissionDecision{}, fmt.Errorf("read decision: %w", err)
+	}
+	var d PermissionDecision
+	if err := json.Unmarshal(resp, &d); err != nil {
+		return PermissionDecision{}, fmt.Errorf("decode decision: %w", err)
+	}
+	return d, nil
+}
+
+// permPrompt holds the shim command claude should launch for permission
+// prompts. Empty when prompts are disabled.
+var permPrompt struct {
+	exe    string
+	socket string
+}
+
+// EnablePermissionPrompts makes every claude invocation route permission
+// checks to the broker on socket, through the flawdcode binary at exe.
+func EnablePermissionPrompts(exe, socket string) {
+	permPrompt.exe = exe
+	permPrompt.socket = socket
+}
+
+// startPermissionPrompts starts a broker and points claude at this binary
+// as the permission prompt MCP server.
+func startPermissionPrompts() (*PermissionBroker, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	broker, err := StartPermissionBroker()
+	if err != nil {
+		return nil, err
+	}
+	EnablePermissionPrompts(exe, broker.Socket())
+	return broker, nil
+}
+
+// permissionPromptArgs returns the claude CLI args wiring up the permission
+// MCP shim, or nil if prompts are disabled.
+func permissionPromptArgs() []string {
+	if permPrompt.socket == "" {
+		return nil
+	}
+	cfg, _ := json.Marshal(map[string]any{
+		"mcpServers": map[string]any{
+			"flawdcode": map[string]any{
+				"command": permPrompt.exe,
+				"args":    []string{"-permission-mcp", permPrompt.socket},
+			},
+		},
+	})
+	return []string{"--mcp-config", string(cfg), "--permission-prompt-tool", permissionToolName}
+}
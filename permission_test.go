@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+)
+
+func TestPermissionBrokerRoundTrip(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	broker, err := StartPermissionBroker()
+	if err != nil {
+		t.Fatalf("StartPermissionBroker: %v", err)
+	}
+	defer broker.Close()
+
+	go func() {
+		req := <-broker.Requests()
+		if req.ToolName != "Bash" {
+			req.Respond(denyDecision("wrong tool " + req.ToolName))
+			return
+		}
+		req.Respond(allowDecision(req.permissionQuery))
+	}()
+
+	q := permissionQuery{ToolName: "Bash", Input: json.RawMessage(`{"command":"ls"}`), ToolUseID: "toolu_1"}
+	d, err := askBroker(broker.Socket(), q)
+	if err != nil {
+		t.Fatalf("askBroker: %v", err)
+	}
+	if d.Behavior != "allow" || string(d.UpdatedInput) != `{"command":"ls"}` {
+		t.Errorf("decision = %+v, want allow with original input", d)
+	}
+}
+
+func TestPermissionBrokerClose(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	broker, err := StartPermissionBroker()
+	if err != nil {
+		t.Fatalf("StartPermissionBroker: %v", err)
+	}
+	dir := filepath.Dir(broker.Socket())
+	if fi, err := os.Stat(dir); err != nil || fi.Mode().Perm() != 0o700 {
+		t.Fatalf("socket dir %s should be private: %v %v", dir, fi.Mode(), err)
+	}
+
+	// The TUI never answers: Close must still answer the shim
+	type answer struct {
+		d   PermissionDecision
+		err error
+	}
+	answers := make(chan answer, 1)
+	go func() {
+		d, err := askBroker(broker.Socket(), permissionQuery{ToolName: "Bash"})
+		answers <- answer{d, err}
+	}()
+	<-broker.Requests()
+	broker.Close()
+	select {
+	case a := <-answers:
+		if a.err != nil || a.d.Behavior != "deny" {
+			t.Errorf("decision = %+v, %v; want deny", a.d, a.err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("shim still waiting after Close")
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Close should remove %s: %v", dir, err)
+	}
+}
+
+func TestAskBrokerUnreachable(t *testing.T) {
+	_, err := askBroker(t.TempDir()+"/missing.sock", permissionQuery{ToolName: "Bash"})
+	if err == nil {
+		t.Error("expected an error for a missing socket")
+	}
+}
+
+func TestServeMCP(t *testing.T) {
+	in := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"approve","arguments":{"tool_name":"Write","input":{"file_path":"/tmp/x"}}}}`,
+		`{"jsonrpc":"2.0","id":"four","method":"bogus"}`,
+	}, "\n")
+
+	var asked []permissionQuery
+	var out strings.Builder
+	err := serveMCP(strings.NewReader(in), &out, func(q permissionQuery) (PermissionDecision, error) {
+		asked = append(asked, q)
+		return denyDecision("no writes"), nil
+	})
+	if err != nil {
+		t.Fatalf("serveMCP: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d responses, want 4 (notification unanswered):\n%s", len(lines), out.String())
+	}
+
+	var init struct {
+		Result struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		} `json:"result"`
+	}
+	json.Unmarshal([]byte(lines[0]), &init)
+	if init.Result.ProtocolVersion != "2025-06-18" {
+		t.Errorf("initialize should echo the client version: %s", lines[0])
+	}
+	if !strings.Contains(lines[1], `"name":"approve"`) {
+		t.Errorf("tools/list should list approve: %s", lines[1])
+	}
+
+	var call struct {
+		ID     int `json:"id"`
+		Result struct {
+			Content []struct {
+				Text string `json:"text"`
+			} `json:"content"`
+		} `json:"result"`
+	}
+	json.Unmarshal([]byte(lines[2]), &call)
+	if call.ID != 3 || len(call.Result.Content) != 1 {
+		t.Fatalf("bad tools/call response: %s", lines[2])
+	}
+	var d PermissionDecision
+	json.Unmarshal([]byte(call.Result.Content[0].Text), &d)
+	if d.Behavior != "deny" || d.Message != "no writes" {
+		t.Errorf("decision = %+v, want deny 'no writes'", d)
+	}
+	if len(asked) != 1 || asked[0].ToolName != "Write" {
+		t.Errorf("ask called with %+v", asked)
+	}
+
+	if !strings.Contains(lines[3], `"id":"four"`) || !strings.Contains(lines[3], "-32601") {
+		t.Errorf("unknown method should return method-not-found: %s", lines[3])
+	}
+}
+
+func TestPermissionPromptArgs(t *testing.T) {
+	old := permPrompt
+	t.Cleanup(func() { permPrompt = old })
+
+	EnablePermissionPrompts("", "")
+	if args := buildClaudeCmd(TurnRequest{Prompt: "hi", PermMode: PermPlan}).Args; slices.Contains(args, "--permission-prompt-tool") {
+		t.Errorf("prompt tool should be absent when disabled: %v", args)
+	}
+
+	EnablePermissionPrompts("/bin/flawdcode", "/tmp/perm.sock")
+	args := buildClaudeCmd(TurnRequest{Prompt: "hi", PermMode: PermPlan}).Args
+	idx := slices.Index(args, "--permission-prompt-tool")
+	if idx < 0 || args[idx+1] != permissionToolName {
+		t.Fatalf("--permission-prompt-tool missing: %v", args)
+	}
+	if args[len(args)-1] != "hi" {
+		t.Errorf("prompt must stay the last arg: %v", args)
+	}
+	cfgIdx := slices.Index(args, "--mcp-config")
+	if cfgIdx < 0 {
+		t.Fatalf("--mcp-config missing: %v", args)
+	}
+	var cfg struct {
+		MCPServers map[string]struct {
+			Command string   `json:"command"`
+			Args    []string `json:"args"`
+		} `json:"mcpServers"`
+	}
+	if err := json.Unmarshal([]byte(args[cfgIdx+1]), &cfg); err != nil {
+		t.Fatalf("bad --mcp-config JSON: %v", err)
+	}
+	srv := cfg.MCPServers["flawdcode"]
+	if srv.Command != "/bin/flawdcode" || !slices.Equal(srv.Args, []string{"-permission-mcp", "/tmp/perm.sock"}) {
+		t.Errorf("mcp server = %+v", srv)
+	}
+
+	if !slices.Contains(buildPersistentCmd(TurnRequest{}).Args, "--permission-prompt-tool") {
+		t.Error("persistent command should also wire up the prompt tool")
+	}
+}
+
+// newPermissionRequest returns a request and a function reading its decision.
+func newPermissionRequest(tool, input string) (*PermissionRequest, func() PermissionDecision) {
+	req := &PermissionRequest{
+		permissionQuery: permissionQuery{ToolName: tool, Input: json.RawMessage(input)},
+		reply:           make(chan PermissionDecision, 1),
+	}
+	return req, func() PermissionDecision {
+		select {
+		case d := <-req.reply:
+			return d
+		case <-time.After(time.Second):
+			return PermissionDecision{}
+		}
+	}
+}
+
+func TestChatPermissionModal(t *testing.T) {
+	m := newTestChat(NewPrintBackend())
+	key := func(s string) tea.KeyPressMsg {
+		if s == "enter" {
+			return tea.KeyPressMsg{Code: tea.KeyEnter}
+		}
+		return tea.KeyPressMsg{Code: rune(s[0]), Text: s}
+	}
+
+	t.Run("allow once", func(t *testing.T) {
+		req, decision := newPermissionRequest("Bash", `{"command":"rm -rf build"}`)
+		m.Update(PermissionRequestMsg{Req: req})
+		view := m.View()
+		for _, want := range []string{"Permission required", "rm -rf build", "allow once"} {
+			if !strings.Contains(view, want) {
+				t.Errorf("modal missing %q:\n%s", want, view)
+			}
+		}
+		m.Update(key("a"))
+		if d := decision(); d.Behavior != "allow" {
+			t.Errorf("decision = %+v, want allow", d)
+		}
+		if len(m.permQueue) != 0 || m.permAlways["Bash"] {
+			t.Error("allow once should pop the prompt without remembering the tool")
+		}
+	})
+
+	t.Run("deny with message", func(t *testing.T) {
+		req, decision := newPermissionRequest("Write", `{"file_path":"/etc/hosts"}`)
+		m.Update(PermissionRequestMsg{Req: req})
+		m.Update(key("d"))
+		for _, r := range "not there" {
+			m.Update(tea.KeyPressMsg{Code: r, Text: string(r)})
+		}
+		m.Update(key("enter"))
+		if d := decision(); d.Behavior != "deny" || d.Message != "not there" {
+			t.Errorf("decision = %+v, want deny 'not there'", d)
+		}
+		if m.textarea.Value() != "" {
+			t.Errorf("keys leaked into the textarea: %q", m.textarea.Value())
+		}
+	})
+
+	t.Run("allow always", func(t *testing.T) {
+		first, d1 := newPermissionRequest("Edit", `{}`)
+		second, d2 := newPermissionRequest("Edit", `{}`)
+		m.Update(PermissionRequestMsg{Req: first})
+		m.Update(PermissionRequestMsg{Req: second})
+		m.Update(key("A"))
+		if d1().Behavior != "allow" || d2().Behavior != "allow" {
+			t.Error("allow always should answer queued requests for the same tool")
+		}
+		third, d3 := newPermissionRequest("Edit", `{}`)
+		m.Update(PermissionRequestMsg{Req: third})
+		if len(m.permQueue) != 0 || d3().Behavior != "allow" {
+			t.Error("later requests for the tool should be allowed without a prompt")
+		}
+	})
+
+	t.Run("turn end denies leftovers", func(t *testing.T) {
+		req, decision := newPermissionRequest("Bash", `{}`)
+		m.Update(PermissionRequestMsg{Req: req})
+		m.Update(ClaudeStreamDoneMsg{Err: errors.New("cancelled")})
+		if d := decision(); d.Behavior != "deny" || len(m.permQueue) != 0 {
+			t.Errorf("decision = %+v, queue = %d", d, len(m.permQueue))
+		}
+	})
+}
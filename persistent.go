@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// PersistentSession manages a single long-lived claude process running with
+// --input-format stream-json. Prompts are written to stdin as NDJSON user
+// messages and the output goes through the same parseEventLine/StreamMsg
+// pipeline as StreamClaude, with the "result" event marking the end of a turn.
+// If the child dies it is restarted on the next prompt with --resume.
+type PersistentSession struct {
+	mu        sync.Mutex
+	proc      *persistentProc
+	turn      *persistentTurn
+	sessionID string // latest session ID seen, used to --resume on restart
+	requests  int    // control requests sent, for unique request IDs
+}
+
+// interruptTimeout is how long an interrupted turn may take to end before
+// the process is stopped instead.
+const interruptTimeout = 5 * time.Second
+
+// persistentProc is one running claude child and its pipes.
+type persistentProc struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stderr *syncBuffer
+	opts   TurnRequest // options the process was started with
+	wl     *os.File
+}
+
+// persistentTurn accumulates the events of the turn currently in flight.
+type persistentTurn struct {
+	proc        *persistentProc
+	ch          chan StreamMsg
+	prompt      string
+	startedAt   time.Time
+	stderrStart int
+	events      []StreamEvent
+	result      ClaudeResult
+	model       string
+	stopReason  string
+	injected    int // prompts injected mid-turn whose result is still to come
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent writes (from exec) and reads.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// Len returns the number of bytes written so far.
+func (b *syncBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Len()
+}
+
+// Since returns everything written after byte offset off.
+func (b *syncBuffer) Since(off int) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if off > b.buf.Len() {
+		return ""
+	}
+	return string(b.buf.Bytes()[off:])
+}
+
+// NewPersistentSession creates a session that resumes sessionID (if set).
+// The claude process is started lazily on the first prompt.
+func NewPersistentSession(sessionID string) *PersistentSession {
+	return &PersistentSession{sessionID: sessionID}
+}
+
+// buildPersistentCmd constructs the exec.Cmd for a long-lived stream-json claude process.
+// Prompts are written to stdin, so req.Prompt is ignored.
+func buildPersistentCmd(req TurnRequest) *exec.Cmd {
+	args := []string{"-p", "--input-format", "stream-json", "--output-format", "stream-json",
+		"--verbose", "--include-partial-messages"}
+	args = append(args, turnArgs(req)...)
+	cmd := exec.Command(claudeBin, args...)
+	cmd.Env = claudeEnv()
+	return cmd
+}
+
+// encodeUserMessage returns the NDJSON line (with trailing newline) for a user prompt.
+func encodeUserMessage(prompt string) []byte {
+	msg := map[string]any{
+		"type": "user",
+		"message": map[string]any{
+			"role":    "user",
+			"content": []map[string]any{{"type": "text", "text": prompt}},
+		},
+	}
+	b, _ := json.Marshal(msg)
+	return append(b, '\n')
+}
+
+// encodeInterrupt returns the NDJSON control request (with trailing newline)
+// asking claude to stop the turn in flight.
+func encodeInterrupt(requestID string) []byte {
+	msg := map[string]any{
+		"type":       "control_request",
+		"request_id": requestID,
+		"request":    map[string]any{"subtype": "interrupt"},
+	}
+	b, _ := json.Marshal(msg)
+	return append(b, '\n')
+}
+
+// SendPrompt writes a user message to the running process (starting or
+// restarting it if needed) and returns a channel of events for this turn.
+// The channel is closed after the final StreamMsg{Done: true}.
+// Only one turn may be in flight at a time. req.SessionID is ignored; the
+// session resumes its own.
+func (s *PersistentSession) SendPrompt(req TurnRequest) (<-chan StreamMsg, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.turn != nil {
+		return nil, errors.New("a turn is already in progress")
+	}
+
+	// Permission mode and allow rules are fixed per process, so changing them means a restart.
+	if s.proc != nil && !sameProcessOptions(s.proc.opts, req) {
+		s.stopLocked()
+	}
+
+	prompt := req.Prompt
+	line := encodeUserMessage(prompt)
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.proc == nil {
+			if err = s.startLocked(req); err != nil {
+				return nil, err
+			}
+		}
+		turn := &persistentTurn{
+			proc:        s.proc,
+			ch:          make(chan StreamMsg, 64),
+			prompt:      prompt,
+			startedAt:   time.Now(),
+			stderrStart: s.proc.stderr.Len(),
+		}
+		s.turn = turn
+		writeWireEnvelope(s.proc.wl, "request", map[string]any{
+			"_ts":        turn.startedAt.Format(time.RFC3339Nano),
+			"prompt":     prompt,
+			"session_id": s.sessionID,
+			"command":    s.proc.cmd.Args,
+		})
+		if _, err = s.proc.stdin.Write(line); err == nil {
+			return turn.ch, nil
+		}
+		// The child went away between turns; restart it and try once more.
+		s.turn = nil
+		s.stopLocked()
+	}
+	return nil, fmt.Errorf("write prompt: %w", err)
+}
+
+// startLocked spawns a new claude process. Caller must hold s.mu.
+func (s *PersistentSession) startLocked(opts TurnRequest) error {
+	opts.SessionID = s.sessionID
+	cmd := buildPersistentCmd(opts)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("stdin pipe: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("stdout pipe: %w", err)
+	}
+	stderr := &syncBuffer{}
+	cmd.Stderr = stderr
+
+	startedAt := time.Now()
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start claude: %w", err)
+	}
+
+	p := &persistentProc{
+		cmd:    cmd,
+		stdin:  stdin,
+		stderr: stderr,
+		opts:   opts,
+		wl:     openWireLog(startedAt),
+	}
+	s.proc = p
+	go s.readLoop(p, stdout)
+	return nil
+}
+
+// stopLocked closes stdin of the current process (claude exits on EOF) and
+// escalates to a kill if it lingers. Caller must hold s.mu.
+func (s *PersistentSession) stopLocked() {
+	if s.proc == nil {
+		return
+	}
+	_ = s.proc.stdin.Close()
+	gracefulKill(s.proc.cmd)
+	s.proc = nil
+}
+
+// readLoop reads stdout of p until EOF, routing events to the current turn.
+func (s *PersistentSession) readLoop(p *persistentProc, stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		if p.wl != nil {
+			fmt.Fprintf(p.wl, "%s\n", line)
+		}
+
+		s.mu.Lock()
+		turn := s.turn
+		s.mu.Unlock()
+		if turn == nil || turn.proc != p {
+			continue // output between turns has nowhere to go
+		}
+
+		ev, resultErr := parseEventLine(line, &turn.result, &turn.model, &turn.stopReason)
+		if ev == nil {
+			continue
+		}
+		if resultErr != nil {
+			writeWireEnvelope(p.wl, "error", map[string]any{"error": resultErr.Error()})
+		}
+		turn.events = append(turn.events, *ev)
+		turn.ch <- StreamMsg{Event: ev}
+
+		if ev.Type == "result" {
+			s.mu.Lock()
+			more := turn.injected > 0
+			if more {
+				turn.injected--
+			}
+			s.mu.Unlock()
+			if !more {
+				s.finishTurn(p, turn)
+			}
+		}
+	}
+
+	if scanErr := scanner.Err(); scanErr != nil {
+		writeWireEnvelope(p.wl, "error", map[string]any{
+			"error":  scanErr.Error(),
+			"source": "scanner",
+		})
+	}
+
+	waitErr := p.cmd.Wait()
+	writeWireEnvelope(p.wl, "exit", map[string]any{
+		"exit_err": fmt.Sprintf("%v", waitErr),
+		"stderr":   p.stderr.Since(0),
+	})
+	if p.wl != nil {
+		p.wl.Close()
+	}
+
+	s.mu.Lock()
+	if s.proc == p {
+		s.proc = nil
+	}
+	turn := s.turn
+	if turn != nil && turn.proc == p {
+		s.turn = nil
+	} else {
+		turn = nil
+	}
+	s.mu.Unlock()
+
+	// The process died (or was closed) mid-turn; fail the turn.
+	// The next prompt restarts it.
+	if turn != nil {
+		turn.ch <- StreamMsg{
+			Done: true,
+			Err:  fmt.Errorf("claude exited mid-turn: %v\nstderr: %s", waitErr, p.stderr.Since(turn.stderrStart)),
+		}
+		close(turn.ch)
+	}
+}
+
+// finishTurn emits the final StreamMsg for a turn ended by a result event.
+func (s *PersistentSession) finishTurn(p *persistentProc, turn *persistentTurn) {
+	s.mu.Lock()
+	if s.turn == turn {
+		s.turn = nil
+	}
+	if turn.result.SessionID != "" {
+		s.sessionID = turn.result.SessionID
+	}
+	s.mu.Unlock()
+
+	stderr := p.stderr.Since(turn.stderrStart)
+	writeWireEnvelope(p.wl, "done", map[string]any{
+		"stderr": stderr,
+		"model":  turn.model,
+		"stop":   turn.stopReason,
+	})
+
+	turn.ch <- StreamMsg{Done: true, Response: &ClaudeResponse{
+		Command:    p.cmd.Args,
+		Prompt:     turn.prompt,
+		Events:     turn.events,
+		Result:     turn.result,
+		Stderr:     stderr,
+		Model:      turn.model,
+		StopReason: turn.stopReason,
+		StartedAt:  turn.startedAt,
+	}}
+	close(turn.ch)
+}
+
+// StartTurn implements Backend. The session ID in req is only used if this
+// session hasn't seen one yet; afterwards the session tracks its own.
+func (s *PersistentSession) StartTurn(req TurnRequest) (<-chan StreamMsg, error) {
+	s.mu.Lock()
+	if s.sessionID == "" {
+		s.sessionID = req.SessionID
+	}
+	s.mu.Unlock()
+	return s.SendPrompt(req)
+}
+
+// Cancel stops the process, failing the turn in flight. The next turn
+// restarts claude with --resume.
+func (s *PersistentSession) Cancel() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopLocked()
+}
+
+// Interrupt implements interrupter: claude stops the turn and ends it with a
+// result event, keeping the process and its context. If the request can't be
+// written, or the turn hasn't ended after interruptTimeout, the process is
+// stopped as in Cancel.
+func (s *PersistentSession) Interrupt() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	turn := s.turn
+	if turn == nil || turn.proc != s.proc {
+		return nil
+	}
+	s.requests++
+	id := fmt.Sprintf("interrupt-%d", s.requests)
+	writeWireEnvelope(s.proc.wl, "interrupt", map[string]any{"request_id": id})
+	if _, err := s.proc.stdin.Write(encodeInterrupt(id)); err != nil {
+		s.stopLocked()
+		return fmt.Errorf("write interrupt: %w", err)
+	}
+	time.AfterFunc(interruptTimeout, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.turn == turn && s.proc == turn.proc {
+			s.stopLocked()
+		}
+	})
+	return nil
+}
+
+// Inject implements injector: prompt is written to claude while the turn is
+// in flight, and the turn goes on until its result as well.
+func (s *PersistentSession) Inject(prompt string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	turn := s.turn
+	if turn == nil || turn.proc != s.proc {
+		return errors.New("no turn in progress")
+	}
+	writeWireEnvelope(s.proc.wl, "inject", map[string]any{"prompt": prompt})
+	if _, err := s.proc.stdin.Write(encodeUserMessage(prompt)); err != nil {
+		return fmt.Errorf("write prompt: %w", err)
+	}
+	turn.prompt += "\n\n" + prompt
+	turn.injected++
+	return nil
+}
+
+// ResetSession implements sessionResetter: the process is stopped and the
+// next prompt starts a new conversation instead of resuming.
+func (s *PersistentSession) ResetSession() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopLocked()
+	s.sessionID = ""
+}
+
+// Close terminates the claude process. Any turn in flight fails with an error.
+func (s *PersistentSession) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopLocked()
+	return nil
+}
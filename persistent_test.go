@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBuildPersistentCmd(t *testing.T) {
+	t.Run("stream-json in and out", func(t *testing.T) {
+		cmd := buildPersistentCmd(TurnRequest{PermMode: PermPlan})
+		args := cmd.Args[1:]
+		idx := slices.Index(args, "--input-format")
+		if idx < 0 || args[idx+1] != "stream-json" {
+			t.Errorf("--input-format stream-json not found in args: %v", args)
+		}
+		idx = slices.Index(args, "--output-format")
+		if idx < 0 || args[idx+1] != "stream-json" {
+			t.Errorf("--output-format stream-json not found in args: %v", args)
+		}
+		idx = slices.Index(args, "--permission-mode")
+		if idx < 0 || args[idx+1] != "plan" {
+			t.Errorf("permission mode not correct in args: %v", args)
+		}
+		if slices.Contains(args, "--resume") {
+			t.Errorf("--resume should not be present without a session, args: %v", args)
+		}
+	})
+
+	t.Run("resumes session", func(t *testing.T) {
+		cmd := buildPersistentCmd(TurnRequest{SessionID: "sess-123"})
+		args := cmd.Args[1:]
+		idx := slices.Index(args, "--resume")
+		if idx < 0 || args[idx+1] != "sess-123" {
+			t.Errorf("--resume sess-123 not found in args: %v", args)
+		}
+		if slices.Contains(args, "--permission-mode") {
+			t.Errorf("--permission-mode should not be present for empty mode, args: %v", args)
+		}
+	})
+
+	t.Run("filters CLAUDECODE", func(t *testing.T) {
+		t.Setenv("CLAUDECODE", "1")
+		cmd := buildPersistentCmd(TurnRequest{})
+		for _, e := range cmd.Env {
+			if strings.HasPrefix(e, "CLAUDECODE=") {
+				t.Errorf("CLAUDECODE leaked into env: %q", e)
+			}
+		}
+	})
+}
+
+func TestEncodeUserMessage(t *testing.T) {
+	line := encodeUserMessage("hello\nworld")
+	if !strings.HasSuffix(string(line), "\n") {
+		t.Fatalf("line should end with newline: %q", line)
+	}
+	if strings.Count(string(line), "\n") != 1 {
+		t.Errorf("embedded newlines must be escaped: %q", line)
+	}
+
+	var msg struct {
+		Type    string `json:"type"`
+		Message struct {
+			Role    string `json:"role"`
+			Content []struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"content"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal(line, &msg); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if msg.Type != "user" || msg.Message.Role != "user" {
+		t.Errorf("type/role = %q/%q, want user/user", msg.Type, msg.Message.Role)
+	}
+	if len(msg.Message.Content) != 1 || msg.Message.Content[0].Text != "hello\nworld" {
+		t.Errorf("content = %+v, want single text block", msg.Message.Content)
+	}
+}
+
+func TestEncodeInterrupt(t *testing.T) {
+	line := encodeInterrupt("interrupt-1")
+	var msg struct {
+		Type      string `json:"type"`
+		RequestID string `json:"request_id"`
+		Request   struct {
+			Subtype string `json:"subtype"`
+		} `json:"request"`
+	}
+	if err := json.Unmarshal(line, &msg); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if msg.Type != "control_request" || msg.RequestID != "interrupt-1" || msg.Request.Subtype != "interrupt" {
+		t.Errorf("got %+v, want an interrupt control request", msg)
+	}
+	if !strings.HasSuffix(string(line), "}\n") {
+		t.Errorf("line should be one NDJSON line: %q", line)
+	}
+}
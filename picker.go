@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+)
+
+// SessionPicker is the startup list shown by -resume: past sessions in this
+// directory by topic, date, cost and turn count. Sessions only known from a
+// CLI transcript are tagged "claude".
+type SessionPicker struct {
+	store    *SessionStore
+	sessions []SavedSession
+	cursor   int
+	offset   int // first visible row
+	err      error
+}
+
+// sessionPickedMsg is sent when the picker closes. Session is nil when the
+// user chose to start a new conversation.
+type sessionPickedMsg struct {
+	Session *SavedSession
+}
+
+// NewSessionPicker lists the sessions saved for cwd.
+func NewSessionPicker(store *SessionStore, cwd string) (*SessionPicker, error) {
+	sessions, err := FindSessions(store, cwd)
+	if err != nil {
+		return nil, err
+	}
+	return &SessionPicker{store: store, sessions: sessions}, nil
+}
+
+// Update handles navigation keys. Enter loads the selected session, esc or
+// n starts a new conversation.
+func (p *SessionPicker) Update(msg tea.Msg) tea.Cmd {
+	key, ok := msg.(tea.KeyPressMsg)
+	if !ok {
+		return nil
+	}
+	switch key.String() {
+	case "up", "k":
+		if p.cursor > 0 {
+			p.cursor--
+		}
+	case "down", "j":
+		if p.cursor < len(p.sessions)-1 {
+			p.cursor++
+		}
+	case "home", "g":
+		p.cursor = 0
+	case "end", "G":
+		p.cursor = max(len(p.sessions)-1, 0)
+	case "esc", "n":
+		return func() tea.Msg { return sessionPickedMsg{} }
+	case "enter":
+		if len(p.sessions) == 0 {
+			return func() tea.Msg { return sessionPickedMsg{} }
+		}
+		sess, err := OpenSession(p.store, p.sessions[p.cursor])
+		if err != nil {
+			p.err = err
+			return nil
+		}
+		return func() tea.Msg { return sessionPickedMsg{Session: sess} }
+	}
+	return nil
+}
+
+// View renders the list to fit in width x height, in the colors of pal.
+func (p *SessionPicker) View(width, height int, pal Palette) string {
+	c := lipgloss.Color
+	titleStyle := lipgloss.NewStyle().Bold(true)
+	dimStyle := lipgloss.NewStyle().Foreground(c(pal.Dim))
+	selStyle := lipgloss.NewStyle().Background(c(pal.PopupSelected)).Foreground(c(pal.PopupText)).Bold(true)
+	errStyle := lipgloss.NewStyle().Foreground(c(pal.Error))
+
+	var sb strings.Builder
+	sb.WriteString(titleStyle.Render("Resume a session") + "\n\n")
+
+	if len(p.sessions) == 0 {
+		sb.WriteString(dimStyle.Render("No saved sessions in this directory.") + "\n\n")
+		sb.WriteString(dimStyle.Render("enter/esc: new conversation"))
+		return sb.String()
+	}
+
+	// 2 title lines, 2 footer lines
+	rows := max(height-4, 1)
+	if p.cursor < p.offset {
+		p.offset = p.cursor
+	}
+	if p.cursor >= p.offset+rows {
+		p.offset = p.cursor - rows + 1
+	}
+	end := min(p.offset+rows, len(p.sessions))
+
+	for i := p.offset; i < end; i++ {
+		s := p.sessions[i]
+		stats := fmt.Sprintf("%s  $%.2f  %d turns", s.UpdatedAt.Local().Format("Jan 2 15:04"), s.CostUSD, s.Turns)
+		if s.Transcript != "" {
+			stats = "claude  " + stats
+		}
+		topicW := max(width-lipgloss.Width(stats)-4, 10)
+		topic := truncateRunes(s.Topic, topicW)
+		gap := max(width-2-lipgloss.Width(topic)-lipgloss.Width(stats), 1)
+		line := topic + strings.Repeat(" ", gap) + dimStyle.Render(stats)
+		if i == p.cursor {
+			sb.WriteString(selStyle.Render("▸ "+topic+strings.Repeat(" ", gap)+stats) + "\n")
+		} else {
+			sb.WriteString("  " + line + "\n")
+		}
+	}
+	for i := end - p.offset; i < rows; i++ {
+		sb.WriteByte('\n')
+	}
+
+	sb.WriteByte('\n')
+	if p.err != nil {
+		sb.WriteString(errStyle.Render(p.err.Error()))
+	} else {
+		sb.WriteString(dimStyle.Render("↑/↓: select · enter: resume · esc: new conversation"))
+	}
+	return sb.String()
+}
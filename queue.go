@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	tea "charm.land/bubbletea/v2"
+)
+
+// Prompts submitted while a turn is streaming wait in a queue, shown as
+// pending user cards below the transcript, and are sent one at a time as
+// each turn ends. ↑ on an empty input takes the last queued prompt back for
+// editing; submitting it empty removes it. alt+enter instead hands the
+// prompt to claude straight away when the backend can take it mid-turn, and
+// claude answers it once the current reply is done.
+
+// queuedPrompt is a prompt waiting for the turn in flight to end.
+type queuedPrompt struct {
+	text     string
+	injected bool // already written to claude, answered after the current reply
+}
+
+// queuePrompt adds text to the queue, or injects it into the running turn
+// if asked to and the backend supports it.
+func (m *ChatModel) queuePrompt(text string, inject bool) {
+	if in, ok := m.backend.(injector); ok && inject {
+		err := in.Inject(text)
+		if err == nil {
+			m.queued = append(m.queued, queuedPrompt{text: text, injected: true})
+			m.refreshViewport()
+			return
+		}
+		m.addNotice("error", "inject prompt: "+err.Error()+" (queued instead)")
+	}
+	m.queued = append(m.queued, queuedPrompt{text: text})
+	m.refreshViewport()
+}
+
+// editQueued moves the last prompt that hasn't been sent yet back into the
+// input. It reports whether there was one.
+func (m *ChatModel) editQueued() bool {
+	for i := len(m.queued) - 1; i >= 0; i-- {
+		if !m.queued[i].injected {
+			m.textarea.SetValue(m.queued[i].text)
+			m.queued = append(m.queued[:i], m.queued[i+1:]...)
+			m.refreshViewport()
+			return true
+		}
+	}
+	return false
+}
+
+// startInjectedReply handles a result event in the middle of a stream: the
+// reply before it is done, and claude moves on to the first injected prompt,
+// which becomes a user entry followed by a new streaming entry.
+func (m *ChatModel) startInjectedReply(ev StreamEvent) bool {
+	idx := -1
+	for i, q := range m.queued {
+		if q.injected {
+			idx = i
+			break
+		}
+	}
+	n := len(m.entries)
+	if idx < 0 || n == 0 || !m.entries[n-1].streaming {
+		return false
+	}
+	var result ClaudeResult
+	if json.Unmarshal([]byte(ev.Raw), &result) != nil {
+		return false
+	}
+
+	last := &m.entries[n-1]
+	resp := &ClaudeResponse{Result: result, Model: last.model} // from the reply's assistant events
+	m.finalizeStreamingEntry(last, resp)
+	if m.interrupting {
+		last.interrupted = true
+		m.interrupting = false
+	}
+	if result.SessionID != "" {
+		m.sessionID = result.SessionID
+	}
+	m.updateSessionStats(resp)
+
+	m.appendEntries(
+		chatEntry{role: "user", text: m.queued[idx].text},
+		chatEntry{role: "assistant", streaming: true},
+	)
+	m.queued = append(m.queued[:idx], m.queued[idx+1:]...)
+	m.refreshViewport()
+	return true
+}
+
+// dispatchQueued starts a turn for the next queued prompt, once the turn in
+// flight has ended. Injected prompts still queued were never answered (the
+// process went away), so they go back into the input to be sent again.
+func (m *ChatModel) dispatchQueued() tea.Cmd {
+	var pending []queuedPrompt
+	var unanswered []string
+	for _, q := range m.queued {
+		if q.injected {
+			unanswered = append(unanswered, q.text)
+		} else {
+			pending = append(pending, q)
+		}
+	}
+	m.queued = pending
+	if len(unanswered) > 0 {
+		if cur := strings.TrimSpace(m.textarea.Value()); cur != "" {
+			unanswered = append(unanswered, cur)
+		}
+		m.textarea.SetValue(strings.Join(unanswered, "\n\n"))
+		m.addNotice("error", "claude exited before answering the injected prompt; it's back in the input")
+	}
+	if len(m.queued) == 0 {
+		return nil
+	}
+	next := m.queued[0]
+	m.queued = m.queued[1:]
+	return m.submitPrompt(next.text)
+}
+
+// restoreQueued puts the prompts that haven't been sent back into the input
+// after an interrupt, since the user may no longer want them.
+func (m *ChatModel) restoreQueued() {
+	var texts, kept []string
+	var injected []queuedPrompt
+	for _, q := range m.queued {
+		if q.injected {
+			injected = append(injected, q)
+		} else {
+			texts = append(texts, q.text)
+		}
+	}
+	if len(texts) == 0 {
+		return
+	}
+	if cur := strings.TrimSpace(m.textarea.Value()); cur != "" {
+		kept = append(texts, cur)
+	} else {
+		kept = texts
+	}
+	m.textarea.SetValue(strings.Join(kept, "\n\n"))
+	m.queued = injected
+}
+
+// renderQueue renders the queued prompts as pending user cards.
+func (m *ChatModel) renderQueue() vpItem {
+	m.cardZones = m.cardZones[:0]
+	var sb strings.Builder
+	var lineCount int
+
+	cardWidth := m.transcriptWidth() - 4
+	if cardWidth < 20 {
+		cardWidth = 20
+	}
+	editable := false
+	for _, q := range m.queued {
+		sb.WriteString("\n")
+		lineCount++
+		label := "Queued:"
+		if q.injected {
+			label = "Sent, answered next:"
+		} else {
+			editable = true
+		}
+		m.renderCard(&sb, &lineCount, "queued",
+			m.styleUserLabel.Render(label)+"\n"+m.styleDim.Render(q.text),
+			m.styleUserCard, cardWidth, false)
+	}
+	if editable {
+		sb.WriteString(m.styleDim.Render("  ↑ to edit the last queued prompt") + "\n")
+	}
+
+	lines := renderedLines(sb.String())
+	return vpItem{lines: lines, height: len(lines), fresh: true}
+}
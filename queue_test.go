@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tea "charm.land/bubbletea/v2"
+)
+
+func TestPromptQueueEditing(t *testing.T) {
+	m := newTestChat(NewPrintBackend())
+	m.streamCh = make(chan StreamMsg) // a turn is in flight
+	enter := tea.KeyPressMsg{Code: tea.KeyEnter}
+	up := tea.KeyPressMsg{Code: tea.KeyUp}
+
+	for _, p := range []string{"first", "second"} {
+		m.textarea.SetValue(p)
+		m.Update(enter)
+	}
+	view := stripANSI(m.viewport.View())
+	if strings.Count(view, "Queued:") != 2 || !strings.Contains(view, "↑ to edit") {
+		t.Errorf("queued prompts should be shown as pending cards:\n%s", view)
+	}
+
+	// ↑ takes the last one back; sending it empty removes it
+	m.Update(up)
+	if m.textarea.Value() != "second" || len(m.queued) != 1 {
+		t.Fatalf("↑ should edit the last queued prompt: %q, %+v", m.textarea.Value(), m.queued)
+	}
+	m.textarea.SetValue("second, edited")
+	m.Update(enter)
+	if len(m.queued) != 2 || m.queued[1].text != "second, edited" {
+		t.Errorf("edited prompt should be queued again: %+v", m.queued)
+	}
+	m.Update(up)
+	m.textarea.Reset()
+	m.Update(enter)
+	if len(m.queued) != 1 || m.queued[0].text != "first" {
+		t.Errorf("emptied prompt should be removed: %+v", m.queued)
+	}
+
+	// ↑ with text in the input moves the cursor as usual
+	m.textarea.SetValue("draft")
+	m.Update(up)
+	if len(m.queued) != 1 {
+		t.Error("↑ should only edit the queue from an empty input")
+	}
+
+	// An interrupt hands the queue back to the input
+	m.restoreQueued()
+	if got := m.textarea.Value(); got != "first\n\ndraft" || len(m.queued) != 0 {
+		t.Errorf("after restoreQueued input = %q, queue = %+v", got, m.queued)
+	}
+}
@@ -2,96 +2,227 @@ package main
 
 import (
 	"fmt"
+	"maps"
+	"slices"
+	"strconv"
 	"strings"
 
 	"charm.land/lipgloss/v2"
 )
 
-const maxCollapsedLines = 5
+// entryRender is the cached rendering of one finalized entry.
+type entryRender struct {
+	key   entryRenderKey
+	lines []string
+	zones []cardZone
+}
+
+// entryRenderKey is what an entry's rendering depends on besides the entry
+// itself, which is identified by its ID: the width, which of its cards are
+// expanded, and for the latest entry the mode named in its denial key hints.
+// An entry replaced in place gets a new ID.
+type entryRenderKey struct {
+	width    int
+	expanded string
+	hint     PermissionMode
+}
 
+// refreshViewport rebuilds the viewport's items from the entries. Entries
+// whose cached rendering still matches are reused as is; the rest are
+// marked stale and only rendered once they scroll into view.
 func (m *ChatModel) refreshViewport() {
+	m.todos = latestTodos(m.entries)
+	width := m.transcriptWidth()
+	expanded := m.expandedByEntry()
+	if m.entryRenders == nil {
+		m.entryRenders = make(map[int]entryRender)
+	}
+	live := make(map[int]bool, len(m.entries))
+	for _, e := range m.entries {
+		live[e.id] = true
+	}
+	maps.DeleteFunc(m.entryRenders, func(id int, _ entryRender) bool { return !live[id] })
+
+	items := make([]vpItem, 0, len(m.entries)+1)
+	if m.initReceived {
+		var sb strings.Builder
+		var lineCount int
+		m.renderInitBanner(&sb, &lineCount)
+		items = append(items, vpItem{lines: renderedLines(sb.String()), fresh: true})
+	}
+	for i, e := range m.entries {
+		if e.streaming {
+			items = append(items, m.renderStreamingEntry(i))
+			continue
+		}
+		key := m.entryKey(i, width, expanded[e.id])
+		cached := m.entryRenders[e.id]
+		items = append(items, vpItem{
+			lines:  cached.lines,
+			zones:  cached.zones,
+			height: len(cached.lines),
+			fresh:  cached.lines != nil && cached.key == key,
+		})
+		if cached.lines == nil {
+			items[len(items)-1].height = 2 // estimate until rendered
+		}
+	}
+
+	banner := len(items) - len(m.entries)
+	if len(m.queued) > 0 {
+		items = append(items, m.renderQueue())
+	}
+
+	wasAtBottom := m.viewport.AtBottom()
+	m.viewport.SetItems(items, func(item int) vpItem {
+		i := item - banner
+		id := m.entries[i].id
+		key := m.entryKey(i, m.transcriptWidth(), m.expandedByEntry()[id])
+		r := m.renderEntry(i)
+		r.key = key
+		m.entryRenders[id] = r
+		return vpItem{lines: r.lines, zones: r.zones, height: len(r.lines), fresh: true}
+	})
+	if wasAtBottom {
+		m.viewport.GotoBottom()
+	}
+}
+
+// entryKey returns the cache key for entry i at the given width.
+func (m *ChatModel) entryKey(i, width int, expanded string) entryRenderKey {
+	key := entryRenderKey{width: width, expanded: expanded}
+	if len(m.entries[i].denials) > 0 && i == m.lastTurnEntry() {
+		key.hint = m.permMode
+	}
+	return key
+}
+
+// expandedByEntry groups the expanded card IDs by the entry ID embedded in
+// them ("tool-3-1" belongs to the entry with ID 3).
+func (m *ChatModel) expandedByEntry() map[int]string {
+	byEntry := make(map[int][]string)
+	for id, on := range m.expandedCards {
+		if !on {
+			continue
+		}
+		_, rest, _ := strings.Cut(id, "-")
+		num, _, _ := strings.Cut(rest, "-")
+		if i, err := strconv.Atoi(num); err == nil {
+			byEntry[i] = append(byEntry[i], id)
+		}
+	}
+	out := make(map[int]string, len(byEntry))
+	for i, ids := range byEntry {
+		slices.Sort(ids)
+		out[i] = strings.Join(ids, ",")
+	}
+	return out
+}
+
+// renderedLines splits rendered output into lines, without the empty line
+// after a trailing newline.
+func renderedLines(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// renderEntry renders finalized entry i with its card zones relative to
+// its first line.
+func (m *ChatModel) renderEntry(i int) entryRender {
 	m.cardZones = m.cardZones[:0]
 	var sb strings.Builder
 	var lineCount int
+	e := m.entries[i]
 
-	innerW := m.width - m.padH*2
-	if innerW < 20 {
-		innerW = 20
-	}
-	contentWidth := innerW - 4
+	contentWidth := m.transcriptWidth() - 4
 	if contentWidth < 20 {
 		contentWidth = 20
 	}
 	cardWidth := contentWidth
-	if cardWidth < 20 {
-		cardWidth = 20
-	}
 
-	// Init banner
-	if m.initReceived {
-		m.renderInitBanner(&sb, &lineCount)
-	}
-
-	for i, e := range m.entries {
-		if i > 0 {
-			sb.WriteString("\n")
-			lineCount++
+	if i > 0 {
+		sb.WriteString("\n")
+		lineCount++
+	}
+
+	switch e.role {
+	case "user":
+		id := fmt.Sprintf("entry-%d", e.id)
+		m.renderCard(&sb, &lineCount, id,
+			m.styleUserLabel.Render("User:")+"\n"+e.text,
+			m.styleUserCard, cardWidth, false)
+
+	case "assistant":
+		if len(e.blocks) > 0 {
+			m.renderBlocks(&sb, e.blocks, contentWidth, false, e.id, &lineCount)
+		} else if e.text != "" {
+			displayText := m.renderMarkdown(e.text, false)
+			id := fmt.Sprintf("text-%d", e.id)
+			m.renderCard(&sb, &lineCount, id, displayText,
+				m.styleAssistantCard, cardWidth, false)
 		}
 
-		switch e.role {
-		case "user":
-			id := fmt.Sprintf("entry-%d", i)
-			m.renderCard(&sb, &lineCount, id,
-				m.styleUserLabel.Render("User:")+"\n"+e.text,
-				m.styleUserCard, cardWidth, false)
-
-		case "assistant":
-			if len(e.blocks) > 0 {
-				m.renderBlocks(&sb, e.blocks, contentWidth, false, i, &lineCount)
-			} else if e.text != "" {
-				brightWhite := lipgloss.NewStyle().Foreground(lipgloss.Color("15"))
-				var displayText string
-				if m.renderer != nil {
-					rendered, err := m.renderer.Render(e.text)
-					if err == nil {
-						displayText = strings.TrimSpace(rendered)
-					} else {
-						displayText = brightWhite.Render(e.text)
-					}
-				} else {
-					displayText = brightWhite.Render(e.text)
-				}
-				id := fmt.Sprintf("text-%d", i)
-				m.renderCard(&sb, &lineCount, id, displayText,
-					m.styleAssistantCard, cardWidth, false)
-			}
+		if len(e.denials) > 0 {
+			m.renderDenialCard(&sb, &lineCount, e, cardWidth, i == m.lastTurnEntry())
+		}
 
-			// Per-message metadata line
-			if e.hasResult {
-				meta := m.renderMessageMeta(e)
-				sb.WriteString(meta)
-				lineCount += strings.Count(meta, "\n")
-			}
+		if e.interrupted {
+			line := m.styleDim.Render("  ⏹ interrupted")
+			sb.WriteString(line + "\n")
+			lineCount += strings.Count(line, "\n") + 1
+		}
 
-		case "error":
-			id := fmt.Sprintf("entry-%d", i)
-			m.renderCard(&sb, &lineCount, id, e.text,
-				m.styleErrorCard, cardWidth, false)
+		// Per-message metadata line
+		if e.hasResult {
+			meta := m.renderMessageMeta(e)
+			sb.WriteString(meta)
+			lineCount += strings.Count(meta, "\n")
 		}
+
+	case "error":
+		id := fmt.Sprintf("entry-%d", e.id)
+		m.renderCard(&sb, &lineCount, id, e.text,
+			m.styleErrorCard, cardWidth, false)
+
+	case "notice":
+		// Local command feedback (e.g. /export), not part of the conversation
+		line := m.styleDim.Render("  " + e.text)
+		sb.WriteString(line + "\n")
+		lineCount += strings.Count(line, "\n") + 1
 	}
 
-	m.cachedContent = sb.String()
-	m.cachedCardZoneCount = len(m.cardZones)
-	m.cachedLineCount = lineCount
-	wasAtBottom := m.viewport.AtBottom()
-	m.viewport.SetContent(m.cachedContent)
-	if wasAtBottom {
-		m.viewport.GotoBottom()
+	return entryRender{lines: renderedLines(sb.String()), zones: slices.Clone(m.cardZones)}
+}
+
+// renderStreamingEntry renders the entry being streamed: blocks are forced
+// expanded and only finished markdown blocks go through glamour. It is
+// never cached.
+func (m *ChatModel) renderStreamingEntry(i int) vpItem {
+	m.cardZones = m.cardZones[:0]
+	var sb strings.Builder
+	var lineCount int
+
+	contentWidth := m.transcriptWidth() - 4
+	if contentWidth < 20 {
+		contentWidth = 20
+	}
+	if i > 0 || m.initReceived {
+		sb.WriteString("\n")
+		lineCount++
 	}
+	if e := m.entries[i]; len(e.blocks) > 0 {
+		m.renderBlocks(&sb, e.blocks, contentWidth, true, e.id, &lineCount)
+	}
+	sb.WriteString("\n")
+
+	lines := renderedLines(sb.String())
+	return vpItem{lines: lines, zones: slices.Clone(m.cardZones), height: len(lines), fresh: true}
 }
 
 // renderCard renders content inside a styled card, with collapsible truncation.
-// Cards longer than maxCollapsedLines are truncated unless expanded or forceExpanded.
+// Cards longer than ui.MaxCollapsedLines are truncated unless expanded or forceExpanded.
 func (m *ChatModel) renderCard(sb *strings.Builder, lineCount *int, id, content string,
 	style lipgloss.Style, width int, forceExpanded bool,
 ) {
@@ -100,10 +231,10 @@ func (m *ChatModel) renderCard(sb *strings.Builder, lineCount *int, id, content
 	lines := strings.Split(rendered, "\n")
 
 	expanded := forceExpanded || m.expandedCards[id]
-	if !expanded && len(lines) > maxCollapsedLines {
+	if !expanded && len(lines) > m.ui.MaxCollapsedLines {
 		ellipsis := style.Width(width).Render(m.styleDim.Render("…"))
 		ellipsisLine := strings.Split(ellipsis, "\n")[0]
-		lines = append(lines[:maxCollapsedLines-1], ellipsisLine)
+		lines = append(lines[:m.ui.MaxCollapsedLines-1], ellipsisLine)
 	}
 
 	sb.WriteString(strings.Join(lines, "\n"))
@@ -114,62 +245,33 @@ func (m *ChatModel) renderCard(sb *strings.Builder, lineCount *int, id, content
 	m.cardZones = append(m.cardZones, cardZone{id: id, startLine: startLine, endLine: endLine})
 }
 
-// refreshStreamingViewport efficiently updates the viewport during streaming.
-// It reuses the cached content for finalized entries and renders the streaming
-// entry's blocks without glamour rendering.
+// refreshStreamingViewport updates the viewport during streaming by
+// re-rendering only the streaming entry; finalized entries keep their items.
 func (m *ChatModel) refreshStreamingViewport() {
-	// Preserve zones from finalized entries, discard streaming zones
-	m.cardZones = m.cardZones[:m.cachedCardZoneCount]
-
-	innerW := m.width - m.padH*2
-	if innerW < 20 {
-		innerW = 20
+	n := len(m.entries)
+	banner := 0
+	if m.initReceived {
+		banner = 1
 	}
-	contentWidth := innerW - 4
-	if contentWidth < 20 {
-		contentWidth = 20
+	queue := 0
+	if len(m.queued) > 0 {
+		queue = 1
 	}
-
-	var sb strings.Builder
-	var lineCount int
-
-	// Init banner if no cached content yet
-	if m.cachedContent == "" && m.initReceived {
-		m.renderInitBanner(&sb, &lineCount)
-	} else {
-		// Use cached content for all finalized entries
-		sb.WriteString(m.cachedContent)
-		lineCount = m.cachedLineCount
-	}
-
-	// Append the streaming entry
-	if len(m.entries) > 0 {
-		last := &m.entries[len(m.entries)-1]
-		if last.streaming {
-			if sb.Len() > 0 {
-				sb.WriteString("\n")
-				lineCount++
-			}
-
-			// Render blocks incrementally (raw=true skips glamour, forces expanded)
-			if len(last.blocks) > 0 {
-				entryIdx := len(m.entries) - 1
-				m.renderBlocks(&sb, last.blocks, contentWidth, true, entryIdx, &lineCount)
-			}
-			sb.WriteString("\n")
-			lineCount++
-		}
+	if n == 0 || !m.entries[n-1].streaming || m.viewport.ItemCount() != banner+n+queue {
+		m.refreshViewport()
+		return
 	}
+	m.todos = latestTodos(m.entries)
 
 	wasAtBottom := m.viewport.AtBottom()
-	m.viewport.SetContent(sb.String())
+	m.viewport.SetItem(banner+n-1, m.renderStreamingEntry(n-1))
 	if wasAtBottom {
 		m.viewport.GotoBottom()
 	}
 }
 
 func (m *ChatModel) renderBlocks(sb *strings.Builder, blocks []ChatBlock,
-	contentWidth int, raw bool, entryIdx int, lineCount *int,
+	contentWidth int, raw bool, entryID int, lineCount *int,
 ) {
 	// Group tool_use and tool_result by ToolID for inline rendering
 	resultMap := make(map[string]*ChatBlock)
@@ -193,7 +295,7 @@ func (m *ChatModel) renderBlocks(sb *strings.Builder, blocks []ChatBlock,
 			if raw {
 				label = m.styleThinkingLabel.Render("Thinking ...")
 			}
-			id := fmt.Sprintf("block-%d-%d", entryIdx, blockIdx)
+			id := fmt.Sprintf("block-%d-%d", entryID, blockIdx)
 			m.renderCard(sb, lineCount, id, label+"\n"+block.Text,
 				m.styleThinkingCard, cardWidth, raw)
 			sb.WriteString("\n")
@@ -207,19 +309,9 @@ func (m *ChatModel) renderBlocks(sb *strings.Builder, blocks []ChatBlock,
 			if cardWidth < 20 {
 				cardWidth = 20
 			}
-			brightWhite := lipgloss.NewStyle().Foreground(lipgloss.Color("15"))
-			var displayText string
-			if !raw && m.renderer != nil {
-				rendered, err := m.renderer.Render(block.Text)
-				if err == nil {
-					displayText = strings.TrimSpace(rendered)
-				} else {
-					displayText = brightWhite.Render(block.Text)
-				}
-			} else {
-				displayText = brightWhite.Render(block.Text)
-			}
-			id := fmt.Sprintf("text-%d-%d", entryIdx, blockIdx)
+			// Only the newest block of a streaming entry can still grow
+			displayText := m.renderMarkdown(block.Text, raw && blockIdx == len(blocks)-1)
+			id := fmt.Sprintf("text-%d-%d", entryID, blockIdx)
 			m.renderCard(sb, lineCount, id, displayText,
 				m.styleAssistantCard, cardWidth, raw)
 			sb.WriteString("\n")
@@ -241,7 +333,7 @@ func (m *ChatModel) renderBlocks(sb *strings.Builder, blocks []ChatBlock,
 			} else {
 				m.renderCompactTool(&toolBuf, block, resultMap[block.ToolID], innerWidth)
 			}
-			id := fmt.Sprintf("tool-%d-%d", entryIdx, blockIdx)
+			id := fmt.Sprintf("tool-%d-%d", entryID, blockIdx)
 			m.renderCard(sb, lineCount, id,
 				strings.TrimRight(toolBuf.String(), "\n"),
 				m.styleToolCard, cardWidth, false)
@@ -256,6 +348,12 @@ func (m *ChatModel) renderBlocks(sb *strings.Builder, blocks []ChatBlock,
 
 // renderMessageMeta formats the per-message metadata line.
 func (m *ChatModel) renderMessageMeta(e chatEntry) string {
+	return m.styleDim.Render("  "+strings.Join(messageMetaParts(e), " · ")) + "\n"
+}
+
+// messageMetaParts returns the model, cost, token, cache and duration fields
+// shown under an assistant message.
+func messageMetaParts(e chatEntry) []string {
 	var parts []string
 
 	if e.model != "" {
@@ -285,7 +383,7 @@ func (m *ChatModel) renderMessageMeta(e chatEntry) string {
 		}
 	}
 
-	return m.styleDim.Render("  "+strings.Join(parts, " · ")) + "\n"
+	return parts
 }
 
 // renderInitBanner writes the init banner (model, version, tools, plugins) to sb.
@@ -383,7 +481,7 @@ func shouldShowResultCard(toolName, output string) bool {
 // The card spans nearly full content width, like opencode's output cards.
 func (m *ChatModel) renderToolResultCard(sb *strings.Builder, output string, contentWidth int) {
 	lines := strings.Split(output, "\n")
-	maxLines := 15
+	maxLines := m.ui.ToolResultLines
 	truncated := false
 	if len(lines) > maxLines {
 		lines = lines[:maxLines]
@@ -409,6 +507,8 @@ func (m *ChatModel) renderToolResultCard(sb *strings.Builder, output string, con
 //	⚙ ToolName
 //	  input summary
 //	  ✓ first line of result
+//
+// Edit, MultiEdit and Write show a diff of the change instead of the result.
 func (m *ChatModel) renderCompactTool(sb *strings.Builder, block ChatBlock, result *ChatBlock,
 	contentWidth int,
 ) {
@@ -417,12 +517,57 @@ func (m *ChatModel) renderCompactTool(sb *strings.Builder, block ChatBlock, resu
 		maxLen = 20
 	}
 
-	// Tool name + input summary on the same line
+	// TodoWrite shows the checklist instead of its JSON input
+	if todos, ok := parseTodos(block.ToolInput); ok && block.ToolName == "TodoWrite" {
+		sb.WriteString("  " + m.styleToolName.Render("⚙ "+block.ToolName) + " " + m.styleDim.Render(todoProgress(todos)) + "\n")
+		if result != nil && result.IsError {
+			outputLine := firstLine(cleanToolOutput(result.ToolOutput), maxLen-4)
+			sb.WriteString("    " + m.styleToolErr.Render("✗ "+outputLine) + "\n")
+		}
+		m.renderTodoItems(sb, todos, "    ", maxLen)
+		return
+	}
+
+	var output string
+	if result != nil {
+		output = result.ToolOutput
+	}
+	diff := parseToolDiff(block.ToolName, block.ToolInput, output)
+
+	// Tool name + input summary on the same line, with +/- counts for
+	// edits and the requested range for reads
+	var stat string
+	if diff != nil {
+		stat = " " + m.renderDiffStat(diff)
+		maxLen -= len([]rune(diff.Stat())) + 1
+	} else if _, r := readInput(block.ToolInput); block.ToolName == "Read" && r != "" {
+		stat = " " + m.styleDim.Render("· "+r)
+		maxLen -= len([]rune(r)) + 3
+	}
 	inputLine := toolInputSummary(block.ToolName, block.ToolInput, maxLen)
 	if inputLine != "" {
-		sb.WriteString("  " + m.styleToolName.Render("⚙ "+block.ToolName) + " " + m.styleToolInput.Render(inputLine) + "\n")
+		sb.WriteString("  " + m.styleToolName.Render("⚙ "+block.ToolName) + " " + m.styleToolInput.Render(inputLine) + stat + "\n")
 	} else {
-		sb.WriteString("  " + m.styleToolName.Render("⚙ "+block.ToolName) + "\n")
+		sb.WriteString("  " + m.styleToolName.Render("⚙ "+block.ToolName) + stat + "\n")
+	}
+
+	// File edits show the diff; only failures need the result text
+	if diff != nil {
+		if result != nil && result.IsError {
+			cleaned := cleanToolOutput(result.ToolOutput)
+			outputLine := firstLine(cleaned, maxLen-4)
+			sb.WriteString("    " + m.styleToolErr.Render("✗ "+outputLine) + "\n")
+		}
+		m.renderDiff(sb, diff, contentWidth)
+		return
+	}
+
+	// Reads show the file content instead of its first line
+	if block.ToolName == "Read" && result != nil && !result.IsError {
+		if nums, texts := numberedLines(result.ToolOutput); len(nums) > 0 {
+			m.renderReadPreview(sb, block, nums, texts, contentWidth)
+			return
+		}
 	}
 
 	// Result
@@ -441,6 +586,92 @@ func (m *ChatModel) renderCompactTool(sb *strings.Builder, block ChatBlock, resu
 	}
 }
 
+// renderDiffStat renders the "+12 −3" summary of a file edit.
+func (m *ChatModel) renderDiffStat(d *toolDiff) string {
+	add := m.styleDiffAdd.Render(fmt.Sprintf("+%d", d.Added))
+	if d.NewFile {
+		return add
+	}
+	return add + " " + m.styleDiffDel.Render(fmt.Sprintf("−%d", d.Removed))
+}
+
+// renderDiff renders diff lines under a tool call with a line-number gutter,
+// left blank for lines whose position is unknown: additions in green,
+// removals in red, context dimmed. Writes show the new
+// file's contents as additions. Long diffs rely on the tool card collapsing
+// and are capped at diffMaxLines when expanded.
+func (m *ChatModel) renderDiff(sb *strings.Builder, d *toolDiff, contentWidth int) {
+	lines := d.Lines
+	if len(lines) > diffMaxLines {
+		lines = lines[:diffMaxLines]
+	}
+
+	gutter := 0
+	for _, l := range lines {
+		if no := max(l.OldNo, l.NewNo); no > 0 {
+			gutter = max(gutter, len(fmt.Sprint(no)))
+		}
+	}
+	numWidth := 0
+	if gutter > 0 {
+		numWidth = gutter + 1
+	}
+	textWidth := contentWidth - numWidth - 6
+	if textWidth < 10 {
+		textWidth = 10
+	}
+
+	for _, l := range lines {
+		if l.Op == diffGap {
+			sb.WriteString("    " + m.styleDim.Render(strings.Repeat(" ", numWidth)+"⋯") + "\n")
+			continue
+		}
+		no := l.NewNo
+		style := m.styleDim
+		switch l.Op {
+		case diffInsert:
+			style = m.styleDiffAdd
+		case diffDelete:
+			no = l.OldNo
+			style = m.styleDiffDel
+		}
+		num := strings.Repeat(" ", numWidth)
+		if no > 0 {
+			num = fmt.Sprintf("%*d ", gutter, no)
+		}
+		text := truncateRunes(strings.ReplaceAll(l.Text, "\t", "    "), textWidth)
+		sb.WriteString("    " + m.styleDim.Render(num) + style.Render(string(l.Op)+" "+text) + "\n")
+	}
+	if more := len(d.Lines) - len(lines); more > 0 {
+		sb.WriteString("    " + m.styleDim.Render(fmt.Sprintf("... (%d more lines)", more)) + "\n")
+	}
+}
+
+// renderReadPreview renders the lines returned by a Read call, highlighted
+// for the file's language, with the file's own line numbers in the gutter.
+// Collapsed cards show the first few lines.
+func (m *ChatModel) renderReadPreview(sb *strings.Builder, block ChatBlock, nums []int, texts []string,
+	contentWidth int,
+) {
+	total := len(texts)
+	if total > readPreviewMaxLines {
+		nums, texts = nums[:readPreviewMaxLines], texts[:readPreviewMaxLines]
+	}
+	gutter := len(strconv.Itoa(nums[len(nums)-1]))
+	textWidth := contentWidth - gutter - 5
+	if textWidth < 10 {
+		textWidth = 10
+	}
+
+	path, _ := readInput(block.ToolInput)
+	for i, line := range highlightLines(path, texts, textWidth, m.codeStyle) {
+		sb.WriteString("    " + m.styleDim.Render(fmt.Sprintf("%*d ", gutter, nums[i])) + line + "\n")
+	}
+	if more := total - len(texts); more > 0 {
+		sb.WriteString("    " + m.styleDim.Render(fmt.Sprintf("... (%d more lines)", more)) + "\n")
+	}
+}
+
 // renderTaskBlock renders a Task (subagent) tool call with compact header,
 // description, subagent activity, result, and metadata footer.
 func (m *ChatModel) renderTaskBlock(sb *strings.Builder, block ChatBlock, result *ChatBlock,
@@ -483,12 +714,16 @@ func (m *ChatModel) renderTaskBlock(sb *strings.Builder, block ChatBlock, result
 
 		for _, sub := range block.TaskSubBlocks {
 			if sub.Kind == BlockToolUse {
-					// Tool name + input on same line
+				// Tool name + input on same line
 				inputLine := toolInputSummary(sub.ToolName, sub.ToolInput, subMaxLen)
+				var stat string
+				if diff := parseToolDiff(sub.ToolName, sub.ToolInput, ""); diff != nil {
+					stat = " " + m.renderDiffStat(diff)
+				}
 				if inputLine != "" {
-					sb.WriteString("      " + m.styleToolName.Render("⚙ "+sub.ToolName) + " " + m.styleToolInput.Render(inputLine) + "\n")
+					sb.WriteString("      " + m.styleToolName.Render("⚙ "+sub.ToolName) + " " + m.styleToolInput.Render(inputLine) + stat + "\n")
 				} else {
-					sb.WriteString("      " + m.styleToolName.Render("⚙ "+sub.ToolName) + "\n")
+					sb.WriteString("      " + m.styleToolName.Render("⚙ "+sub.ToolName) + stat + "\n")
 				}
 
 				// Show first line of result
@@ -521,29 +756,35 @@ func (m *ChatModel) renderTaskBlock(sb *strings.Builder, block ChatBlock, result
 	}
 
 	// Metadata footer
-	if block.TaskMeta != nil {
-		meta := block.TaskMeta
-		var metaParts []string
-		if meta.AgentID != "" {
-			id := meta.AgentID
-			if len(id) > 8 {
-				id = id[:8]
-			}
-			metaParts = append(metaParts, id)
-		}
-		if meta.TotalDurationMs > 0 {
-			metaParts = append(metaParts, fmt.Sprintf("%.1fs", float64(meta.TotalDurationMs)/1000))
-		}
-		if meta.TotalTokens > 0 {
-			metaParts = append(metaParts, formatTokens(meta.TotalTokens)+" tok")
-		}
-		if meta.TotalToolUseCount > 0 {
-			metaParts = append(metaParts, fmt.Sprintf("%d tools", meta.TotalToolUseCount))
-		}
-		if len(metaParts) > 0 {
-			sb.WriteString("    " + m.styleDim.Render(strings.Join(metaParts, " · ")) + "\n")
+	if metaParts := taskMetaParts(block.TaskMeta); len(metaParts) > 0 {
+		sb.WriteString("    " + m.styleDim.Render(strings.Join(metaParts, " · ")) + "\n")
+	}
+}
+
+// taskMetaParts returns the agent ID, duration, token and tool counts of a
+// finished Task, or nil if there is no metadata.
+func taskMetaParts(meta *TaskResultMeta) []string {
+	if meta == nil {
+		return nil
+	}
+	var metaParts []string
+	if meta.AgentID != "" {
+		id := meta.AgentID
+		if len(id) > 8 {
+			id = id[:8]
 		}
+		metaParts = append(metaParts, id)
+	}
+	if meta.TotalDurationMs > 0 {
+		metaParts = append(metaParts, fmt.Sprintf("%.1fs", float64(meta.TotalDurationMs)/1000))
+	}
+	if meta.TotalTokens > 0 {
+		metaParts = append(metaParts, formatTokens(meta.TotalTokens)+" tok")
+	}
+	if meta.TotalToolUseCount > 0 {
+		metaParts = append(metaParts, fmt.Sprintf("%d tools", meta.TotalToolUseCount))
 	}
+	return metaParts
 }
 
 // renderHeaderCard renders the sticky header card showing conversation topic and stats.
@@ -554,6 +795,9 @@ func (m *ChatModel) renderHeaderCard(innerW int) string {
 
 	// Build stats: total tokens, cache%, cost
 	var statParts []string
+	if mismatch := m.modelMismatch(); mismatch != "" {
+		statParts = append(statParts, lipgloss.NewStyle().Foreground(lipgloss.Color("11")).Render(mismatch))
+	}
 	totalTok := m.totalInputTok + m.totalOutputTok
 	if totalTok > 0 {
 		statParts = append(statParts, formatTokens(totalTok))
@@ -591,7 +835,18 @@ func (m *ChatModel) renderHeaderCard(innerW int) string {
 
 // conversationTopic derives a short topic from the first user message.
 func (m *ChatModel) conversationTopic() string {
-	for _, e := range m.entries {
+	if topic := entriesTopic(m.entries); topic != "" {
+		return topic
+	}
+	if m.initModel != "" {
+		return m.initModel
+	}
+	return "New conversation"
+}
+
+// entriesTopic returns the first user prompt, flattened and shortened, or "".
+func entriesTopic(entries []chatEntry) string {
+	for _, e := range entries {
 		if e.role == "user" {
 			topic := strings.ReplaceAll(e.text, "\n", " ")
 			topic = strings.TrimSpace(topic)
@@ -601,8 +856,112 @@ func (m *ChatModel) conversationTopic() string {
 			return topic
 		}
 	}
-	if m.initModel != "" {
-		return m.initModel
+	return ""
+}
+
+// renderPermissionModal renders the prompt for the first pending permission
+// request: tool name and summary, the full input, and the available keys.
+func (m *ChatModel) renderPermissionModal(innerW int) string {
+	req := m.permQueue[0]
+	cardW := innerW
+	if cardW < 20 {
+		cardW = 20
 	}
-	return "New conversation"
+	textW := cardW - 4 // border + padding
+
+	var sb strings.Builder
+	sb.WriteString(m.styleToolName.Render("Permission required") + "\n")
+	summary := toolInputSummary(req.ToolName, string(req.Input), textW-len(req.ToolName)-3)
+	sb.WriteString(m.styleToolName.Render("⚙ "+req.ToolName) + " " + m.styleToolInput.Render(summary) + "\n\n")
+
+	// Full input, capped so the modal never covers the whole viewport
+	maxInputLines := m.viewport.Height() - 8
+	if maxInputLines < 3 {
+		maxInputLines = 3
+	}
+	var input []string
+	for _, line := range strings.Split(prettyJSON(req.Input), "\n") {
+		input = append(input, wrapText(line, textW)...)
+	}
+	if len(input) > maxInputLines {
+		more := len(input) - maxInputLines + 1
+		input = append(input[:maxInputLines-1], fmt.Sprintf("... (%d more lines)", more))
+	}
+	sb.WriteString(m.styleToolOutput.Render(strings.Join(input, "\n")) + "\n\n")
+
+	if m.permDenying {
+		sb.WriteString(m.permDenyInput.View() + "\n")
+		sb.WriteString(m.styleDim.Render("enter: deny · esc: back"))
+	} else {
+		keys := "a: allow once · A: allow " + req.ToolName + " for this session · d: deny"
+		sb.WriteString(m.styleDim.Render(keys))
+	}
+	if n := len(m.permQueue) - 1; n > 0 {
+		sb.WriteString("\n" + m.styleDim.Render(fmt.Sprintf("+%d more waiting", n)))
+	}
+
+	return m.stylePermCard.Width(cardW).Render(sb.String())
+}
+
+// renderDenialCard renders the tool calls the permission mode refused during
+// the turn of e, titled with the mode it ran in. The retry keys are only
+// offered on the latest turn, since they re-run the last prompt.
+func (m *ChatModel) renderDenialCard(sb *strings.Builder, lineCount *int, e chatEntry,
+	width int, actionable bool,
+) {
+	textW := width - 3 // border + padding
+	denials := e.denials
+
+	var b strings.Builder
+	title := fmt.Sprintf("⚠ %d tool call denied", len(denials))
+	if len(denials) > 1 {
+		title = fmt.Sprintf("⚠ %d tool calls denied", len(denials))
+	}
+	b.WriteString(m.styleToolName.Render(title))
+	if e.permMode != "" { // sessions saved before modes were recorded
+		b.WriteString(m.styleDim.Render(" (" + e.permMode.Short() + ")"))
+	}
+	for _, d := range denials {
+		summary := toolInputSummary(d.ToolName, string(d.ToolInput), textW-len(d.ToolName)-3)
+		b.WriteString("\n" + m.styleToolName.Render("⚙ "+d.ToolName) + " " + m.styleToolInput.Render(summary))
+		for _, line := range strings.Split(prettyJSON(d.ToolInput), "\n") {
+			for _, wrapped := range wrapText(line, textW-2) {
+				b.WriteString("\n  " + m.styleToolOutput.Render(wrapped))
+			}
+		}
+	}
+	m.renderCard(sb, lineCount, fmt.Sprintf("denials-%d", e.id), b.String(),
+		m.styleDenialCard, width, false)
+
+	// Keys go below the card so they stay visible when it is collapsed
+	if actionable {
+		keys := fmt.Sprintf("  %s: retry in %s mode · %s: retry allowing %s",
+			m.keys.RetryEscalate, m.permMode.Escalate().Short(),
+			m.keys.RetryAllow, strings.Join(deniedToolNames(denials), ", "))
+		sb.WriteString(m.styleDim.Render(keys) + "\n")
+		*lineCount++
+	}
+}
+
+// deniedToolNames returns the distinct tool names in denials, in order.
+func deniedToolNames(denials []PermissionDenial) []string {
+	var names []string
+	for _, d := range denials {
+		if !slices.Contains(names, d.ToolName) {
+			names = append(names, d.ToolName)
+		}
+	}
+	return names
+}
+
+// overlayBottom replaces the last lines of base with overlay, keeping the
+// line count of base unchanged.
+func overlayBottom(base, overlay string) string {
+	baseLines := strings.Split(base, "\n")
+	overLines := strings.Split(overlay, "\n")
+	if len(overLines) >= len(baseLines) {
+		return strings.Join(overLines[len(overLines)-len(baseLines):], "\n")
+	}
+	copy(baseLines[len(baseLines)-len(overLines):], overLines)
+	return strings.Join(baseLines, "\n")
 }
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// maxReplayGap caps the idle time between recorded turns (time the user
+// spent typing) so replays don't sit idle for minutes.
+const maxReplayGap = 5 * time.Second
+
+// replayTurn is one request/response cycle parsed from a wire log.
+type replayTurn struct {
+	Prompt    string
+	SessionID string
+	Command   []string
+	Start     time.Time // from the request envelope
+	End       time.Time // from the done/exit envelope, zero if the log ends mid-turn
+	Lines     []string  // raw NDJSON lines between request and done
+	ExitErr   string    // exit_err from the done envelope ("<nil>" on success)
+	Stderr    string
+}
+
+// wireEnvelope is the synthetic {"_wire": ...} line written around raw events.
+type wireEnvelope struct {
+	Wire      string   `json:"_wire"`
+	TS        string   `json:"_ts"`
+	Prompt    string   `json:"prompt"`
+	SessionID string   `json:"session_id"`
+	Command   []string `json:"command"`
+	ExitErr   string   `json:"exit_err"`
+	Stderr    string   `json:"stderr"`
+}
+
+// parseWireLog splits a wire log written by -wire-log into turns. Raw lines
+// outside a request/done pair are dropped; a turn still open at EOF is kept
+// with a zero End so replay can report the truncation.
+func parseWireLog(r io.Reader) ([]replayTurn, error) {
+	var turns []replayTurn
+	var cur *replayTurn
+
+	closeTurn := func(env wireEnvelope) {
+		if cur == nil {
+			return
+		}
+		cur.End, _ = time.Parse(time.RFC3339Nano, env.TS)
+		cur.ExitErr = env.ExitErr
+		cur.Stderr = env.Stderr
+		turns = append(turns, *cur)
+		cur = nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		var env wireEnvelope
+		if json.Unmarshal([]byte(line), &env) == nil && env.Wire != "" {
+			switch env.Wire {
+			case "request":
+				if cur != nil {
+					turns = append(turns, *cur) // previous turn never finished
+				}
+				start, _ := time.Parse(time.RFC3339Nano, env.TS)
+				cur = &replayTurn{
+					Prompt:    env.Prompt,
+					SessionID: env.SessionID,
+					Command:   env.Command,
+					Start:     start,
+				}
+			case "done":
+				closeTurn(env)
+			case "exit":
+				// Persistent process died with a turn open
+				env.ExitErr = "exited mid-turn: " + env.ExitErr
+				closeTurn(env)
+			}
+			continue
+		}
+		if cur != nil {
+			cur.Lines = append(cur.Lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read wire log: %w", err)
+	}
+	if cur != nil {
+		turns = append(turns, *cur)
+	}
+	return turns, nil
+}
+
+// ReplayBackend plays back a recorded wire log instead of running claude.
+// It supplies its own prompts (see promptSource); lines within a turn are
+// spread evenly between the recorded request and done timestamps, since the
+// wire log doesn't timestamp individual events.
+type ReplayBackend struct {
+	turns []replayTurn
+	speed float64 // 1 = original timing, 2 = twice as fast, 0 = no delays
+
+	mu      sync.Mutex
+	next    int  // index of the next turn to hand out as a prompt
+	pending bool // turns[next-1] was handed out but not started
+	cancel  chan struct{}
+}
+
+// LoadReplay reads a wire log from path.
+func LoadReplay(path string, speed float64) (*ReplayBackend, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	turns, err := parseWireLog(f)
+	if err != nil {
+		return nil, err
+	}
+	if len(turns) == 0 {
+		return nil, fmt.Errorf("%s: no recorded turns", path)
+	}
+	return &ReplayBackend{turns: turns, speed: speed}, nil
+}
+
+// scale converts a recorded duration to replay time.
+func (b *ReplayBackend) scale(d time.Duration) time.Duration {
+	if b.speed <= 0 || d <= 0 {
+		return 0
+	}
+	return time.Duration(float64(d) / b.speed)
+}
+
+// NextPrompt implements promptSource: it returns the next recorded prompt
+// and how long to wait before submitting it.
+func (b *ReplayBackend) NextPrompt() (string, time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pending || b.next >= len(b.turns) {
+		return "", 0, false
+	}
+	t := b.turns[b.next]
+	var gap time.Duration
+	if b.next > 0 {
+		prev := b.turns[b.next-1]
+		if !prev.End.IsZero() && !t.Start.IsZero() {
+			gap = min(t.Start.Sub(prev.End), maxReplayGap)
+		}
+	}
+	b.next++
+	b.pending = true
+	return t.Prompt, b.scale(gap), true
+}
+
+// StartTurn plays the turn handed out by the last NextPrompt.
+func (b *ReplayBackend) StartTurn(req TurnRequest) (<-chan StreamMsg, error) {
+	b.mu.Lock()
+	if !b.pending {
+		b.mu.Unlock()
+		return nil, errors.New("replay mode: input is disabled")
+	}
+	b.pending = false
+	turn := b.turns[b.next-1]
+	cancel := make(chan struct{})
+	b.cancel = cancel
+	b.mu.Unlock()
+
+	var interval time.Duration
+	if !turn.End.IsZero() && len(turn.Lines) > 0 {
+		interval = b.scale(turn.End.Sub(turn.Start)) / time.Duration(len(turn.Lines))
+	}
+
+	ch := make(chan StreamMsg, 64)
+	go func() {
+		defer close(ch)
+		startedAt := time.Now()
+		var events []StreamEvent
+		var result ClaudeResult
+		var model, stopReason string
+
+		// stop reports the cancel without waiting for a reader, which may be
+		// gone; the close on return still ends the turn for one that isn't.
+		stop := func() {
+			select {
+			case ch <- StreamMsg{Done: true, Err: errors.New("replay cancelled")}:
+			default:
+			}
+		}
+		for _, line := range turn.Lines {
+			select {
+			case <-cancel:
+				stop()
+				return
+			default:
+			}
+			if interval > 0 {
+				select {
+				case <-time.After(interval):
+				case <-cancel:
+					stop()
+					return
+				}
+			}
+			ev, _ := parseEventLine(line, &result, &model, &stopReason)
+			if ev == nil {
+				continue
+			}
+			events = append(events, *ev)
+			select {
+			case ch <- StreamMsg{Event: ev}:
+			case <-cancel:
+				stop()
+				return
+			}
+		}
+
+		var done StreamMsg
+		switch {
+		case turn.End.IsZero():
+			done = StreamMsg{Done: true, Err: errors.New("replay: wire log ends mid-turn")}
+		case turn.ExitErr != "" && turn.ExitErr != "<nil>":
+			done = StreamMsg{Done: true, Err: fmt.Errorf("claude: %s\nstderr: %s", turn.ExitErr, turn.Stderr)}
+		default:
+			done = StreamMsg{Done: true, Response: &ClaudeResponse{
+				Command:    turn.Command,
+				Prompt:     turn.Prompt,
+				Events:     events,
+				Result:     result,
+				Stderr:     turn.Stderr,
+				Model:      model,
+				StopReason: stopReason,
+				StartedAt:  startedAt,
+			}}
+		}
+		select {
+		case ch <- done:
+		case <-cancel:
+			stop()
+		}
+	}()
+	return ch, nil
+}
+
+// Cancel stops the turn being replayed.
+func (b *ReplayBackend) Cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.cancel != nil {
+		close(b.cancel)
+		b.cancel = nil
+	}
+}
+
+// Close is Cancel; replay holds no processes.
+func (b *ReplayBackend) Close() error {
+	b.Cancel()
+	return nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeWireLog builds a wire log with one turn per prompt, each replaying the
+// fixture's lines between request and done envelopes.
+func writeWireLog(t *testing.T, fixture string, prompts ...string) string {
+	t.Helper()
+	lines, err := readFixtureLines(fixture)
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	var sb strings.Builder
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i, p := range prompts {
+		ts := start.Add(time.Duration(i) * 10 * time.Second)
+		fmt.Fprintf(&sb, `{"_wire":"request","_ts":%q,"prompt":%q,"session_id":"","command":["claude","-p"]}`+"\n",
+			ts.Format(time.RFC3339Nano), p)
+		for _, l := range lines {
+			sb.WriteString(l + "\n")
+		}
+		fmt.Fprintf(&sb, `{"_wire":"done","_ts":%q,"exit_err":"<nil>","stderr":"","model":"m","stop":""}`+"\n",
+			ts.Add(2*time.Second).Format(time.RFC3339Nano))
+	}
+	path := filepath.Join(t.TempDir(), "wire.jsonl")
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseWireLog(t *testing.T) {
+	log := strings.Join([]string{
+		`{"type":"system","subtype":"init"}`, // before any request: dropped
+		`{"_wire":"request","_ts":"2026-01-02T03:04:05Z","prompt":"hi","session_id":"s1","command":["claude","-p","hi"]}`,
+		`{"type":"assistant","message":{"content":[]}}`,
+		`{"_wire":"error","_ts":"2026-01-02T03:04:06Z","error":"result unmarshal: bad"}`,
+		`{"type":"result","subtype":"success"}`,
+		`{"_wire":"done","_ts":"2026-01-02T03:04:08Z","exit_err":"<nil>","stderr":"warn"}`,
+		`{"_wire":"request","_ts":"2026-01-02T03:05:00Z","prompt":"crash"}`,
+		`{"type":"assistant","message":{"content":[]}}`,
+		`{"_wire":"exit","_ts":"2026-01-02T03:05:01Z","exit_err":"signal: killed"}`,
+		`{"_wire":"request","_ts":"2026-01-02T03:06:00Z","prompt":"truncated"}`,
+		`{"type":"assistant","message":{"content":[]}}`,
+	}, "\n")
+
+	turns, err := parseWireLog(strings.NewReader(log))
+	if err != nil {
+		t.Fatalf("parseWireLog: %v", err)
+	}
+	if len(turns) != 3 {
+		t.Fatalf("got %d turns, want 3", len(turns))
+	}
+
+	first := turns[0]
+	if first.Prompt != "hi" || first.SessionID != "s1" || len(first.Command) != 3 {
+		t.Errorf("turn[0] request fields = %+v", first)
+	}
+	if len(first.Lines) != 2 {
+		t.Errorf("turn[0] has %d lines, want 2 (envelopes excluded)", len(first.Lines))
+	}
+	if got := first.End.Sub(first.Start); got != 3*time.Second {
+		t.Errorf("turn[0] duration = %v, want 3s", got)
+	}
+	if first.ExitErr != "<nil>" || first.Stderr != "warn" {
+		t.Errorf("turn[0] done fields = %q/%q", first.ExitErr, first.Stderr)
+	}
+
+	if !strings.Contains(turns[1].ExitErr, "mid-turn") || !strings.Contains(turns[1].ExitErr, "killed") {
+		t.Errorf("turn[1] should record the mid-turn exit, got %q", turns[1].ExitErr)
+	}
+	if !turns[2].End.IsZero() {
+		t.Error("turn[2] should be open (zero End)")
+	}
+}
+
+func TestReplayBackendPrompts(t *testing.T) {
+	rb, err := LoadReplay(writeWireLog(t, "testdata/text_turn.jsonl", "one", "two"), 2)
+	if err != nil {
+		t.Fatalf("LoadReplay: %v", err)
+	}
+
+	if _, err := rb.StartTurn(TurnRequest{Prompt: "typed"}); err == nil {
+		t.Error("StartTurn without a scripted prompt should fail")
+	}
+
+	p, delay, ok := rb.NextPrompt()
+	if !ok || p != "one" || delay != 0 {
+		t.Errorf("first NextPrompt = %q, %v, %v", p, delay, ok)
+	}
+	if _, _, ok := rb.NextPrompt(); ok {
+		t.Error("NextPrompt should wait until the pending turn starts")
+	}
+	ch, err := rb.StartTurn(TurnRequest{Prompt: p})
+	if err != nil {
+		t.Fatalf("StartTurn: %v", err)
+	}
+	for range ch {
+	}
+
+	// 8s recorded gap between turns, capped at maxReplayGap, at 2x speed
+	p, delay, ok = rb.NextPrompt()
+	if !ok || p != "two" || delay != maxReplayGap/2 {
+		t.Errorf("second NextPrompt = %q, %v, %v", p, delay, ok)
+	}
+}
+
+func TestReplayBackendCancelAtSpeedZero(t *testing.T) {
+	// More events than the stream buffer holds, so an unread replay blocks
+	const events = 200
+	var sb strings.Builder
+	sb.WriteString(`{"_wire":"request","_ts":"2026-01-02T03:04:05Z","prompt":"long"}` + "\n")
+	for range events {
+		sb.WriteString(`{"type":"assistant","message":{"content":[]}}` + "\n")
+	}
+	sb.WriteString(`{"_wire":"done","_ts":"2026-01-02T03:04:08Z","exit_err":"<nil>"}` + "\n")
+	path := filepath.Join(t.TempDir(), "wire.jsonl")
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rb, err := LoadReplay(path, 0)
+	if err != nil {
+		t.Fatalf("LoadReplay: %v", err)
+	}
+	p, _, _ := rb.NextPrompt()
+	ch, err := rb.StartTurn(TurnRequest{Prompt: p})
+	if err != nil {
+		t.Fatalf("StartTurn: %v", err)
+	}
+
+	rb.Cancel()
+	var got int
+	var last StreamMsg
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				if got >= events || (last.Done && last.Err == nil) {
+					t.Errorf("replay ran to the end after Cancel: %d events, last %+v", got, last)
+				}
+				return
+			}
+			if msg.Event != nil {
+				got++
+			}
+			last = msg
+		case <-timeout:
+			t.Fatal("stream not closed after Cancel")
+		}
+	}
+}
+
+func TestE2EReplay(t *testing.T) {
+	rb, err := LoadReplay(writeWireLog(t, "testdata/tool_turn.jsonl", "list files", "again"), 0)
+	if err != nil {
+		t.Fatalf("LoadReplay: %v", err)
+	}
+	m := newTestChat(rb)
+
+	drive(t, m, m.nextScriptedPrompt())
+
+	if len(m.entries) != 4 {
+		t.Fatalf("got %d entries, want both turns replayed", len(m.entries))
+	}
+	if m.entries[0].text != "list files" || m.entries[2].text != "again" {
+		t.Errorf("user entries should carry recorded prompts: %q, %q", m.entries[0].text, m.entries[2].text)
+	}
+	last := m.entries[3]
+	if !last.hasResult || lastBlockIndex(last.blocks, BlockToolUse) < 0 {
+		t.Errorf("replayed turn not finalized with blocks: %+v", last)
+	}
+	if m.totalRequests != 2 || m.sessionID != "7f3c2a10-5b1e-4c8e-9a42-0d6e1f2b3c4d" {
+		t.Errorf("totalRequests=%d sessionID=%q", m.totalRequests, m.sessionID)
+	}
+	if view := m.viewport.View(); !strings.Contains(view, "README.md") {
+		t.Errorf("viewport missing replayed output:\n%s", view)
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SavedSession is a conversation persisted by flawdcode, with everything
+// needed to re-render its history and --resume it.
+type SavedSession struct {
+	ID           string       `json:"id"`         // flawdcode's own ID, the file name
+	SessionID    string       `json:"session_id"` // latest claude session ID
+	Cwd          string       `json:"cwd"`
+	Topic        string       `json:"topic"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+	Turns        int          `json:"turns"`
+	CostUSD      float64      `json:"cost_usd"`
+	InputTokens  int          `json:"input_tokens"`
+	OutputTokens int          `json:"output_tokens"`
+	Model        string       `json:"model,omitempty"`
+	Entries      []savedEntry `json:"entries"`
+
+	// PastSessionIDs are the claude session IDs this conversation has had,
+	// since resuming in print mode can fork a new one.
+	PastSessionIDs []string `json:"past_session_ids,omitempty"`
+	// Transcript is set for sessions read from a CLI transcript rather than the store.
+	Transcript string `json:"-"`
+}
+
+// savedEntry is the on-disk form of a chatEntry. Streaming state is not kept.
+type savedEntry struct {
+	Role          string             `json:"role"`
+	Text          string             `json:"text,omitempty"`
+	Blocks        []ChatBlock        `json:"blocks,omitempty"`
+	Result        *ClaudeResult      `json:"result,omitempty"` // nil unless the turn had a result event
+	Model         string             `json:"model,omitempty"`
+	StopReason    string             `json:"stop_reason,omitempty"`
+	CacheReadTok  int                `json:"cache_read_tokens,omitempty"`
+	DurationMs    int                `json:"duration_ms,omitempty"`
+	DurationAPIMs int                `json:"duration_api_ms,omitempty"`
+	Denials       []PermissionDenial `json:"denials,omitempty"`
+	Interrupted   bool               `json:"interrupted,omitempty"`
+	PermMode      PermissionMode     `json:"perm_mode,omitempty"`
+}
+
+func newSavedEntry(e chatEntry) savedEntry {
+	s := savedEntry{
+		Role:          e.role,
+		Text:          e.text,
+		Blocks:        e.blocks,
+		Model:         e.model,
+		StopReason:    e.stopReason,
+		CacheReadTok:  e.cacheReadTok,
+		DurationMs:    e.durationMs,
+		DurationAPIMs: e.durationAPIMs,
+		Denials:       e.denials,
+		Interrupted:   e.interrupted,
+		PermMode:      e.permMode,
+	}
+	if e.hasResult {
+		result := e.result
+		s.Result = &result
+	}
+	return s
+}
+
+func (s savedEntry) chatEntry() chatEntry {
+	e := chatEntry{
+		role:          s.Role,
+		text:          s.Text,
+		blocks:        s.Blocks,
+		model:         s.Model,
+		stopReason:    s.StopReason,
+		cacheReadTok:  s.CacheReadTok,
+		durationMs:    s.DurationMs,
+		durationAPIMs: s.DurationAPIMs,
+		denials:       s.Denials,
+		interrupted:   s.Interrupted,
+		permMode:      s.PermMode,
+	}
+	if s.Result != nil {
+		e.result = *s.Result
+		e.hasResult = true
+	}
+	return e
+}
+
+// newSessionID returns a sortable, practically unique ID for a new saved session.
+func newSessionID(now time.Time) string {
+	return fmt.Sprintf("%s-%04x", now.Format("20060102-150405"), rand.IntN(0x10000))
+}
+
+// SessionStore keeps saved sessions as one JSON file each in a directory.
+type SessionStore struct {
+	dir string
+}
+
+// NewSessionStore returns a store rooted at dir. The directory is created on first save.
+func NewSessionStore(dir string) *SessionStore {
+	return &SessionStore{dir: dir}
+}
+
+// DefaultSessionStore returns the store under $XDG_DATA_HOME/flawdcode/sessions
+// (~/.local/share/flawdcode/sessions when unset).
+func DefaultSessionStore() (*SessionStore, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewSessionStore(filepath.Join(dir, "sessions")), nil
+}
+
+// dataDir returns flawdcode's XDG data directory.
+func dataDir() (string, error) {
+	if d := os.Getenv("XDG_DATA_HOME"); d != "" {
+		return filepath.Join(d, "flawdcode"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("data dir: %w", err)
+	}
+	return filepath.Join(home, ".local", "share", "flawdcode"), nil
+}
+
+func (s *SessionStore) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// Save writes sess, replacing any previous version. The file is written to a
+// temp file and renamed so a crash never leaves a truncated session behind.
+func (s *SessionStore) Save(sess *SavedSession) error {
+	if sess.ID == "" {
+		return errors.New("save session: missing ID")
+	}
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return fmt.Errorf("save session: %w", err)
+	}
+	data, err := json.Marshal(sess)
+	if err != nil {
+		return fmt.Errorf("save session: %w", err)
+	}
+	tmp, err := os.CreateTemp(s.dir, sess.ID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("save session: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("save session: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("save session: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(sess.ID)); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("save session: %w", err)
+	}
+	return nil
+}
+
+// Load reads the session with the given ID.
+func (s *SessionStore) Load(id string) (*SavedSession, error) {
+	data, err := os.ReadFile(s.path(id))
+	if err != nil {
+		return nil, err
+	}
+	var sess SavedSession
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, fmt.Errorf("load session %s: %w", id, err)
+	}
+	return &sess, nil
+}
+
+// sessionSummary decodes a saved session without its entries, which make up
+// nearly all of the file.
+type sessionSummary struct {
+	SavedSession
+	Entries skipJSON `json:"entries"`
+}
+
+// skipJSON ignores the value it is decoded from.
+type skipJSON struct{}
+
+func (*skipJSON) UnmarshalJSON([]byte) error { return nil }
+
+// loadSummary reads the session with the given ID without its entries.
+func (s *SessionStore) loadSummary(id string) (*SavedSession, error) {
+	data, err := os.ReadFile(s.path(id))
+	if err != nil {
+		return nil, err
+	}
+	var sum sessionSummary
+	if err := json.Unmarshal(data, &sum); err != nil {
+		return nil, fmt.Errorf("load session %s: %w", id, err)
+	}
+	return &sum.SavedSession, nil
+}
+
+// List returns the sessions started in cwd (all sessions if cwd is empty),
+// most recently updated first, without their entries. Unreadable files are skipped.
+func (s *SessionStore) List(cwd string) ([]SavedSession, error) {
+	names, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+	var out []SavedSession
+	for _, de := range names {
+		id, ok := strings.CutSuffix(de.Name(), ".json")
+		if !ok || de.IsDir() {
+			continue
+		}
+		sess, err := s.loadSummary(id)
+		if err != nil || (cwd != "" && sess.Cwd != cwd) {
+			continue
+		}
+		out = append(out, *sess)
+	}
+	slices.SortFunc(out, func(a, b SavedSession) int {
+		return b.UpdatedAt.Compare(a.UpdatedAt)
+	})
+	return out, nil
+}
+
+// FindSessions lists the sessions that can be reopened in cwd, newest first:
+// flawdcode's own, plus CLI transcripts of claude sessions it hasn't saved.
+func FindSessions(store *SessionStore, cwd string) ([]SavedSession, error) {
+	saved, err := store.List(cwd)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool)
+	for _, s := range saved {
+		known[s.SessionID] = true
+		for _, id := range s.PastSessionIDs {
+			known[id] = true
+		}
+	}
+	// Transcripts are a bonus; a missing or unreadable ~/.claude just means none
+	transcripts, _ := ListTranscripts(cwd)
+	for _, t := range transcripts {
+		if !known[t.SessionID] {
+			saved = append(saved, t)
+		}
+	}
+	slices.SortFunc(saved, func(a, b SavedSession) int {
+		return b.UpdatedAt.Compare(a.UpdatedAt)
+	})
+	return saved, nil
+}
+
+// OpenSession loads the full session for a summary returned by FindSessions.
+func OpenSession(store *SessionStore, s SavedSession) (*SavedSession, error) {
+	if s.Transcript != "" {
+		return LoadTranscript(s.Transcript)
+	}
+	return store.Load(s.ID)
+}
+
+// snapshotSession captures the conversation for the store. It returns nil
+// until claude has assigned a session ID, since without one it can't be resumed.
+func (m *ChatModel) snapshotSession() *SavedSession {
+	if m.sessionID == "" {
+		return nil
+	}
+	if m.savedID == "" {
+		now := time.Now()
+		m.savedID = newSessionID(now)
+		m.savedCreatedAt = now
+	}
+	if !slices.Contains(m.savedSessionIDs, m.sessionID) {
+		m.savedSessionIDs = append(m.savedSessionIDs, m.sessionID)
+	}
+	return m.currentSession()
+}
+
+// currentSession returns the conversation as a SavedSession without
+// assigning it a store ID, e.g. for export.
+func (m *ChatModel) currentSession() *SavedSession {
+	sess := &SavedSession{
+		ID:             m.savedID,
+		SessionID:      m.sessionID,
+		Cwd:            m.cwd,
+		Topic:          m.conversationTopic(),
+		CreatedAt:      m.savedCreatedAt,
+		UpdatedAt:      time.Now(),
+		Turns:          m.totalRequests,
+		CostUSD:        m.totalCost,
+		InputTokens:    m.totalInputTok,
+		OutputTokens:   m.totalOutputTok,
+		Model:          m.lastModel,
+		PastSessionIDs: slices.Clone(m.savedSessionIDs),
+	}
+	for _, e := range m.entries {
+		if e.streaming || e.transient {
+			continue
+		}
+		sess.Entries = append(sess.Entries, newSavedEntry(e))
+	}
+	return sess
+}
+
+// saveSession persists the conversation if a store is configured.
+func (m *ChatModel) saveSession() {
+	if m.store == nil {
+		return
+	}
+	sess := m.snapshotSession()
+	if sess == nil {
+		return
+	}
+	if err := m.store.Save(sess); err != nil {
+		m.addNotice("error", err.Error())
+	}
+}
+
+// LoadSession replaces the conversation with a saved one; the next turn
+// resumes its claude session.
+func (m *ChatModel) LoadSession(sess *SavedSession) {
+	m.entries = m.entries[:0]
+	m.entryRenders = nil
+	for _, e := range sess.Entries {
+		m.appendEntries(e.chatEntry())
+	}
+	m.savedID = sess.ID
+	m.savedCreatedAt = sess.CreatedAt
+	m.savedSessionIDs = slices.Clone(sess.PastSessionIDs)
+	m.sessionID = sess.SessionID
+	m.totalRequests = sess.Turns
+	m.totalCost = sess.CostUSD
+	m.totalInputTok = sess.InputTokens
+	m.totalOutputTok = sess.OutputTokens
+	m.lastModel = sess.Model
+	m.refreshViewport()
+	m.viewport.GotoBottom()
+}
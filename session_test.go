@@ -0,0 +1,207 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+)
+
+func TestSessionStore(t *testing.T) {
+	store := NewSessionStore(filepath.Join(t.TempDir(), "sessions"))
+
+	if list, err := store.List(""); err != nil || len(list) != 0 {
+		t.Fatalf("List on missing dir = %v, %v; want empty", list, err)
+	}
+
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	sessions := []*SavedSession{
+		{ID: "old", Cwd: "/proj", Topic: "old one", UpdatedAt: base},
+		{ID: "new", Cwd: "/proj", Topic: "new one", UpdatedAt: base.Add(time.Hour), Turns: 2, CostUSD: 0.5,
+			Entries: []savedEntry{{Role: "user", Text: "hi"}}},
+		{ID: "other", Cwd: "/elsewhere", Topic: "other dir", UpdatedAt: base.Add(2 * time.Hour)},
+	}
+	for _, s := range sessions {
+		if err := store.Save(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Junk in the directory is ignored
+	if err := os.WriteFile(filepath.Join(store.dir, "broken.json"), []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := store.List("/proj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, s := range list {
+		ids = append(ids, s.ID)
+		if s.Entries != nil {
+			t.Errorf("List should drop entries, %s has %d", s.ID, len(s.Entries))
+		}
+	}
+	if !slices.Equal(ids, []string{"new", "old"}) {
+		t.Errorf("List(/proj) = %v, want newest first without other dirs", ids)
+	}
+	if s := list[0]; s.Topic != "new one" || s.Turns != 2 || s.CostUSD != 0.5 {
+		t.Errorf("summary = %+v, want the saved totals", s)
+	}
+	if all, _ := store.List(""); len(all) != 3 {
+		t.Errorf("List(\"\") returned %d sessions, want 3", len(all))
+	}
+
+	if err := store.Save(&SavedSession{}); err == nil {
+		t.Error("Save without an ID should fail")
+	}
+}
+
+func TestE2ESessionPersistence(t *testing.T) {
+	t.Setenv("CLAUDE_CONFIG_DIR", t.TempDir()) // no CLI transcripts
+	argsFile := useFakeClaude(t, fakeClaude{Fixture: "testdata/tool_turn.jsonl"})
+	store := NewSessionStore(t.TempDir())
+
+	m := newTestChat(NewPrintBackend())
+	m.store = store
+	m.cwd = "/tmp/project"
+	sendPrompt(t, m, "list files")
+
+	list, err := store.List("/tmp/project")
+	if err != nil || len(list) != 1 {
+		t.Fatalf("List = %v, %v; want one saved session", list, err)
+	}
+	saved := list[0]
+	if saved.SessionID != "7f3c2a10-5b1e-4c8e-9a42-0d6e1f2b3c4d" || saved.Topic != "list files" ||
+		saved.Turns != 1 || saved.CostUSD != 0.0213 {
+		t.Errorf("saved summary = %+v", saved)
+	}
+
+	// A fresh chat reopens it with the history rendered
+	sess, err := store.Load(saved.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m2 := newTestChat(NewPrintBackend())
+	m2.store = store
+	m2.LoadSession(sess)
+
+	if len(m2.entries) != len(m.entries) {
+		t.Fatalf("got %d entries, want %d", len(m2.entries), len(m.entries))
+	}
+	for i := range m.entries {
+		want, got := m.entries[i], m2.entries[i]
+		if got.role != want.role || got.text != want.text || got.hasResult != want.hasResult ||
+			len(got.blocks) != len(want.blocks) || got.result.CostUSD != want.result.CostUSD {
+			t.Errorf("entry %d = %+v, want %+v", i, got, want)
+		}
+	}
+	if m2.totalRequests != 1 || m2.totalCost != 0.0213 {
+		t.Errorf("stats not restored: requests=%d cost=%v", m2.totalRequests, m2.totalCost)
+	}
+	view := m2.viewport.View()
+	for _, want := range []string{"list files", "⚙ Bash", "There are three files"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("viewport missing %q:\n%s", want, view)
+		}
+	}
+
+	// The next turn resumes the claude session and updates the same file
+	sendPrompt(t, m2, "again")
+	calls := fakeClaudeInvocations(t, argsFile)
+	if idx := slices.Index(calls[1], "--resume"); idx < 0 || calls[1][idx+1] != saved.SessionID {
+		t.Errorf("turn after reopening should resume: %v", calls[1])
+	}
+	list, _ = store.List("")
+	if len(list) != 1 || list[0].Turns != 2 || !list[0].CreatedAt.Equal(saved.CreatedAt) {
+		t.Errorf("reopened session should be updated in place: %+v", list)
+	}
+}
+
+func TestE2EClearStartsFreshStats(t *testing.T) {
+	useFakeClaude(t, fakeClaude{Fixture: "testdata/tool_turn.jsonl"})
+	store := NewSessionStore(t.TempDir())
+	m := newTestChat(NewPrintBackend())
+	m.store = store
+	m.cwd = "/tmp/project"
+	sendPrompt(t, m, "list files")
+	sendPrompt(t, m, "again")
+	oneTurnIn := m.lastInputTok
+
+	m.textarea.SetValue("/clear")
+	m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	if m.totalRequests != 0 || m.totalCost != 0 || m.lastModel != "" {
+		t.Errorf("/clear kept stats: requests=%d cost=%v model=%q", m.totalRequests, m.totalCost, m.lastModel)
+	}
+	sendPrompt(t, m, "after clear")
+
+	list, err := store.List("/tmp/project")
+	if err != nil || len(list) != 2 {
+		t.Fatalf("List = %v, %v; want the cleared and the new session", list, err)
+	}
+	fresh := list[0]
+	if fresh.Topic != "after clear" || fresh.Turns != 1 || fresh.CostUSD != 0.0213 || fresh.InputTokens != oneTurnIn {
+		t.Errorf("session after /clear = %+v, want one turn's totals", fresh)
+	}
+}
+
+func TestE2ESessionSaveFailureShown(t *testing.T) {
+	useFakeClaude(t, fakeClaude{Fixture: "testdata/tool_turn.jsonl"})
+	blocker := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := newTestChat(NewPrintBackend())
+	m.store = NewSessionStore(filepath.Join(blocker, "sessions")) // can't be created
+	sendPrompt(t, m, "list files")
+
+	last := m.entries[len(m.entries)-1]
+	if last.role != "error" || !strings.Contains(last.text, "save session") {
+		t.Errorf("last entry = %+v, want the save error", last)
+	}
+}
+
+func TestSessionPicker(t *testing.T) {
+	t.Setenv("CLAUDE_CONFIG_DIR", t.TempDir()) // no CLI transcripts
+	store := NewSessionStore(t.TempDir())
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, topic := range []string{"first", "second", "third"} {
+		err := store.Save(&SavedSession{ID: topic, Cwd: "/proj", Topic: topic, UpdatedAt: base.Add(time.Duration(i) * time.Hour)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	p, err := NewSessionPicker(store, "/proj")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pal := defaultPalette()
+	pal.PopupSelected = "#123456"
+	view := p.View(80, 20, pal)
+	if strings.Index(view, "third") > strings.Index(view, "first") {
+		t.Errorf("newest session should be listed first:\n%s", view)
+	}
+	if !strings.Contains(view, "48;2;18;52;86") {
+		t.Errorf("selected row should use the palette's popup_selected color:\n%q", view)
+	}
+
+	p.Update(tea.KeyPressMsg{Code: tea.KeyDown})
+	p.Update(tea.KeyPressMsg{Code: tea.KeyDown})
+	p.Update(tea.KeyPressMsg{Code: tea.KeyDown}) // clamped at the end
+	msg := p.Update(tea.KeyPressMsg{Code: tea.KeyEnter})()
+	picked, ok := msg.(sessionPickedMsg)
+	if !ok || picked.Session == nil || picked.Session.ID != "first" {
+		t.Errorf("enter on last row = %#v, want session 'first'", msg)
+	}
+
+	msg = p.Update(tea.KeyPressMsg{Code: tea.KeyEscape})()
+	if picked, ok := msg.(sessionPickedMsg); !ok || picked.Session != nil {
+		t.Errorf("esc = %#v, want a new conversation", msg)
+	}
+}
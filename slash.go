@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Input starting with "/" is a slash command. Local commands are handled by
+// flawdcode; anything else, including the commands claude lists in its init
+// event, is sent to claude verbatim. While a command name is being typed a
+// completion popup above the input lists the matches.
+
+const slashPopupMax = 8 // commands shown at once in the popup
+
+// slashCommand is a command offered in the completion popup.
+type slashCommand struct {
+	Name        string // without the slash
+	Args        string // argument hint, e.g. "[path]"
+	Description string
+	run         func(m *ChatModel, args string) tea.Cmd // nil for claude's commands
+}
+
+// localCommands returns the commands flawdcode handles itself.
+func localCommands() []slashCommand {
+	return []slashCommand{
+		{Name: "clear", Description: "Start a new conversation", run: (*ChatModel).clearCommand},
+		{Name: "export", Args: "[path]", Description: "Write the conversation to a .md, .html or .json file",
+			run: func(m *ChatModel, args string) tea.Cmd { m.exportCommand(args); return nil }},
+		{Name: "model", Args: "[name]", Description: "Show or set the model for the next turns", run: (*ChatModel).modelCommand},
+		{Name: "perm", Args: "[mode]", Description: "Show or set the permission mode", run: (*ChatModel).permCommand},
+		{Name: "theme", Args: "[name]", Description: "Show or switch the color theme", run: (*ChatModel).themeCommand},
+		{Name: "wire", Args: "[on|off]", Description: "Show or toggle the raw wire log", run: (*ChatModel).wireCommand},
+	}
+}
+
+// claudeCommandDescriptions describes claude's built-in commands; the init
+// event only names them.
+var claudeCommandDescriptions = map[string]string{
+	"compact":         "Summarize the conversation to free up context",
+	"context":         "Show what is using the context window",
+	"cost":            "Show the cost and duration of the session",
+	"init":            "Write a CLAUDE.md describing this project",
+	"pr-comments":     "Fetch the comments of a GitHub pull request",
+	"release-notes":   "Show claude's release notes",
+	"review":          "Review a pull request",
+	"security-review": "Review the pending changes for security issues",
+}
+
+// slashCommands returns the local commands followed by claude's.
+func (m *ChatModel) slashCommands() []slashCommand {
+	cmds := localCommands()
+	for _, name := range m.initSlashCommands {
+		if slices.ContainsFunc(cmds, func(c slashCommand) bool { return c.Name == name }) {
+			continue
+		}
+		desc, ok := claudeCommandDescriptions[name]
+		if !ok {
+			desc = "Sent to claude"
+		}
+		cmds = append(cmds, slashCommand{Name: name, Description: desc})
+	}
+	return cmds
+}
+
+// slashMatches returns the commands completing the input, or nil when the
+// popup is closed: the input isn't a bare "/name" or the user dismissed it.
+func (m *ChatModel) slashMatches() []slashCommand {
+	value := m.textarea.Value()
+	if !strings.HasPrefix(value, "/") || strings.ContainsAny(value, " \t\n") || value == m.slashDismissed {
+		return nil
+	}
+	query := strings.ToLower(value[1:])
+	var prefix, contains []slashCommand
+	for _, c := range m.slashCommands() {
+		switch {
+		case strings.HasPrefix(c.Name, query):
+			prefix = append(prefix, c)
+		case strings.Contains(c.Name, query):
+			contains = append(contains, c)
+		}
+	}
+	matches := append(prefix, contains...)
+	if value != m.slashQuery {
+		m.slashQuery = value
+		m.slashSel = 0
+	}
+	m.slashSel = max(0, min(m.slashSel, len(matches)-1))
+	return matches
+}
+
+// handleSlashKey handles the popup's keys: ↑/↓ select, tab completes, enter
+// completes a command that takes arguments (else runs it), esc closes the
+// popup. It reports whether the key was consumed.
+func (m *ChatModel) handleSlashKey(msg tea.KeyPressMsg) (tea.Cmd, bool) {
+	matches := m.slashMatches()
+	if len(matches) == 0 {
+		return nil, false
+	}
+	sel := matches[m.slashSel]
+	switch msg.String() {
+	case "up", "shift+tab":
+		m.slashSel = (m.slashSel + len(matches) - 1) % len(matches)
+	case "down":
+		m.slashSel = (m.slashSel + 1) % len(matches)
+	case "tab":
+		m.textarea.SetValue("/" + sel.Name + " ")
+	case "enter":
+		if sel.Args != "" && m.textarea.Value() != "/"+sel.Name {
+			m.textarea.SetValue("/" + sel.Name + " ")
+			return nil, true
+		}
+		m.textarea.SetValue("/" + sel.Name)
+		return nil, false // run it like any submitted input
+	case "esc":
+		m.slashDismissed = m.textarea.Value()
+	default:
+		return nil, false
+	}
+	return nil, true
+}
+
+// runSlashCommand runs text if it is a local command. Other input, including
+// claude's commands, is left for the caller to send.
+func (m *ChatModel) runSlashCommand(text string) (tea.Cmd, bool) {
+	if !strings.HasPrefix(text, "/") {
+		return nil, false
+	}
+	name, args, _ := strings.Cut(text[1:], " ")
+	for _, c := range localCommands() {
+		if c.Name == name {
+			return c.run(m, strings.TrimSpace(args)), true
+		}
+	}
+	return nil, false
+}
+
+// addNotice shows local command feedback (role "notice" or "error"). While
+// a turn streams it goes above the streaming entry, which must stay last.
+// Notices are transient: they aren't saved with the session or exported.
+func (m *ChatModel) addNotice(role, text string) {
+	e := chatEntry{id: m.newEntryID(), role: role, text: text, transient: true}
+	if n := len(m.entries); n > 0 && m.entries[n-1].streaming {
+		m.entries = slices.Insert(m.entries, n-1, e)
+	} else {
+		m.entries = append(m.entries, e)
+	}
+	m.refreshViewport()
+}
+
+// clearCommand handles "/clear": the transcript is emptied and the next
+// prompt starts a new claude session, saved separately from this one.
+func (m *ChatModel) clearCommand(string) tea.Cmd {
+	if m.streamCh != nil {
+		m.addNotice("notice", "A turn is running; wait for it or press "+m.keys.Interrupt+" before /clear")
+		return nil
+	}
+	if r, ok := m.backend.(sessionResetter); ok {
+		r.ResetSession()
+	}
+	m.entries = nil
+	m.entryRenders = nil
+	m.queued = nil
+	m.expandedCards = make(map[string]bool)
+	m.sessionID = ""
+	m.savedID = ""
+	m.savedCreatedAt = time.Time{}
+	m.savedSessionIDs = nil
+	m.resetSessionStats()
+	m.refreshViewport()
+	m.viewport.GotoTop()
+	return nil
+}
+
+// modelCommand handles "/model [name]"; without a name it opens the picker.
+func (m *ChatModel) modelCommand(args string) tea.Cmd {
+	if args == "" {
+		m.openModelPicker()
+		return nil
+	}
+	m.setModel(args)
+	return nil
+}
+
+// permCommand handles "/perm [mode]". Modes can be given by name or by the
+// short name shown in the divider.
+func (m *ChatModel) permCommand(args string) tea.Cmd {
+	if args == "" {
+		m.addNotice("notice", fmt.Sprintf("Permission mode: %s (one of %s)", m.permMode, permModeNames()))
+		return nil
+	}
+	for _, p := range permModes {
+		if strings.EqualFold(args, string(p)) || strings.EqualFold(args, p.Short()) {
+			m.permMode = p
+			m.addNotice("notice", "Permission mode set to "+string(p))
+			return nil
+		}
+	}
+	m.addNotice("error", fmt.Sprintf("unknown permission mode %q, want one of %s", args, permModeNames()))
+	return nil
+}
+
+// wireCommand handles "/wire [on|off]". A persistent claude process keeps
+// the setting it was started with.
+func (m *ChatModel) wireCommand(args string) tea.Cmd {
+	switch args {
+	case "on":
+		SetWireLogEnabled(true)
+	case "off":
+		SetWireLogEnabled(false)
+	case "":
+	default:
+		m.addNotice("error", "usage: /wire [on|off]")
+		return nil
+	}
+	switch {
+	case !wireLog.enabled.Load():
+		m.addNotice("notice", "Wire log off")
+	case WireLogPath() == "":
+		m.addNotice("notice", "Wire log on, written from the next turn")
+	default:
+		m.addNotice("notice", "Wire log: "+WireLogPath())
+	}
+	return nil
+}
+
+// renderSlashPopup renders the completion popup shown above the input.
+func (m *ChatModel) renderSlashPopup(width int) string {
+	var rows []popupRow
+	for _, c := range m.slashMatches() {
+		rows = append(rows, popupRow{label: strings.TrimSpace("/" + c.Name + " " + c.Args), desc: c.Description})
+	}
+	return m.renderPopup(width, rows, m.slashSel, "↑↓ select · tab complete · enter run · esc close")
+}
+
+// popupRow is one line of a completion popup.
+type popupRow struct {
+	label string
+	desc  string // shown dimmed after the labels
+}
+
+// renderPopup renders a completion popup of up to slashPopupMax rows around
+// the selection, with a key hint below.
+func (m *ChatModel) renderPopup(width int, rows []popupRow, sel int, hint string) string {
+	first := max(0, sel-slashPopupMax+1)
+	last := min(len(rows), first+slashPopupMax)
+
+	labelW := 0
+	for _, r := range rows[first:last] {
+		labelW = max(labelW, lipgloss.Width(r.label))
+	}
+	var lines []string
+	for i := first; i < last; i++ {
+		style := m.stylePopupItem
+		if i == sel {
+			style = m.stylePopupSelected
+		}
+		line := fmt.Sprintf(" %-*s  %s", labelW, rows[i].label, rows[i].desc)
+		lines = append(lines, style.Width(width).Render(ansi.Truncate(line, width, "…")))
+	}
+	if len(rows) > last-first {
+		hint = fmt.Sprintf("%d of %d · %s", last-first, len(rows), hint)
+	}
+	lines = append(lines, m.stylePopupItem.Width(width).Render(m.styleDim.Render(" "+hint)))
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tea "charm.land/bubbletea/v2"
+)
+
+// typeText sends s to m one key at a time.
+func typeText(m *ChatModel, s string) {
+	for _, r := range s {
+		m.Update(tea.KeyPressMsg{Code: r, Text: string(r)})
+	}
+}
+
+func TestSlashMatches(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"/", []string{"clear", "export", "model", "perm", "theme", "wire", "compact", "cost", "review", "security-review"}},
+		{"/c", []string{"clear", "compact", "cost", "security-review"}},
+		{"/co", []string{"compact", "cost"}},
+		{"/review", []string{"review", "security-review"}},
+		{"/nope", nil},
+		{"/export out.md", nil},
+		{"hello", nil},
+	}
+	m := newTestChat(NewPrintBackend())
+	m.initSlashCommands = []string{"compact", "cost", "review", "security-review", "clear"}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			m.textarea.SetValue(tt.input)
+			var got []string
+			for _, c := range m.slashMatches() {
+				got = append(got, c.Name)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("matches for %q = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlashPopup(t *testing.T) {
+	m := newTestChat(NewPrintBackend())
+	m.initSlashCommands = []string{"compact", "cost"}
+	m.textarea.Focus()
+
+	typeText(m, "/co")
+	view := stripANSI(m.View())
+	for _, want := range []string{"/compact", "Summarize the conversation", "/cost", "tab complete"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("popup missing %q:\n%s", want, view)
+		}
+	}
+
+	// ↓ moves the selection, tab completes it
+	m.Update(tea.KeyPressMsg{Code: tea.KeyDown})
+	m.Update(tea.KeyPressMsg{Code: tea.KeyTab})
+	if m.textarea.Value() != "/cost " {
+		t.Errorf("tab completed to %q, want %q", m.textarea.Value(), "/cost ")
+	}
+	if len(m.slashMatches()) != 0 {
+		t.Error("popup should close once arguments are being typed")
+	}
+
+	// enter completes a command that takes arguments instead of running it
+	m.textarea.SetValue("/exp")
+	m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	if m.textarea.Value() != "/export " || len(m.entries) != 0 {
+		t.Errorf("enter should complete /export, input %q, %d entries", m.textarea.Value(), len(m.entries))
+	}
+
+	// esc closes the popup until the input changes
+	m.textarea.SetValue("/")
+	m.Update(tea.KeyPressMsg{Code: tea.KeyEscape})
+	if len(m.slashMatches()) != 0 || m.scrollMode {
+		t.Error("esc should close the popup without entering scroll mode")
+	}
+	typeText(m, "w")
+	if matches := m.slashMatches(); len(matches) != 1 || matches[0].Name != "wire" {
+		t.Errorf("popup should reopen on typing, got %v", matches)
+	}
+}
+
+// resettingBackend records ResetSession calls.
+type resettingBackend struct {
+	*PrintBackend
+	resets int
+}
+
+func (b *resettingBackend) ResetSession() { b.resets++ }
+
+func TestLocalSlashCommands(t *testing.T) {
+	backend := &resettingBackend{PrintBackend: NewPrintBackend()}
+	m := newTestChat(backend)
+	run := func(input string) {
+		t.Helper()
+		m.textarea.SetValue(input)
+		m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+		if m.textarea.Value() != "" {
+			t.Errorf("%s: input not cleared", input)
+		}
+	}
+	lastText := func() string { return m.entries[len(m.entries)-1].text }
+
+	run("/perm plan")
+	if m.permMode != PermPlan {
+		t.Errorf("permMode = %q, want plan", m.permMode)
+	}
+	run("/perm yolo")
+	if m.permMode != PermDontAsk {
+		t.Errorf("short names should work, permMode = %q", m.permMode)
+	}
+	run("/perm nope")
+	if e := m.entries[len(m.entries)-1]; e.role != "error" || !strings.Contains(e.text, "acceptEdits") {
+		t.Errorf("unknown mode should list the modes: %+v", e)
+	}
+
+	run("/model")
+	if !m.modelPicking {
+		t.Error("/model without a name should open the picker")
+	}
+	m.Update(tea.KeyPressMsg{Code: tea.KeyEscape})
+	run("/model opus")
+	if m.model != "opus" || !strings.Contains(lastText(), "opus") {
+		t.Errorf("model = %q, notice %q", m.model, lastText())
+	}
+
+	t.Cleanup(func() { SetWireLogEnabled(false) })
+	run("/wire on")
+	if !wireLog.enabled.Load() || !strings.Contains(lastText(), "Wire log") {
+		t.Errorf("/wire on: enabled %v, notice %q", wireLog.enabled.Load(), lastText())
+	}
+	run("/wire off")
+	if wireLog.enabled.Load() || lastText() != "Wire log off" {
+		t.Errorf("/wire off: enabled %v, notice %q", wireLog.enabled.Load(), lastText())
+	}
+
+	// Notices during a turn go above the streaming entry
+	m.entries = append(m.entries, chatEntry{role: "assistant", streaming: true})
+	m.streamCh = make(chan StreamMsg)
+	run("/perm")
+	if n := len(m.entries); !m.entries[n-1].streaming || m.entries[n-2].role != "notice" {
+		t.Errorf("streaming entry should stay last: %+v", m.entries[n-2:])
+	}
+	run("/clear")
+	if len(m.entries) == 0 || backend.resets != 0 {
+		t.Error("/clear should wait for the turn to end")
+	}
+
+	m.streamCh = nil
+	m.sessionID = "sess-1"
+	m.savedID = "saved-1"
+	run("/clear")
+	if len(m.entries) != 0 || m.sessionID != "" || m.savedID != "" || backend.resets != 1 {
+		t.Errorf("/clear should start over: %d entries, session %q, saved %q, %d resets",
+			len(m.entries), m.sessionID, m.savedID, backend.resets)
+	}
+}
@@ -78,6 +78,16 @@ func (m *ChatModel) parseStreamBlock(entry *chatEntry, ev StreamEvent) {
 
 	// Normal (non-subagent) event processing
 	switch ev.Type {
+	case "assistant":
+		// Remember who is answering, for replies finalized mid-stream
+		var msg struct {
+			Message struct {
+				Model string `json:"model"`
+			} `json:"message"`
+		}
+		if json.Unmarshal([]byte(ev.Raw), &msg) == nil && msg.Message.Model != "" {
+			entry.model = msg.Message.Model
+		}
 	case "content_block_start":
 		m.addContentBlock(entry, ev.Raw)
 	case "stream_event":
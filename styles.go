@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"charm.land/lipgloss/v2"
+)
+
+// Palette holds the colors the chat's cards and text are drawn with. Values
+// are ANSI color numbers ("0"-"255") or hex colors ("#rrggbb"). Each theme
+// has one; the config file's "colors" section overrides them by name.
+type Palette struct {
+	User             string `json:"user"`              // user card border and label
+	Assistant        string `json:"assistant"`         // assistant card border
+	Error            string `json:"error"`             // error card border
+	Tool             string `json:"tool"`              // tool card border and tool names
+	ToolInput        string `json:"tool_input"`        // tool call arguments
+	ToolOutput       string `json:"tool_output"`       // tool results
+	ToolError        string `json:"tool_error"`        // failed tool results
+	Thinking         string `json:"thinking"`          // thinking card border and text
+	Permission       string `json:"permission"`        // permission prompts and denials
+	DiffAdd          string `json:"diff_add"`          // added lines in diffs
+	DiffDel          string `json:"diff_del"`          // removed lines in diffs
+	Dim              string `json:"dim"`               // hints and secondary text
+	Border           string `json:"border"`            // todo panel border
+	CardBackground   string `json:"card_background"`   // user, error, thinking and header cards, popups
+	ResultBackground string `json:"result_background"` // tool output cards
+	PopupSelected    string `json:"popup_selected"`    // selected popup row background
+	PopupText        string `json:"popup_text"`        // selected popup row text
+	Text             string `json:"text"`              // assistant text outside markdown
+}
+
+// defaultPalette returns the colors for a dark terminal background.
+func defaultPalette() Palette {
+	return Palette{
+		User:             "4",
+		Assistant:        "208",
+		Error:            "1",
+		Tool:             "3",
+		ToolInput:        "6",
+		ToolOutput:       "7",
+		ToolError:        "9",
+		Thinking:         "243",
+		Permission:       "11",
+		DiffAdd:          "2",
+		DiffDel:          "1",
+		Dim:              "8",
+		Border:           "8",
+		CardBackground:   "236",
+		ResultBackground: "235",
+		PopupSelected:    "238",
+		PopupText:        "15",
+		Text:             "15",
+	}
+}
+
+// colors returns the palette's colors by their config name.
+func (p *Palette) colors() map[string]*string {
+	return map[string]*string{
+		"user":              &p.User,
+		"assistant":         &p.Assistant,
+		"error":             &p.Error,
+		"tool":              &p.Tool,
+		"tool_input":        &p.ToolInput,
+		"tool_output":       &p.ToolOutput,
+		"tool_error":        &p.ToolError,
+		"thinking":          &p.Thinking,
+		"permission":        &p.Permission,
+		"diff_add":          &p.DiffAdd,
+		"diff_del":          &p.DiffDel,
+		"dim":               &p.Dim,
+		"border":            &p.Border,
+		"card_background":   &p.CardBackground,
+		"result_background": &p.ResultBackground,
+		"popup_selected":    &p.PopupSelected,
+		"popup_text":        &p.PopupText,
+		"text":              &p.Text,
+	}
+}
+
+// with returns p with the colors named in overrides replaced.
+func (p Palette) with(overrides map[string]string) Palette {
+	colors := p.colors()
+	for name, c := range overrides {
+		if dst, ok := colors[name]; ok {
+			*dst = c
+		}
+	}
+	return p
+}
+
+var hexColorRe = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// validColor checks a color value: an ANSI color number or a hex color.
+func validColor(c string) error {
+	if hexColorRe.MatchString(c) {
+		return nil
+	}
+	if n, err := strconv.Atoi(c); err == nil && n >= 0 && n <= 255 {
+		return nil
+	}
+	return fmt.Errorf("%q is not an ANSI color number (0-255) or #rrggbb", c)
+}
+
+// applyPalette rebuilds the cached styles from p.
+func (m *ChatModel) applyPalette(p Palette) {
+	c := lipgloss.Color
+	m.styleUserCard = lipgloss.NewStyle().
+		BorderLeft(true).
+		BorderStyle(lipgloss.ThickBorder()).
+		BorderForeground(c(p.User)).
+		Background(c(p.CardBackground)).
+		PaddingLeft(1).
+		PaddingRight(1)
+	m.styleErrorCard = lipgloss.NewStyle().
+		BorderLeft(true).
+		BorderStyle(lipgloss.ThickBorder()).
+		BorderForeground(c(p.Error)).
+		Background(c(p.CardBackground)).
+		PaddingLeft(1).
+		PaddingRight(1)
+	m.styleToolResultCard = lipgloss.NewStyle().
+		Background(c(p.ResultBackground)).
+		PaddingLeft(1).
+		PaddingRight(1)
+	m.styleDim = lipgloss.NewStyle().
+		Foreground(c(p.Dim))
+	m.styleToolName = lipgloss.NewStyle().
+		Bold(true).
+		Foreground(c(p.Tool))
+	m.styleToolInput = lipgloss.NewStyle().
+		Foreground(c(p.ToolInput))
+	m.styleToolOutput = lipgloss.NewStyle().
+		Foreground(c(p.ToolOutput))
+	m.styleToolErr = lipgloss.NewStyle().
+		Foreground(c(p.ToolError))
+	m.styleUserLabel = lipgloss.NewStyle().
+		Foreground(c(p.User)).
+		Background(c(p.CardBackground)).
+		Bold(true)
+	m.styleThinkingCard = lipgloss.NewStyle().
+		BorderLeft(true).
+		BorderStyle(lipgloss.ThickBorder()).
+		BorderForeground(c(p.Thinking)).
+		Background(c(p.CardBackground)).
+		PaddingLeft(1).
+		PaddingRight(1).
+		Foreground(c(p.Thinking)).
+		Italic(true)
+	m.styleThinkingLabel = lipgloss.NewStyle().
+		Foreground(c(p.Thinking)).
+		Background(c(p.CardBackground)).
+		Bold(true).
+		Italic(true)
+	m.styleHeaderCard = lipgloss.NewStyle().
+		Background(c(p.CardBackground)).
+		PaddingLeft(1).
+		PaddingRight(1)
+	m.styleAssistantCard = lipgloss.NewStyle().
+		BorderLeft(true).
+		BorderStyle(lipgloss.ThickBorder()).
+		BorderForeground(c(p.Assistant)).
+		PaddingLeft(1)
+	m.styleToolCard = lipgloss.NewStyle().
+		BorderLeft(true).
+		BorderStyle(lipgloss.ThickBorder()).
+		BorderForeground(c(p.Tool)).
+		PaddingLeft(1)
+	m.stylePermCard = lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(c(p.Permission)).
+		Background(c(p.CardBackground)).
+		PaddingLeft(1).
+		PaddingRight(1)
+	m.styleDenialCard = lipgloss.NewStyle().
+		BorderLeft(true).
+		BorderStyle(lipgloss.ThickBorder()).
+		BorderForeground(c(p.Permission)).
+		Background(c(p.CardBackground)).
+		PaddingLeft(1).
+		PaddingRight(1)
+	m.styleDiffAdd = lipgloss.NewStyle().
+		Foreground(c(p.DiffAdd))
+	m.styleDiffDel = lipgloss.NewStyle().
+		Foreground(c(p.DiffDel))
+	m.styleTodoPanel = lipgloss.NewStyle().
+		BorderLeft(true).
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderForeground(c(p.Border)).
+		PaddingLeft(1)
+	m.stylePopupItem = lipgloss.NewStyle().
+		Background(c(p.CardBackground))
+	m.stylePopupSelected = lipgloss.NewStyle().
+		Background(c(p.PopupSelected)).
+		Foreground(c(p.PopupText)).
+		Bold(true)
+	m.styleText = lipgloss.NewStyle().
+		Foreground(c(p.Text))
+	m.entryRenders = nil
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	tea "charm.land/bubbletea/v2"
+	chromastyles "github.com/alecthomas/chroma/v2/styles"
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/glamour/styles"
+)
+
+// A theme sets the palette of the cards along with the glamour style for
+// markdown and the chroma style for code previews. The default, "auto",
+// asks the terminal for its background color at startup and picks dark or
+// light; "/theme" switches at runtime. Colors from the config file are
+// applied on top of whichever theme is active.
+
+const autoTheme = "auto"
+
+// Theme is a named set of styles.
+type Theme struct {
+	Name    string
+	Palette Palette
+	Glamour string // glamour standard style
+	Code    string // chroma style for code previews; only its foregrounds are used
+}
+
+// themes are the built-in themes, "auto" aside.
+var themes = []Theme{
+	{Name: "dark", Palette: defaultPalette(), Glamour: styles.DarkStyle, Code: "monokai"},
+	{
+		Name: "light",
+		Palette: Palette{
+			User:             "25",
+			Assistant:        "166",
+			Error:            "160",
+			Tool:             "136",
+			ToolInput:        "30",
+			ToolOutput:       "238",
+			ToolError:        "160",
+			Thinking:         "244",
+			Permission:       "130",
+			DiffAdd:          "28",
+			DiffDel:          "160",
+			Dim:              "245",
+			Border:           "250",
+			CardBackground:   "254",
+			ResultBackground: "255",
+			PopupSelected:    "252",
+			PopupText:        "232",
+			Text:             "235",
+		},
+		Glamour: styles.LightStyle,
+		Code:    "github",
+	},
+	{
+		Name: "high-contrast",
+		Palette: Palette{
+			User:             "14",
+			Assistant:        "11",
+			Error:            "9",
+			Tool:             "11",
+			ToolInput:        "14",
+			ToolOutput:       "15",
+			ToolError:        "9",
+			Thinking:         "15",
+			Permission:       "13",
+			DiffAdd:          "10",
+			DiffDel:          "9",
+			Dim:              "250",
+			Border:           "15",
+			CardBackground:   "0",
+			ResultBackground: "0",
+			PopupSelected:    "15",
+			PopupText:        "0",
+			Text:             "15",
+		},
+		Glamour: styles.DarkStyle,
+		Code:    "monokai",
+	},
+	{
+		Name: "solarized",
+		Palette: Palette{
+			User:             "#268bd2",
+			Assistant:        "#cb4b16",
+			Error:            "#dc322f",
+			Tool:             "#b58900",
+			ToolInput:        "#2aa198",
+			ToolOutput:       "#93a1a1",
+			ToolError:        "#dc322f",
+			Thinking:         "#657b83",
+			Permission:       "#d33682",
+			DiffAdd:          "#859900",
+			DiffDel:          "#dc322f",
+			Dim:              "#586e75",
+			Border:           "#586e75",
+			CardBackground:   "#073642",
+			ResultBackground: "#002b36",
+			PopupSelected:    "#586e75",
+			PopupText:        "#fdf6e3",
+			Text:             "#eee8d5",
+		},
+		Glamour: styles.DarkStyle,
+		Code:    "solarized-dark",
+	},
+}
+
+// themeNames lists the themes that can be chosen, "auto" first.
+func themeNames() []string {
+	names := []string{autoTheme}
+	for _, t := range themes {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+// themeByName returns the built-in theme called name.
+func themeByName(name string) (Theme, bool) {
+	i := slices.IndexFunc(themes, func(t Theme) bool { return t.Name == name })
+	if i < 0 {
+		return Theme{}, false
+	}
+	return themes[i], true
+}
+
+// themeForBackground returns the theme auto picks for a background.
+func themeForBackground(dark bool) Theme {
+	if dark {
+		t, _ := themeByName("dark")
+		return t
+	}
+	t, _ := themeByName("light")
+	return t
+}
+
+// setTheme switches the styles to t, with the configured colors on top.
+func (m *ChatModel) setTheme(t Theme) {
+	m.activeTheme = t
+	m.applyPalette(m.palette())
+	m.codeStyle = chromastyles.Get(t.Code)
+	m.renderer = m.newMarkdownRenderer()
+	m.markdownCache = nil
+	if m.width > 0 {
+		m.refreshViewport()
+	}
+}
+
+// palette returns the active theme's colors with the configured ones on top.
+func (m *ChatModel) palette() Palette {
+	return m.activeTheme.Palette.with(m.colorOverrides)
+}
+
+// newMarkdownRenderer returns a glamour renderer in the active theme's
+// style, wrapping at the transcript width. It returns nil if glamour fails.
+func (m *ChatModel) newMarkdownRenderer() *glamour.TermRenderer {
+	wrap := 73
+	if m.width > 0 {
+		wrap = m.transcriptWidth() - 7
+	}
+	r, err := glamour.NewTermRenderer(
+		glamour.WithStandardStyle(m.activeTheme.Glamour),
+		glamour.WithWordWrap(wrap),
+	)
+	if err != nil {
+		log.Printf("glamour renderer init failed: %v (markdown rendering disabled)", err)
+		return nil
+	}
+	return r
+}
+
+// detectBackground asks the terminal for its background color when the
+// theme is picked automatically; the answer arrives as a BackgroundColorMsg.
+func (m *ChatModel) detectBackground() tea.Cmd {
+	if m.themeName != autoTheme {
+		return nil
+	}
+	return tea.RequestBackgroundColor
+}
+
+// themeCommand handles "/theme [name]".
+func (m *ChatModel) themeCommand(args string) tea.Cmd {
+	if args == "" {
+		current := m.themeName
+		if current == autoTheme {
+			current += " (" + m.activeTheme.Name + ")"
+		}
+		m.addNotice("notice", fmt.Sprintf("Theme: %s (one of %s)", current, strings.Join(themeNames(), ", ")))
+		return nil
+	}
+	if args == autoTheme {
+		m.themeName = autoTheme
+		m.addNotice("notice", "Theme set to auto, following the terminal background")
+		return m.detectBackground()
+	}
+	t, ok := themeByName(args)
+	if !ok {
+		m.addNotice("error", fmt.Sprintf("unknown theme %q, want one of %s", args, strings.Join(themeNames(), ", ")))
+		return nil
+	}
+	m.themeName = t.Name
+	m.setTheme(t)
+	m.addNotice("notice", "Theme set to "+t.Name)
+	return nil
+}
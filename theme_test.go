@@ -0,0 +1,75 @@
+package main
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+	chromastyles "github.com/alecthomas/chroma/v2/styles"
+)
+
+func TestThemesAreComplete(t *testing.T) {
+	for _, th := range themes {
+		t.Run(th.Name, func(t *testing.T) {
+			for name, c := range th.Palette.colors() {
+				if err := validColor(*c); err != nil {
+					t.Errorf("%s: %v", name, err)
+				}
+			}
+			if th.Glamour == "" {
+				t.Error("no glamour style")
+			}
+			if chromastyles.Get(th.Code) == chromastyles.Fallback {
+				t.Errorf("unknown chroma style %q", th.Code)
+			}
+		})
+	}
+}
+
+func TestThemeSelection(t *testing.T) {
+	m := newTestChat(NewPrintBackend())
+	cfg := defaultConfig()
+	cfg.Colors = map[string]string{"assistant": "#ff8700"}
+	m.applyConfig(cfg)
+	if m.activeTheme.Name != "dark" {
+		t.Errorf("auto should start dark until the terminal answers, got %q", m.activeTheme.Name)
+	}
+
+	// auto follows the terminal background
+	m.Update(tea.BackgroundColorMsg{Color: color.White})
+	if m.activeTheme.Name != "light" {
+		t.Errorf("a white background should pick light, got %q", m.activeTheme.Name)
+	}
+	if got := m.styleAssistantCard.GetBorderLeftForeground(); got != lipgloss.Color("#ff8700") {
+		t.Errorf("configured colors should apply over the theme, assistant border = %v", got)
+	}
+	if got := m.styleUserCard.GetBackground(); got != lipgloss.Color("254") {
+		t.Errorf("user card background = %v, want the light theme's", got)
+	}
+
+	// /theme switches and stops following the background
+	m.textarea.SetValue("/theme solarized")
+	m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	if m.activeTheme.Name != "solarized" || m.themeName != "solarized" {
+		t.Errorf("theme = %q (%q), want solarized", m.activeTheme.Name, m.themeName)
+	}
+	m.Update(tea.BackgroundColorMsg{Color: color.Black})
+	if m.activeTheme.Name != "solarized" {
+		t.Errorf("a chosen theme should ignore the background, got %q", m.activeTheme.Name)
+	}
+
+	m.textarea.SetValue("/theme neon")
+	m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	if e := m.entries[len(m.entries)-1]; e.role != "error" || !strings.Contains(e.text, "high-contrast") {
+		t.Errorf("unknown theme should list the themes: %+v", e)
+	}
+
+	m.textarea.SetValue("/theme auto")
+	m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	m.Update(tea.BackgroundColorMsg{Color: color.Black})
+	if m.themeName != autoTheme || m.activeTheme.Name != "dark" {
+		t.Errorf("auto again: theme = %q (%q), want dark", m.activeTheme.Name, m.themeName)
+	}
+}
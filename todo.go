@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// TodoWrite replaces the agent's whole todo list on every call, so the
+// latest call with a complete input is the current plan. It is shown inline
+// as a checklist and, with ctrl+t, in a panel pinned beside the transcript.
+
+const (
+	todoPanelMaxWidth = 40
+	todoPanelMinInner = 80 // narrower terminals never show the panel
+)
+
+// TodoItem is one entry of a TodoWrite list.
+type TodoItem struct {
+	Content    string `json:"content"`
+	Status     string `json:"status"` // "pending", "in_progress" or "completed"
+	ActiveForm string `json:"activeForm"`
+}
+
+// parseTodos returns the list from a TodoWrite input, and false if the input
+// is incomplete or not a todo list.
+func parseTodos(input string) ([]TodoItem, bool) {
+	var fields struct {
+		Todos []TodoItem `json:"todos"`
+	}
+	if json.Unmarshal([]byte(input), &fields) != nil || fields.Todos == nil {
+		return nil, false
+	}
+	return fields.Todos, true
+}
+
+// latestTodos returns the list from the most recent complete TodoWrite call
+// of the main agent, or nil if there is none.
+func latestTodos(entries []chatEntry) []TodoItem {
+	for i := len(entries) - 1; i >= 0; i-- {
+		blocks := entries[i].blocks
+		for j := len(blocks) - 1; j >= 0; j-- {
+			b := blocks[j]
+			if b.Kind != BlockToolUse || b.ToolName != "TodoWrite" {
+				continue
+			}
+			if todos, ok := parseTodos(b.ToolInput); ok {
+				return todos
+			}
+		}
+	}
+	return nil
+}
+
+// todoProgress returns how many todos are completed out of the total.
+func todoProgress(todos []TodoItem) string {
+	done := 0
+	for _, t := range todos {
+		if t.Status == "completed" {
+			done++
+		}
+	}
+	return fmt.Sprintf("%d/%d done", done, len(todos))
+}
+
+// renderTodoItems renders a checklist, one todo per line, truncated to width:
+// ○ pending, ◐ in progress (by its active form), ✓ completed.
+func (m *ChatModel) renderTodoItems(sb *strings.Builder, todos []TodoItem, indent string, width int) {
+	for _, t := range todos {
+		switch t.Status {
+		case "completed":
+			sb.WriteString(indent + m.styleDim.Strikethrough(true).Render("✓ "+truncateRunes(t.Content, width-2)) + "\n")
+		case "in_progress":
+			text := t.ActiveForm
+			if text == "" {
+				text = t.Content
+			}
+			sb.WriteString(indent + m.styleToolName.Render("◐ "+truncateRunes(text, width-2)) + "\n")
+		default:
+			sb.WriteString(indent + m.styleToolOutput.Render("○ "+truncateRunes(t.Content, width-2)) + "\n")
+		}
+	}
+}
+
+// todoPanelWidth returns the width of the pinned todo panel for an inner
+// width, or 0 when it is hidden.
+func (m *ChatModel) todoPanelWidth(innerW int) int {
+	if !m.showTodos || innerW < todoPanelMinInner {
+		return 0
+	}
+	return min(todoPanelMaxWidth, innerW/3)
+}
+
+// transcriptWidth returns the width available to the viewport, leaving
+// room for the todo panel and the column between them.
+func (m *ChatModel) transcriptWidth() int {
+	innerW := m.width - m.padH*2
+	if innerW < 20 {
+		innerW = 20
+	}
+	if w := m.todoPanelWidth(innerW); w > 0 {
+		innerW -= w + 1
+	}
+	return innerW
+}
+
+// renderTodoPanel renders the pinned todo panel at the given size.
+func (m *ChatModel) renderTodoPanel(width, height int) string {
+	var sb strings.Builder
+	textWidth := width - 2 // border + padding
+	if len(m.todos) == 0 {
+		sb.WriteString(m.styleToolName.Render("Todos") + "\n")
+		sb.WriteString(m.styleDim.Render(truncateRunes("No todo list yet", textWidth)) + "\n")
+	} else {
+		sb.WriteString(m.styleToolName.Render("Todos") + " " + m.styleDim.Render(todoProgress(m.todos)) + "\n")
+		m.renderTodoItems(&sb, m.todos, "", textWidth)
+	}
+
+	lines := strings.Split(strings.TrimRight(sb.String(), "\n"), "\n")
+	if height > 0 && len(lines) > height {
+		lines = append(lines[:height-1], m.styleDim.Render(fmt.Sprintf("… %d more", len(lines)-height+1)))
+	}
+	return m.styleTodoPanel.Width(width).Height(height).Render(strings.Join(lines, "\n"))
+}
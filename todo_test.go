@@ -0,0 +1,111 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tea "charm.land/bubbletea/v2"
+)
+
+const todoInput = `{"todos":[` +
+	`{"content":"Find the config loader","status":"completed","activeForm":"Finding the config loader"},` +
+	`{"content":"Add a timeout option","status":"in_progress","activeForm":"Adding a timeout option"},` +
+	`{"content":"Run the tests","status":"pending","activeForm":"Running the tests"}]}`
+
+func TestLatestTodos(t *testing.T) {
+	todoWrite := func(input string) ChatBlock {
+		return ChatBlock{Kind: BlockToolUse, ToolName: "TodoWrite", ToolInput: input}
+	}
+	tests := []struct {
+		name    string
+		entries []chatEntry
+		want    string // contents joined by ","
+	}{
+		{name: "none", entries: []chatEntry{{role: "user", text: "hi"}}},
+		{
+			name: "latest call wins",
+			entries: []chatEntry{
+				{role: "assistant", blocks: []ChatBlock{todoWrite(`{"todos":[{"content":"old","status":"pending"}]}`)}},
+				{role: "assistant", blocks: []ChatBlock{todoWrite(todoInput), {Kind: BlockText, Text: "done"}}},
+			},
+			want: "Find the config loader,Add a timeout option,Run the tests",
+		},
+		{
+			name: "partial input while streaming",
+			entries: []chatEntry{
+				{role: "assistant", blocks: []ChatBlock{todoWrite(`{"todos":[{"content":"old","status":"pending"}]}`)}},
+				{role: "assistant", streaming: true, blocks: []ChatBlock{todoWrite(`{"todos":[{"content":"ne`)}},
+			},
+			want: "old",
+		},
+		{
+			name: "cleared list",
+			entries: []chatEntry{
+				{role: "assistant", blocks: []ChatBlock{todoWrite(todoInput), todoWrite(`{"todos":[]}`)}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, todo := range latestTodos(tt.entries) {
+				got = append(got, todo.Content)
+			}
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("latestTodos = %v, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTodoChecklist(t *testing.T) {
+	m := newTestChat(NewPrintBackend())
+	m.SetSize(120, 30)
+	m.appendEntries([]chatEntry{
+		{role: "user", text: "add a timeout"},
+		{role: "assistant", blocks: []ChatBlock{
+			{Kind: BlockToolUse, ToolName: "TodoWrite", ToolID: "t1", ToolInput: todoInput},
+			{Kind: BlockToolResult, ToolID: "t1", ToolOutput: "Todos have been modified successfully."},
+		}},
+	}...)
+	m.refreshViewport()
+
+	view := stripANSI(m.View())
+	for _, want := range []string{
+		"⚙ TodoWrite 1/3 done",
+		"✓ Find the config loader",
+		"◐ Adding a timeout option",
+		"○ Run the tests",
+		"ctrl+t: todos",
+	} {
+		if !strings.Contains(view, want) {
+			t.Errorf("view missing %q:\n%s", want, view)
+		}
+	}
+	if strings.Contains(view, "modified successfully") || strings.Contains(view, `"todos"`) {
+		t.Errorf("TodoWrite should render as a checklist, not its input or result:\n%s", view)
+	}
+
+	// ctrl+t pins the panel and narrows the transcript
+	fullWidth := m.viewport.Width()
+	m.Update(tea.KeyPressMsg{Code: 't', Mod: tea.ModCtrl})
+	if !m.showTodos || m.viewport.Width() >= fullWidth {
+		t.Fatalf("ctrl+t: showTodos=%v viewport width %d, was %d", m.showTodos, m.viewport.Width(), fullWidth)
+	}
+	lines := strings.Split(stripANSI(m.View()), "\n")
+	var panelLine string
+	for _, l := range lines {
+		if strings.Contains(l, "Todos 1/3 done") {
+			panelLine = l
+		}
+	}
+	if panelLine == "" || strings.Index(panelLine, "Todos") < m.viewport.Width() {
+		t.Errorf("panel header not pinned right of the transcript:\n%s", strings.Join(lines, "\n"))
+	}
+
+	// Too narrow for a side panel
+	m.SetSize(70, 30)
+	if m.todoPanelWidth(m.width-m.padH*2) != 0 || strings.Contains(stripANSI(m.View()), "Todos 1/3") {
+		t.Error("panel should be hidden on narrow terminals")
+	}
+}
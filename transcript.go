@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The claude CLI keeps its own transcript of every session as JSONL under
+// ~/.claude/projects/<cwd-slug>/<session>.jsonl. Records carry the same
+// user/assistant messages as stream-json output, with camelCase metadata
+// around them, so they are normalized into StreamEvents and run through
+// ExtractBlocks. Subagent records are flagged isSidechain and tagged with an
+// agentId; older CLIs write them inline, newer ones to separate agent files.
+
+// transcriptRecord is the subset of a transcript line flawdcode needs.
+type transcriptRecord struct {
+	Type        string    `json:"type"`
+	IsSidechain bool      `json:"isSidechain"`
+	IsMeta      bool      `json:"isMeta"`
+	AgentID     string    `json:"agentId"`
+	SessionID   string    `json:"sessionId"`
+	Cwd         string    `json:"cwd"`
+	Timestamp   time.Time `json:"timestamp"`
+	Message     struct {
+		Model      string          `json:"model"`
+		StopReason string          `json:"stop_reason"`
+		Content    json.RawMessage `json:"content"`
+	} `json:"message"`
+	ToolUseResult json.RawMessage `json:"toolUseResult"`
+}
+
+// claudeProjectsDir returns where the CLI keeps per-project transcripts.
+func claudeProjectsDir() (string, error) {
+	if d := os.Getenv("CLAUDE_CONFIG_DIR"); d != "" {
+		return filepath.Join(d, "projects"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("claude projects dir: %w", err)
+	}
+	return filepath.Join(home, ".claude", "projects"), nil
+}
+
+// projectSlug mirrors how the CLI names a project's transcript directory:
+// every character other than a letter or digit becomes a dash.
+func projectSlug(cwd string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '-'
+	}, cwd)
+}
+
+// ListTranscripts returns summaries of the CLI sessions recorded for cwd,
+// without their entries; OpenSession loads a chosen one in full.
+// Transcripts with no prompts (e.g. only a summary) are skipped.
+func ListTranscripts(cwd string) ([]SavedSession, error) {
+	root, err := claudeProjectsDir()
+	if err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(root, projectSlug(cwd), "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	var out []SavedSession
+	for _, path := range paths {
+		if strings.HasPrefix(filepath.Base(path), "agent-") {
+			continue // subagent transcript, read through its parent
+		}
+		sess, err := transcriptSummary(path)
+		if err != nil || sess.Turns == 0 {
+			continue
+		}
+		out = append(out, *sess)
+	}
+	return out, nil
+}
+
+// transcriptSummary reads what the session picker shows from the transcript
+// at path: session, dates, prompt count, topic and model. Unlike
+// LoadTranscript it builds no entries and doesn't open subagent files, and
+// only user records are decoded in full.
+func transcriptSummary(path string) (*SavedSession, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// header is a transcriptRecord without the message content
+	type header struct {
+		Type        string    `json:"type"`
+		IsSidechain bool      `json:"isSidechain"`
+		SessionID   string    `json:"sessionId"`
+		Cwd         string    `json:"cwd"`
+		Timestamp   time.Time `json:"timestamp"`
+		Message     struct {
+			Model string `json:"model"`
+		} `json:"message"`
+	}
+	sess := &SavedSession{Transcript: path}
+	var topic []chatEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var header header
+		if json.Unmarshal(scanner.Bytes(), &header) != nil || header.Type != "user" && header.Type != "assistant" {
+			continue
+		}
+		if sess.SessionID == "" {
+			sess.SessionID = header.SessionID
+			sess.Cwd = header.Cwd
+		}
+		if !header.Timestamp.IsZero() {
+			if sess.CreatedAt.IsZero() {
+				sess.CreatedAt = header.Timestamp
+			}
+			sess.UpdatedAt = header.Timestamp
+		}
+		switch {
+		case header.IsSidechain:
+		case header.Type == "assistant":
+			if m := header.Message.Model; m != "" && m != "<synthetic>" {
+				sess.Model = m
+			}
+		default:
+			var rec transcriptRecord
+			if json.Unmarshal(scanner.Bytes(), &rec) != nil {
+				continue
+			}
+			if text, ok := transcriptPrompt(rec); ok {
+				sess.Turns++
+				if topic == nil {
+					topic = []chatEntry{{role: "user", text: text}}
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if sess.SessionID == "" {
+		sess.SessionID = strings.TrimSuffix(filepath.Base(path), ".jsonl")
+	}
+	sess.Topic = entriesTopic(topic)
+	return sess, nil
+}
+
+// LoadTranscript parses the CLI transcript at path, reading subagent
+// transcripts from next to it as needed.
+func LoadTranscript(path string) (*SavedSession, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dir := filepath.Dir(path)
+	session := strings.TrimSuffix(filepath.Base(path), ".jsonl")
+	subagent := func(agentID string) []string {
+		name := "agent-" + agentID + ".jsonl"
+		for _, p := range []string{filepath.Join(dir, session, "subagents", name), filepath.Join(dir, name)} {
+			if lines, err := readJSONLines(p); err == nil {
+				return lines
+			}
+		}
+		return nil
+	}
+
+	sess, err := parseTranscript(f, subagent)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if sess.SessionID == "" {
+		sess.SessionID = session
+	}
+	sess.Transcript = path
+	return sess, nil
+}
+
+// readJSONLines returns the non-empty lines of a JSONL file.
+func readJSONLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// parseTranscript builds chat entries from transcript lines: one user entry
+// per prompt and one assistant entry for everything up to the next prompt.
+// subagent returns the lines of a subagent transcript stored in its own file.
+func parseTranscript(r io.Reader, subagent func(agentID string) []string) (*SavedSession, error) {
+	type line struct {
+		raw string
+		rec transcriptRecord
+	}
+	var lines []line
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var rec transcriptRecord
+		if json.Unmarshal(scanner.Bytes(), &rec) != nil {
+			continue
+		}
+		lines = append(lines, line{raw: scanner.Text(), rec: rec})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// Task results name the agent that ran them; subagent records only
+	// carry the agent ID, so map it back to the Task tool_use.
+	agentTask := make(map[string]string)
+	for _, l := range lines {
+		if l.rec.Type != "user" || l.rec.IsSidechain {
+			continue
+		}
+		if agentID, toolID := taskAgent(l.rec); agentID != "" {
+			agentTask[agentID] = toolID
+		}
+	}
+
+	sess := &SavedSession{}
+	var events []StreamEvent
+	var model, stopReason string
+	inlineAgents := make(map[string]bool)
+
+	flush := func() {
+		if len(events) == 0 {
+			return
+		}
+		resp := &ClaudeResponse{Events: events}
+		sess.Entries = append(sess.Entries, newSavedEntry(chatEntry{
+			role:       "assistant",
+			text:       resp.AssistantText(),
+			blocks:     resp.ExtractBlocks(),
+			model:      model,
+			stopReason: stopReason,
+		}))
+		events = nil
+	}
+	addEvent := func(raw string, rec transcriptRecord, parentToolID string) {
+		events = append(events, StreamEvent{
+			Type:       rec.Type,
+			Raw:        normalizeTranscriptLine(raw, parentToolID),
+			ReceivedAt: rec.Timestamp,
+		})
+	}
+
+	for _, l := range lines {
+		rec := l.rec
+		if rec.Type != "user" && rec.Type != "assistant" {
+			continue
+		}
+		if sess.SessionID == "" {
+			sess.SessionID = rec.SessionID
+			sess.Cwd = rec.Cwd
+		}
+		if !rec.Timestamp.IsZero() {
+			if sess.CreatedAt.IsZero() {
+				sess.CreatedAt = rec.Timestamp
+			}
+			sess.UpdatedAt = rec.Timestamp
+		}
+
+		if rec.IsSidechain {
+			if toolID := agentTask[rec.AgentID]; toolID != "" {
+				inlineAgents[rec.AgentID] = true
+				addEvent(l.raw, rec, toolID)
+			}
+			continue
+		}
+
+		if rec.Type == "user" {
+			if text, ok := transcriptPrompt(rec); ok {
+				flush()
+				sess.Entries = append(sess.Entries, savedEntry{Role: "user", Text: text})
+				sess.Turns++
+				continue
+			}
+			if len(toolResultIDs(rec)) == 0 {
+				continue // meta record or slash command output
+			}
+			// A Task result whose subagent lives in its own file: its
+			// records go in just before the result, after the Task call.
+			if agentID, toolID := taskAgent(rec); agentID != "" && !inlineAgents[agentID] && subagent != nil {
+				for _, raw := range subagent(agentID) {
+					var sub transcriptRecord
+					if json.Unmarshal([]byte(raw), &sub) == nil && (sub.Type == "user" || sub.Type == "assistant") {
+						addEvent(raw, sub, toolID)
+					}
+				}
+			}
+		}
+
+		if rec.Type == "assistant" {
+			if rec.Message.Model != "" && rec.Message.Model != "<synthetic>" {
+				model = rec.Message.Model
+			}
+			if rec.Message.StopReason != "" {
+				stopReason = rec.Message.StopReason
+			}
+		}
+		addEvent(l.raw, rec, "")
+	}
+	flush()
+
+	sess.Topic = entriesTopic(transcriptChatEntries(sess.Entries))
+	sess.Model = model
+	return sess, nil
+}
+
+// transcriptChatEntries converts saved entries back for helpers that take chatEntry.
+func transcriptChatEntries(saved []savedEntry) []chatEntry {
+	entries := make([]chatEntry, len(saved))
+	for i, s := range saved {
+		entries[i] = s.chatEntry()
+	}
+	return entries
+}
+
+// transcriptPrompt returns the text of a user record typed by the user, and
+// false for tool results, meta records and local slash command output.
+func transcriptPrompt(rec transcriptRecord) (string, bool) {
+	if rec.IsMeta {
+		return "", false
+	}
+	var text string
+	var s string
+	var blocks []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	switch {
+	case json.Unmarshal(rec.Message.Content, &s) == nil:
+		text = s
+	case json.Unmarshal(rec.Message.Content, &blocks) == nil:
+		var parts []string
+		for _, b := range blocks {
+			switch b.Type {
+			case "tool_result":
+				return "", false
+			case "text":
+				parts = append(parts, b.Text)
+			}
+		}
+		text = strings.Join(parts, "\n")
+	}
+	text = strings.TrimSpace(text)
+	if text == "" || strings.HasPrefix(text, "<command-") || strings.HasPrefix(text, "<local-command-") {
+		return "", false
+	}
+	return text, true
+}
+
+// taskAgent returns the subagent ID and Task tool_use ID from a Task result record.
+func taskAgent(rec transcriptRecord) (agentID, toolID string) {
+	var result struct {
+		AgentID string `json:"agentId"`
+	}
+	if len(rec.ToolUseResult) == 0 || json.Unmarshal(rec.ToolUseResult, &result) != nil || result.AgentID == "" {
+		return "", ""
+	}
+	if ids := toolResultIDs(rec); len(ids) > 0 {
+		return result.AgentID, ids[0]
+	}
+	return "", ""
+}
+
+// toolResultIDs returns the tool_use IDs answered by the tool_result blocks of a user record.
+func toolResultIDs(rec transcriptRecord) []string {
+	var blocks []struct {
+		Type      string `json:"type"`
+		ToolUseID string `json:"tool_use_id"`
+	}
+	if json.Unmarshal(rec.Message.Content, &blocks) != nil {
+		return nil
+	}
+	var ids []string
+	for _, b := range blocks {
+		if b.Type == "tool_result" {
+			ids = append(ids, b.ToolUseID)
+		}
+	}
+	return ids
+}
+
+// normalizeTranscriptLine rewrites a transcript record into stream-json
+// shape: toolUseResult becomes tool_use_result, and subagent records get the
+// parent_tool_use_id that ExtractBlocks routes on.
+func normalizeTranscriptLine(raw, parentToolID string) string {
+	var obj map[string]json.RawMessage
+	if json.Unmarshal([]byte(raw), &obj) != nil {
+		return raw
+	}
+	if r, ok := obj["toolUseResult"]; ok {
+		obj["tool_use_result"] = r
+		delete(obj, "toolUseResult")
+	}
+	if parentToolID != "" {
+		obj["parent_tool_use_id"], _ = json.Marshal(parentToolID)
+	}
+	out, err := json.Marshal(obj)
+	if err != nil {
+		return raw
+	}
+	return string(out)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const transcriptSession = "5d2f8c1e-9b3a-4e7d-8f21-6a0c4b9e2d17"
+
+func TestProjectSlug(t *testing.T) {
+	tests := []struct {
+		cwd  string
+		want string
+	}{
+		{"/tmp/project", "-tmp-project"},
+		{"/home/me/src/github.com/boozedog/flawdcode", "-home-me-src-github-com-boozedog-flawdcode"},
+		{"/Users/me/my_app v2", "-Users-me-my-app-v2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cwd, func(t *testing.T) {
+			if got := projectSlug(tt.cwd); got != tt.want {
+				t.Errorf("projectSlug(%q) = %q, want %q", tt.cwd, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadTranscript(t *testing.T) {
+	path := filepath.Join("testdata", "claude", "projects", "-tmp-project", transcriptSession+".jsonl")
+	sess, err := LoadTranscript(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sess.SessionID != transcriptSession || sess.Cwd != "/tmp/project" || sess.Transcript != path {
+		t.Errorf("session = %s in %s from %s", sess.SessionID, sess.Cwd, sess.Transcript)
+	}
+	if sess.Topic != "find the config loader" || sess.Turns != 2 || sess.Model != "claude-sonnet-4-5" {
+		t.Errorf("topic=%q turns=%d model=%q", sess.Topic, sess.Turns, sess.Model)
+	}
+	if want := time.Date(2026, 3, 2, 10, 7, 0, 0, time.UTC); !sess.UpdatedAt.Equal(want) {
+		t.Errorf("UpdatedAt = %v, want %v", sess.UpdatedAt, want)
+	}
+
+	// Meta records and local command output are not prompts
+	var roles []string
+	for _, e := range sess.Entries {
+		roles = append(roles, e.Role)
+	}
+	if strings.Join(roles, ",") != "user,assistant,user,assistant" {
+		t.Fatalf("roles = %v", roles)
+	}
+
+	first := sess.Entries[1].chatEntry()
+	if first.text != "The loader is in config.go." || first.stopReason != "end_turn" || first.hasResult {
+		t.Errorf("assistant entry text=%q stop=%q hasResult=%v", first.text, first.stopReason, first.hasResult)
+	}
+	var kinds []string
+	for _, b := range first.blocks {
+		kinds = append(kinds, string(b.Kind))
+	}
+	if strings.Join(kinds, ",") != "thinking,text,tool_use,tool_result,text" {
+		t.Fatalf("block kinds = %v", kinds)
+	}
+
+	task := first.blocks[2]
+	if !task.IsTask || task.TaskDescription != "Find config loader" || task.TaskSubagentType != "Explore" {
+		t.Errorf("Task block = %+v", task)
+	}
+	if task.TaskMeta == nil || task.TaskMeta.AgentID != "a1b2" || task.TaskMeta.TotalToolUseCount != 1 {
+		t.Errorf("Task meta = %+v", task.TaskMeta)
+	}
+	// Subagent records from the agent file are routed into the Task
+	if len(task.TaskSubBlocks) != 2 || task.TaskSubBlocks[0].ToolName != "Grep" ||
+		task.TaskSubBlocks[1].ToolOutput != "config.go:12:func LoadConfig() {" {
+		t.Errorf("Task sub-blocks = %+v", task.TaskSubBlocks)
+	}
+	if out := first.blocks[3].ToolOutput; out != "Found it in config.go" {
+		t.Errorf("Task result = %q, want agentId stripped", out)
+	}
+}
+
+func TestTranscriptSummary(t *testing.T) {
+	path := filepath.Join("testdata", "claude", "projects", "-tmp-project", transcriptSession+".jsonl")
+	full, err := LoadTranscript(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, err := transcriptSummary(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The summary agrees with a full load on everything the picker shows
+	full.Entries = nil
+	if !reflect.DeepEqual(sum, full) {
+		t.Errorf("summary = %+v\nwant      %+v", sum, full)
+	}
+}
+
+func TestParseTranscriptInlineSidechain(t *testing.T) {
+	// Older CLIs write subagent records inline, before the Task result
+	lines := []string{
+		`{"type":"user","sessionId":"s1","message":{"role":"user","content":"go"}}`,
+		`{"type":"assistant","message":{"model":"m","content":[{"type":"tool_use","id":"toolu_t","name":"Task","input":{"description":"d","prompt":"p"}}]}}`,
+		`{"type":"assistant","isSidechain":true,"agentId":"ag","message":{"content":[{"type":"tool_use","id":"toolu_r","name":"Read","input":{"file_path":"a.go"}}]}}`,
+		`{"type":"user","isSidechain":true,"agentId":"ag","message":{"content":[{"type":"tool_result","tool_use_id":"toolu_r","content":"package a"}]}}`,
+		`{"type":"assistant","isSidechain":true,"agentId":"unknown","message":{"content":[{"type":"tool_use","id":"toolu_x","name":"Bash","input":{}}]}}`,
+		`{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"toolu_t","content":"done"}]},"toolUseResult":{"agentId":"ag"}}`,
+		`not json`,
+	}
+	loaded := false
+	sess, err := parseTranscript(strings.NewReader(strings.Join(lines, "\n")), func(string) []string {
+		loaded = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded {
+		t.Error("inline subagent records should not be looked up in agent files")
+	}
+	if len(sess.Entries) != 2 || sess.SessionID != "s1" {
+		t.Fatalf("entries = %+v", sess.Entries)
+	}
+	task := sess.Entries[1].Blocks[0]
+	if len(task.TaskSubBlocks) != 2 || task.TaskSubBlocks[0].ToolName != "Read" {
+		t.Errorf("Task sub-blocks = %+v, want the Read call only", task.TaskSubBlocks)
+	}
+}
+
+func TestFindSessionsMergesTranscripts(t *testing.T) {
+	t.Setenv("CLAUDE_CONFIG_DIR", filepath.Join("testdata", "claude"))
+	store := NewSessionStore(t.TempDir())
+	if err := store.Save(&SavedSession{ID: "mine", SessionID: "other", Cwd: "/tmp/project", UpdatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := FindSessions(store, "/tmp/project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != "mine" || list[1].SessionID != transcriptSession || list[1].Entries != nil {
+		t.Fatalf("FindSessions = %+v, want saved session then transcript summary", list)
+	}
+	sess, err := OpenSession(store, list[1])
+	if err != nil || len(sess.Entries) != 4 {
+		t.Fatalf("OpenSession = %v, %v", sess, err)
+	}
+
+	// Once flawdcode has saved the claude session, the transcript is hidden
+	if err := store.Save(&SavedSession{ID: "imported", SessionID: "forked", PastSessionIDs: []string{transcriptSession, "forked"},
+		Cwd: "/tmp/project"}); err != nil {
+		t.Fatal(err)
+	}
+	list, _ = FindSessions(store, "/tmp/project")
+	for _, s := range list {
+		if s.Transcript != "" {
+			t.Errorf("transcript listed after being saved: %+v", s)
+		}
+	}
+
+	// Reopening a transcript renders its history
+	m := newTestChat(NewPrintBackend())
+	m.LoadSession(sess)
+	view := stripANSI(m.viewport.View())
+	for _, want := range []string{"find the config loader", "The loader is in config.go.", "You're welcome!"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("viewport missing %q:\n%s", want, view)
+		}
+	}
+	if m.sessionID != transcriptSession {
+		t.Errorf("sessionID = %q, want the transcript's", m.sessionID)
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"strings"
+
+	"charm.land/bubbles/v2/key"
+	"charm.land/bubbles/v2/viewport"
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// The transcript viewport is virtualized: its content is a list of items,
+// one per chat entry, and only items overlapping the visible window are
+// materialized. Items whose cached rendering is out of date (after a resize
+// or theme change) keep their old height as an estimate until they scroll
+// into view, so long sessions re-render a screenful instead of everything.
+
+// vpItem is one entry's lines in the viewport. A stale item has a height
+// but no usable lines; load renders it when it becomes visible.
+type vpItem struct {
+	lines  []string
+	zones  []cardZone // clickable cards, relative to the item's first line
+	height int
+	fresh  bool
+}
+
+// virtualViewport is a scrollable list of items rendered on demand. It
+// mirrors the parts of bubbles' viewport the chat uses.
+type virtualViewport struct {
+	KeyMap          viewport.KeyMap
+	MouseWheelDelta int
+
+	width, height int
+	yOffset       int
+	items         []vpItem
+	offsets       []int // offsets[i] is the first line of item i; offsets[len(items)] the total
+	load          func(i int) vpItem
+}
+
+func newVirtualViewport(width, height int) virtualViewport {
+	km := viewport.DefaultKeyMap()
+	km.Left = key.NewBinding(key.WithDisabled())
+	km.Right = key.NewBinding(key.WithDisabled())
+	return virtualViewport{
+		KeyMap:          km,
+		MouseWheelDelta: 3,
+		width:           width,
+		height:          height,
+		offsets:         []int{0},
+	}
+}
+
+func (v *virtualViewport) Width() int      { return v.width }
+func (v *virtualViewport) Height() int     { return v.height }
+func (v *virtualViewport) SetWidth(w int)  { v.width = w }
+func (v *virtualViewport) SetHeight(h int) { v.height = h; v.clamp() }
+func (v *virtualViewport) YOffset() int    { return v.yOffset }
+func (v *virtualViewport) ItemCount() int  { return len(v.items) }
+func (v *virtualViewport) TotalLineCount() int {
+	return v.offsets[len(v.items)]
+}
+
+// SetItems replaces the content. load renders stale items on demand. The
+// item at the top of the window stays there, so re-rendering the items
+// above it (e.g. on resize) doesn't move what the user is reading.
+func (v *virtualViewport) SetItems(items []vpItem, load func(i int) vpItem) {
+	top, rel := v.ItemAt(v.yOffset)
+	v.items = items
+	v.load = load
+	v.reindex(0)
+	if top >= 0 && top < len(items) {
+		v.yOffset = v.offsets[top] + min(rel, max(0, items[top].height-1))
+	}
+	v.clamp()
+}
+
+// SetItem replaces one item, e.g. the entry being streamed.
+func (v *virtualViewport) SetItem(i int, item vpItem) {
+	v.items[i] = item
+	v.reindex(i)
+	v.clamp()
+}
+
+// ItemAt returns the item containing content line y and the line within
+// it, or -1 if y is past the end.
+func (v *virtualViewport) ItemAt(y int) (item, rel int) {
+	if len(v.items) == 0 || y < 0 || y >= v.TotalLineCount() {
+		return -1, 0
+	}
+	// Last item starting at or before y (items may be empty)
+	lo, hi := 0, len(v.items)-1
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if v.offsets[mid] <= y {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return lo, y - v.offsets[lo]
+}
+
+// Item returns item i, rendering it first if it is stale.
+func (v *virtualViewport) Item(i int) vpItem {
+	v.materialize(i)
+	return v.items[i]
+}
+
+func (v *virtualViewport) reindex(from int) {
+	if cap(v.offsets) < len(v.items)+1 {
+		v.offsets = append(v.offsets[:0], make([]int, len(v.items)+1)...)
+		from = 0
+	}
+	v.offsets = v.offsets[:len(v.items)+1]
+	for i := from; i < len(v.items); i++ {
+		v.offsets[i+1] = v.offsets[i] + v.items[i].height
+	}
+}
+
+func (v *virtualViewport) materialize(i int) bool {
+	if v.items[i].fresh || v.load == nil {
+		return false
+	}
+	v.items[i] = v.load(i)
+	v.reindex(i)
+	return true
+}
+
+func (v *virtualViewport) maxYOffset() int {
+	return max(0, v.TotalLineCount()-v.height)
+}
+
+func (v *virtualViewport) clamp() {
+	v.yOffset = max(0, min(v.yOffset, v.maxYOffset()))
+}
+
+func (v *virtualViewport) AtBottom() bool { return v.yOffset >= v.maxYOffset() }
+func (v *virtualViewport) AtTop() bool    { return v.yOffset <= 0 }
+func (v *virtualViewport) GotoBottom()    { v.yOffset = v.maxYOffset() }
+func (v *virtualViewport) GotoTop()       { v.yOffset = 0 }
+
+func (v *virtualViewport) SetYOffset(n int) {
+	v.yOffset = n
+	v.clamp()
+}
+
+func (v *virtualViewport) ScrollDown(n int) { v.SetYOffset(v.yOffset + n) }
+func (v *virtualViewport) ScrollUp(n int)   { v.SetYOffset(v.yOffset - n) }
+
+// ScrollPercent returns how far down the content the window is, from 0 to 1.
+func (v *virtualViewport) ScrollPercent() float64 {
+	if v.maxYOffset() == 0 {
+		return 1
+	}
+	return float64(v.yOffset) / float64(v.maxYOffset())
+}
+
+// Update scrolls on the pager keys and the mouse wheel.
+func (v virtualViewport) Update(msg tea.Msg) (virtualViewport, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, v.KeyMap.PageDown):
+			v.ScrollDown(v.height)
+		case key.Matches(msg, v.KeyMap.PageUp):
+			v.ScrollUp(v.height)
+		case key.Matches(msg, v.KeyMap.HalfPageDown):
+			v.ScrollDown(v.height / 2)
+		case key.Matches(msg, v.KeyMap.HalfPageUp):
+			v.ScrollUp(v.height / 2)
+		case key.Matches(msg, v.KeyMap.Down):
+			v.ScrollDown(1)
+		case key.Matches(msg, v.KeyMap.Up):
+			v.ScrollUp(1)
+		}
+	case tea.MouseWheelMsg:
+		switch msg.Button {
+		case tea.MouseWheelDown:
+			v.ScrollDown(v.MouseWheelDelta)
+		case tea.MouseWheelUp:
+			v.ScrollUp(v.MouseWheelDelta)
+		}
+	}
+	return v, nil
+}
+
+// View renders the visible window, materializing the items in it first.
+// Rendering can change their heights, so this repeats until the window only
+// holds fresh items; the bottom stays pinned if the window was there.
+func (v *virtualViewport) View() string {
+	if v.width <= 0 || v.height <= 0 {
+		return ""
+	}
+	atBottom := v.AtBottom()
+	for {
+		changed := false
+		first, _ := v.ItemAt(v.yOffset)
+		for i := max(first, 0); first >= 0 && i < len(v.items) && v.offsets[i] < v.yOffset+v.height; i++ {
+			if v.materialize(i) {
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+		if atBottom {
+			v.GotoBottom()
+		}
+		v.clamp()
+	}
+
+	visible := make([]string, 0, v.height)
+	first, rel := v.ItemAt(v.yOffset)
+	for i := max(first, 0); first >= 0 && i < len(v.items) && len(visible) < v.height; i++ {
+		lines := v.items[i].lines
+		if i == first {
+			lines = lines[min(rel, len(lines)):]
+		}
+		for _, l := range lines {
+			if len(visible) == v.height {
+				break
+			}
+			visible = append(visible, ansi.Truncate(l, v.width, ""))
+		}
+	}
+	return lipgloss.NewStyle().Width(v.width).Height(v.height).Render(strings.Join(visible, "\n"))
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+)
+
+// itemLines returns n lines "<name>.0" … "<name>.n-1".
+func itemLines(name string, n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("%s.%d", name, i)
+	}
+	return lines
+}
+
+func TestVirtualViewport(t *testing.T) {
+	// 100 stale items estimated at 1 line that render to 5 lines each
+	var loaded []int
+	items := make([]vpItem, 100)
+	for i := range items {
+		items[i] = vpItem{height: 1}
+	}
+	v := newVirtualViewport(20, 6)
+	v.SetItems(items, func(i int) vpItem {
+		loaded = append(loaded, i)
+		return vpItem{lines: itemLines(fmt.Sprint(i), 5), height: 5, fresh: true}
+	})
+
+	// Only the items in the window are rendered
+	v.GotoBottom()
+	view := v.View()
+	if !strings.Contains(view, "99.4") || len(loaded) > v.Height() {
+		t.Errorf("bottom view rendered items %v:\n%s", loaded, view)
+	}
+	if !v.AtBottom() {
+		t.Error("view should stay pinned to the bottom as heights change")
+	}
+
+	loaded = nil
+	v.GotoTop()
+	view = v.View()
+	if !strings.HasPrefix(view, "0.0") || len(loaded) != 2 {
+		t.Errorf("top view rendered items %v:\n%s", loaded, view)
+	}
+
+	if item, rel := v.ItemAt(7); item != 1 || rel != 2 {
+		t.Errorf("ItemAt(7) = %d, %d; want item 1 line 2", item, rel)
+	}
+	if item, _ := v.ItemAt(v.TotalLineCount()); item != -1 {
+		t.Errorf("ItemAt past the end = %d, want -1", item)
+	}
+
+	// Scrolling keys and the wheel move the window
+	v, _ = v.Update(tea.KeyPressMsg{Code: tea.KeyDown})
+	v, _ = v.Update(tea.MouseWheelMsg{Button: tea.MouseWheelDown})
+	if v.YOffset() != 4 {
+		t.Errorf("YOffset = %d after down + wheel, want 4", v.YOffset())
+	}
+
+	// Replacing the items keeps the top item in place
+	v.SetYOffset(7) // item 1, line 2
+	fresh := make([]vpItem, 100)
+	for i := range fresh {
+		fresh[i] = vpItem{lines: itemLines(fmt.Sprint(i), 3), height: 3, fresh: true}
+	}
+	v.SetItems(fresh, nil)
+	if item, rel := v.ItemAt(v.YOffset()); item != 1 || rel != 2 {
+		t.Errorf("after SetItems top is item %d line %d, want item 1 line 2", item, rel)
+	}
+}
+
+// longSession returns a conversation with turns×4 assistant blocks: text,
+// a Bash call with its result, and a closing paragraph.
+func longSession(turns int) []chatEntry {
+	var entries []chatEntry
+	for i := range turns {
+		entries = append(entries,
+			chatEntry{role: "user", text: fmt.Sprintf("step %d", i)},
+			chatEntry{role: "assistant", hasResult: true, model: "claude-sonnet-4-5", blocks: []ChatBlock{
+				{Kind: BlockText, Text: "Let me **check** the files.\n\n- one\n- two"},
+				{Kind: BlockToolUse, ToolName: "Bash", ToolID: fmt.Sprint("t", i), ToolInput: `{"command":"ls -1"}`},
+				{Kind: BlockToolResult, ToolID: fmt.Sprint("t", i), ToolOutput: "go.mod\nmain.go\nREADME.md"},
+				{Kind: BlockText, Text: fmt.Sprintf("Turn %d is done.", i)},
+			}},
+		)
+	}
+	return entries
+}
+
+func TestRenderCache(t *testing.T) {
+	m := newTestChat(NewPrintBackend())
+	m.appendEntries(longSession(200)...)
+	m.refreshViewport()
+	m.viewport.GotoBottom()
+	if view := stripANSI(m.viewport.View()); !strings.Contains(view, "Turn 199 is done.") {
+		t.Fatalf("bottom of the session not shown:\n%s", view)
+	}
+	last := len(m.entries) - 1
+	rendered := func(i int) entryRender { return m.entryRenders[m.entries[i].id] }
+	if rendered(0).lines != nil {
+		t.Error("entries far above the window should not be rendered")
+	}
+
+	// Resizing re-renders the visible entries only
+	m.SetSize(90, 40)
+	m.viewport.View()
+	width := m.transcriptWidth()
+	if rendered(last).key.width != width {
+		t.Errorf("last entry rendered at width %d, want %d", rendered(last).key.width, width)
+	}
+	if r := rendered(last - 40); r.lines != nil && r.key.width == width {
+		t.Error("entries outside the window should not be re-rendered on resize")
+	}
+
+	// Clicking a card re-renders just its entry
+	before := rendered(last - 2)
+	var toolLine int
+	for _, z := range rendered(last).zones {
+		if strings.HasPrefix(z.id, "tool-") {
+			toolLine = z.startLine
+		}
+	}
+	y := -1
+	for row := range m.viewport.Height() {
+		if item, line := m.viewport.ItemAt(row + m.viewport.YOffset()); item == last && line == toolLine {
+			y = row
+		}
+	}
+	if y < 0 {
+		t.Fatal("tool card of the last entry is not on screen")
+	}
+	const headerLines = 2
+	click := tea.MouseClickMsg{Button: tea.MouseLeft, X: 5, Y: y + headerLines}
+	m.Update(click)
+	if len(m.expandedCards) != 1 {
+		t.Fatalf("click did not toggle a card: %v", m.expandedCards)
+	}
+	m.viewport.View()
+	if rendered(last).key.expanded == "" {
+		t.Error("clicked entry should be re-rendered with its card expanded")
+	}
+	if rendered(last-2).key != before.key {
+		t.Error("other entries should keep their cached rendering")
+	}
+}
+
+func TestRenderCacheStableIDs(t *testing.T) {
+	m := newTestChat(NewPrintBackend())
+	m.appendEntries(longSession(2)...)
+	m.appendEntries(chatEntry{role: "assistant", streaming: true})
+	m.refreshViewport()
+	m.viewport.View()
+	streamID := m.entries[len(m.entries)-1].id
+	card := fmt.Sprintf("tool-%d-0", streamID)
+	m.expandedCards[card] = true
+
+	// A notice goes before the streaming entry without taking its cards
+	m.addNotice("notice", "Theme set to light")
+	notice := m.entries[len(m.entries)-2]
+	if notice.role != "notice" || notice.id == streamID || m.entries[len(m.entries)-1].id != streamID {
+		t.Fatalf("entries = %+v", m.entries[len(m.entries)-2:])
+	}
+	if got := m.expandedByEntry(); got[notice.id] != "" || got[streamID] != card {
+		t.Errorf("expanded cards by entry = %v", got)
+	}
+
+	// An entry replaced in place with one of the same size is re-rendered
+	first := m.entries[0]
+	m.entries[0] = chatEntry{id: m.newEntryID(), role: "user", text: "STEP 0"}
+	if len(m.entries[0].text) != len(first.text) {
+		t.Fatal("replacement should have the same size")
+	}
+	m.refreshViewport()
+	m.viewport.GotoTop()
+	if view := stripANSI(m.viewport.View()); !strings.Contains(view, "STEP 0") {
+		t.Errorf("replaced entry shows a stale render:\n%s", view)
+	}
+	if _, ok := m.entryRenders[first.id]; ok {
+		t.Error("renders of removed entries should be dropped")
+	}
+}
+
+// frameBudget is what a redraw may take to keep up with a 60 Hz terminal.
+const frameBudget = 16 * time.Millisecond
+
+// benchChat returns a chat showing the bottom of a session with 5,000
+// assistant blocks, with everything on screen rendered.
+func benchChat(tb testing.TB) *ChatModel {
+	tb.Helper()
+	m := newTestChat(NewPrintBackend())
+	m.SetSize(120, 50)
+	m.appendEntries(longSession(1250)...)
+	m.refreshViewport()
+	m.viewport.GotoBottom()
+	m.viewport.View()
+	return m
+}
+
+func BenchmarkResize5000Blocks(b *testing.B) {
+	m := benchChat(b)
+	for i := 0; b.Loop(); i++ {
+		m.SetSize(100+i%40, 50)
+		m.View()
+	}
+}
+
+func BenchmarkExpandCard5000Blocks(b *testing.B) {
+	m := benchChat(b)
+	click := tea.MouseClickMsg{Button: tea.MouseLeft, X: 5, Y: 40}
+	for b.Loop() {
+		m.Update(click)
+		m.View()
+	}
+}
+
+func BenchmarkStreamChunk5000Blocks(b *testing.B) {
+	m := benchChat(b)
+	m.appendEntries(chatEntry{role: "assistant", streaming: true,
+		blocks: []ChatBlock{{Kind: BlockText, Text: "Streaming"}}})
+	m.refreshStreamingViewport()
+	for b.Loop() {
+		m.entries[len(m.entries)-1].blocks[0].Text += " more"
+		m.refreshStreamingViewport()
+		m.View()
+	}
+}
+
+// TestFrameBudget5000Blocks asserts wall-clock times, so it only runs with
+// FLAWDCODE_TIMING_TESTS=1 (just test-timing) rather than on loaded CI.
+func TestFrameBudget5000Blocks(t *testing.T) {
+	if os.Getenv("FLAWDCODE_TIMING_TESTS") != "1" {
+		t.Skip("timing test; set FLAWDCODE_TIMING_TESTS=1 to run")
+	}
+	for _, bench := range []struct {
+		name string
+		fn   func(*testing.B)
+	}{
+		{"resize", BenchmarkResize5000Blocks},
+		{"expand card", BenchmarkExpandCard5000Blocks},
+		{"stream chunk", BenchmarkStreamChunk5000Blocks},
+	} {
+		res := testing.Benchmark(bench.fn)
+		if per := time.Duration(res.NsPerOp()); per > frameBudget {
+			t.Errorf("%s takes %v per frame at 5,000 blocks, budget %v", bench.name, per, frameBudget)
+		} else {
+			t.Logf("%s: %v per frame", bench.name, per)
+		}
+	}
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/rivo/uniseg"
+)
+
+// vtScreen is a small in-process terminal emulator. PTY output written to
+// it is run through an ANSI parser and applied to a grid of cells, so what
+// it holds is what a real terminal would show: cursor movement, erasing,
+// scroll regions and the alternate screen are honored rather than stripped.
+// Colors and other attributes are ignored; only the text matters here.
+type vtScreen struct {
+	width, height int
+	cells         [][]rune // height rows of width cells; 0 is the right half of a wide rune
+	x, y          int
+	wrapPending   bool // a rune was printed in the last column
+	savedX        int
+	savedY        int
+	top, bottom   int // scroll region rows, inclusive
+
+	main [][]rune // the main screen while the alternate one is shown
+	alt  bool
+
+	// bracketedPaste is set while the application asked for pasted text
+	// to be wrapped in ESC[200~ ... ESC[201~.
+	bracketedPaste bool
+
+	// replies holds answers to the application's queries (cursor position,
+	// device attributes), to be written back to it.
+	replies []byte
+
+	scrollback []string // lines scrolled off the top of the main screen
+	dropped    int      // scrollback lines discarded to stay under vtScrollbackMax
+
+	parser *ansi.Parser
+}
+
+const vtScrollbackMax = 10000 // scrollback lines kept
+
+// newVTScreen returns a blank width×height screen.
+func newVTScreen(width, height int) *vtScreen {
+	s := &vtScreen{width: width, height: height}
+	s.reset()
+	s.parser = ansi.NewParser()
+	s.parser.SetHandler(ansi.Handler{
+		Print:     s.print,
+		Execute:   s.execute,
+		HandleCsi: s.csi,
+		HandleEsc: s.esc,
+	})
+	return s
+}
+
+// reset clears the screen and all modes, keeping the scrollback.
+func (s *vtScreen) reset() {
+	s.cells = s.blankRows(s.height)
+	s.x, s.y, s.savedX, s.savedY = 0, 0, 0, 0
+	s.wrapPending = false
+	s.top, s.bottom = 0, s.height-1
+	s.main, s.alt = nil, false
+	s.bracketedPaste = false
+}
+
+// Write feeds terminal output to the screen. It never fails.
+func (s *vtScreen) Write(p []byte) (int, error) {
+	for _, b := range p {
+		s.parser.Advance(b)
+	}
+	return len(p), nil
+}
+
+// TakeReplies returns and clears the answers to queries seen so far.
+func (s *vtScreen) TakeReplies() []byte {
+	r := s.replies
+	s.replies = nil
+	return r
+}
+
+// Lines returns the screen's rows as text, trailing blanks trimmed.
+func (s *vtScreen) Lines() []string {
+	lines := make([]string, s.height)
+	for i, row := range s.cells {
+		lines[i] = rowText(row)
+	}
+	return lines
+}
+
+// String returns the screen's rows joined by newlines.
+func (s *vtScreen) String() string {
+	return strings.Join(s.Lines(), "\n")
+}
+
+// Scrolled returns how many lines have scrolled off the top so far; pass
+// it to ScrolledSince later to get the lines scrolled off in between.
+func (s *vtScreen) Scrolled() int {
+	return s.dropped + len(s.scrollback)
+}
+
+// ScrolledSince returns the lines scrolled off the top since Scrolled
+// returned mark, oldest first, minus any dropped from the scrollback.
+func (s *vtScreen) ScrolledSince(mark int) []string {
+	i := max(mark-s.dropped, 0)
+	return append([]string(nil), s.scrollback[min(i, len(s.scrollback)):]...)
+}
+
+func rowText(row []rune) string {
+	var sb strings.Builder
+	for _, r := range row {
+		if r != 0 {
+			sb.WriteRune(r)
+		}
+	}
+	return strings.TrimRight(sb.String(), " ")
+}
+
+func (s *vtScreen) blankRow() []rune {
+	row := make([]rune, s.width)
+	for i := range row {
+		row[i] = ' '
+	}
+	return row
+}
+
+func (s *vtScreen) blankRows(n int) [][]rune {
+	rows := make([][]rune, n)
+	for i := range rows {
+		rows[i] = s.blankRow()
+	}
+	return rows
+}
+
+// moveTo puts the cursor at (x, y), clamped to the screen.
+func (s *vtScreen) moveTo(x, y int) {
+	s.x = min(max(x, 0), s.width-1)
+	s.y = min(max(y, 0), s.height-1)
+	s.wrapPending = false
+}
+
+func (s *vtScreen) print(r rune) {
+	w := uniseg.StringWidth(string(r))
+	if w == 0 {
+		return // combining marks and zero-width joiners are dropped
+	}
+	if s.wrapPending || s.x+w > s.width {
+		s.x = 0
+		s.lineFeed()
+	}
+	s.wrapPending = false
+	s.cells[s.y][s.x] = r
+	if w == 2 && s.x+1 < s.width {
+		s.cells[s.y][s.x+1] = 0
+	}
+	s.x += w
+	if s.x >= s.width {
+		s.x = s.width - 1
+		s.wrapPending = true
+	}
+}
+
+func (s *vtScreen) execute(b byte) {
+	switch b {
+	case '\r':
+		s.moveTo(0, s.y)
+	case '\n', '\v', '\f':
+		s.lineFeed()
+	case '\b':
+		s.moveTo(s.x-1, s.y)
+	case '\t':
+		s.moveTo((s.x/8+1)*8, s.y)
+	}
+}
+
+// lineFeed moves the cursor down a row, scrolling at the region's bottom.
+func (s *vtScreen) lineFeed() {
+	s.wrapPending = false
+	switch {
+	case s.y == s.bottom:
+		s.scrollUp(1)
+	case s.y < s.height-1:
+		s.y++
+	}
+}
+
+// reverseIndex moves the cursor up a row, scrolling at the region's top.
+func (s *vtScreen) reverseIndex() {
+	s.wrapPending = false
+	switch {
+	case s.y == s.top:
+		s.scrollDown(1)
+	case s.y > 0:
+		s.y--
+	}
+}
+
+// scrollUp scrolls the region up n rows. Rows leaving the top of the
+// main screen go to the scrollback.
+func (s *vtScreen) scrollUp(n int) {
+	n = min(n, s.bottom-s.top+1)
+	if s.top == 0 && !s.alt {
+		for _, row := range s.cells[:n] {
+			s.scrollback = append(s.scrollback, rowText(row))
+		}
+		if over := len(s.scrollback) - vtScrollbackMax; over > 0 {
+			s.scrollback = append([]string(nil), s.scrollback[over:]...)
+			s.dropped += over
+		}
+	}
+	region := s.cells[s.top : s.bottom+1]
+	copy(region, region[n:])
+	for i := len(region) - n; i < len(region); i++ {
+		region[i] = s.blankRow()
+	}
+}
+
+// scrollDown scrolls the region down n rows, blank rows entering at the top.
+func (s *vtScreen) scrollDown(n int) {
+	n = min(n, s.bottom-s.top+1)
+	region := s.cells[s.top : s.bottom+1]
+	copy(region[n:], region)
+	for i := range n {
+		region[i] = s.blankRow()
+	}
+}
+
+// erase blanks cells [from, to) of row y.
+func (s *vtScreen) erase(y, from, to int) {
+	row := s.cells[y]
+	for i := max(from, 0); i < min(to, s.width); i++ {
+		row[i] = ' '
+	}
+}
+
+func (s *vtScreen) csi(cmd ansi.Cmd, params ansi.Params) {
+	// n returns parameter i, with 0 and missing parameters meaning def.
+	n := func(i, def int) int {
+		v, _, _ := params.Param(i, def)
+		if v == 0 {
+			return def
+		}
+		return v
+	}
+	if cmd.Prefix() == '?' {
+		switch cmd.Final() {
+		case 'h':
+			s.setModes(params, true)
+		case 'l':
+			s.setModes(params, false)
+		}
+		return
+	}
+	if cmd.Prefix() != 0 || cmd.Intermediate() != 0 {
+		return
+	}
+
+	switch cmd.Final() {
+	case 'A':
+		s.moveTo(s.x, s.y-n(0, 1))
+	case 'B', 'e':
+		s.moveTo(s.x, s.y+n(0, 1))
+	case 'C', 'a':
+		s.moveTo(s.x+n(0, 1), s.y)
+	case 'D':
+		s.moveTo(s.x-n(0, 1), s.y)
+	case 'E':
+		s.moveTo(0, s.y+n(0, 1))
+	case 'F':
+		s.moveTo(0, s.y-n(0, 1))
+	case 'G', '`':
+		s.moveTo(n(0, 1)-1, s.y)
+	case 'd':
+		s.moveTo(s.x, n(0, 1)-1)
+	case 'H', 'f':
+		s.moveTo(n(1, 1)-1, n(0, 1)-1)
+	case 'J':
+		switch mode, _, _ := params.Param(0, 0); mode {
+		case 0:
+			s.erase(s.y, s.x, s.width)
+			for y := s.y + 1; y < s.height; y++ {
+				s.erase(y, 0, s.width)
+			}
+		case 1:
+			for y := range s.y {
+				s.erase(y, 0, s.width)
+			}
+			s.erase(s.y, 0, s.x+1)
+		case 2, 3:
+			for y := range s.height {
+				s.erase(y, 0, s.width)
+			}
+		}
+	case 'K':
+		switch mode, _, _ := params.Param(0, 0); mode {
+		case 0:
+			s.erase(s.y, s.x, s.width)
+		case 1:
+			s.erase(s.y, 0, s.x+1)
+		case 2:
+			s.erase(s.y, 0, s.width)
+		}
+	case 'X':
+		s.erase(s.y, s.x, s.x+n(0, 1))
+	case 'P':
+		row := s.cells[s.y]
+		k := min(n(0, 1), s.width-s.x)
+		copy(row[s.x:], row[s.x+k:])
+		s.erase(s.y, s.width-k, s.width)
+	case '@':
+		row := s.cells[s.y]
+		k := min(n(0, 1), s.width-s.x)
+		copy(row[s.x+k:], row[s.x:])
+		s.erase(s.y, s.x, s.x+k)
+	case 'L', 'M':
+		if s.y < s.top || s.y > s.bottom {
+			return
+		}
+		// Both work on the part of the region from the cursor row down
+		top := s.top
+		s.top = s.y
+		if cmd.Final() == 'L' {
+			s.scrollDown(n(0, 1))
+		} else {
+			s.deleteLines(n(0, 1))
+		}
+		s.top = top
+		s.moveTo(0, s.y)
+	case 'S':
+		s.scrollUp(n(0, 1))
+	case 'T':
+		s.scrollDown(n(0, 1))
+	case 'r':
+		top, bottom := n(0, 1)-1, n(1, s.height)-1
+		if top < bottom && bottom < s.height {
+			s.top, s.bottom = top, bottom
+			s.moveTo(0, 0)
+		}
+	case 'n':
+		if mode, _, _ := params.Param(0, 0); mode == 6 {
+			s.replies = fmt.Appendf(s.replies, "\x1b[%d;%dR", s.y+1, s.x+1)
+		}
+	case 'c':
+		s.replies = append(s.replies, "\x1b[?62;22c"...)
+	case 's':
+		s.savedX, s.savedY = s.x, s.y
+	case 'u':
+		s.moveTo(s.savedX, s.savedY)
+	}
+}
+
+// deleteLines removes n rows at the region's top without saving them to
+// the scrollback.
+func (s *vtScreen) deleteLines(n int) {
+	n = min(n, s.bottom-s.top+1)
+	region := s.cells[s.top : s.bottom+1]
+	copy(region, region[n:])
+	for i := len(region) - n; i < len(region); i++ {
+		region[i] = s.blankRow()
+	}
+}
+
+// setModes handles the private modes (CSI ? ... h/l) that change what is
+// on screen or how input must be sent.
+func (s *vtScreen) setModes(params ansi.Params, on bool) {
+	params.ForEach(0, func(_, mode int, _ bool) {
+		switch mode {
+		case 47, 1047, 1049:
+			if on == s.alt {
+				return
+			}
+			if on {
+				if mode == 1049 {
+					s.savedX, s.savedY = s.x, s.y
+				}
+				s.main, s.cells = s.cells, s.blankRows(s.height)
+				s.alt = true
+			} else {
+				s.cells, s.main = s.main, nil
+				s.alt = false
+				if mode == 1049 {
+					s.moveTo(s.savedX, s.savedY)
+				}
+			}
+		case 2004:
+			s.bracketedPaste = on
+		}
+	})
+}
+
+func (s *vtScreen) esc(cmd ansi.Cmd) {
+	if cmd.Intermediate() != 0 {
+		return // charset designations and the like
+	}
+	switch cmd.Final() {
+	case '7':
+		s.savedX, s.savedY = s.x, s.y
+	case '8':
+		s.moveTo(s.savedX, s.savedY)
+	case 'D':
+		s.lineFeed()
+	case 'E':
+		s.x = 0
+		s.lineFeed()
+	case 'M':
+		s.reverseIndex()
+	case 'c':
+		s.reset()
+	}
+}
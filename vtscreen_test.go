@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestVTScreen(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string // screen rows, trailing blank rows omitted
+	}{
+		{"text and newlines", "hello\r\nworld", []string{"hello", "world"}},
+		{"colors are dropped", "\x1b[1;31mred\x1b[0m plain", []string{"red plain"}},
+		{"carriage return overwrites", "12345\rab", []string{"ab345"}},
+		{"backspace and tab", "ab\bc\td", []string{"ac      d"}},
+		{"autowrap at the last column", "0123456789ab", []string{"0123456789", "ab"}},
+		{"wrap is deferred until the next rune", "0123456789\r\nx", []string{"0123456789", "x"}},
+		{"wide runes take two cells", "日本語日本語", []string{"日本語日本", "語"}},
+		{"cursor position", "\x1b[3;4Hx\x1b[1;1Hy", []string{"y", "", "   x"}},
+		{"relative moves", "abc\x1b[2D\x1b[Bx\x1b[Ay\x1b[3Cz", []string{"aby   z", " x"}},
+		{"column and row absolute", "\x1b[5Ga\x1b[2db", []string{"    a", "     b"}},
+		{"erase to end of line", "hello\x1b[3D\x1b[K", []string{"he"}},
+		{"erase to start of line", "hello\x1b[3D\x1b[1K", []string{"   lo"}},
+		{"erase whole line", "hello\x1b[2Kx", []string{"     x"}},
+		{"erase below", "one\r\ntwo\r\nthree\x1b[2;2H\x1b[J", []string{"one", "t"}},
+		{"erase above", "one\r\ntwo\r\nthree\x1b[2;2H\x1b[1J", []string{"", "  o", "three"}},
+		{"erase screen", "one\r\ntwo\x1b[2Jx", []string{"", "   x"}},
+		{"erase and delete characters", "abcdef\x1b[1;2H\x1b[2X\x1b[1;5H\x1b[P", []string{"a  df"}},
+		{"insert characters", "abc\x1b[1;2H\x1b[2@", []string{"a  bc"}},
+		{"insert and delete lines", "1\r\n2\r\n3\x1b[2;1H\x1b[L\x1b[4;1H\x1b[M", []string{"1", "", "2"}},
+		{"redraw in place", "status 1\r\n> box\x1b[1A\r\x1b[Jstatus 2\r\n> box", []string{"status 2", "> box"}},
+		{"save and restore cursor", "a\x1b7\x1b[3;3Hb\x1b8c", []string{"ac", "", "  b"}},
+		{"alternate screen is discarded", "main\x1b[?1049halt\x1b[?1049l!", []string{"main!"}},
+		{"reverse index at the top scrolls down", "top\x1bMnew", []string{"   new", "top"}},
+		{"charset designations are ignored", "\x1b(Bok", []string{"ok"}},
+		{"sequences split across writes", "\x1b[", []string{""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newVTScreen(10, 5)
+			s.Write([]byte(tt.input))
+			got := s.Lines()
+			for len(got) > 0 && got[len(got)-1] == "" {
+				got = got[:len(got)-1]
+			}
+			want := tt.want
+			for len(want) > 0 && want[len(want)-1] == "" {
+				want = want[:len(want)-1]
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("screen = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestVTScreenSplitWrites(t *testing.T) {
+	s := newVTScreen(20, 3)
+	for _, b := range []byte("ab\x1b[1;31mc\x1b[2Dé日") {
+		s.Write([]byte{b})
+	}
+	if got := s.Lines()[0]; got != "aé日" {
+		t.Errorf("row = %q, want %q", got, "aé日")
+	}
+}
+
+func TestVTScreenScrollback(t *testing.T) {
+	s := newVTScreen(10, 3)
+	mark := s.Scrolled()
+	for i := range 5 {
+		s.Write(fmt.Appendf(nil, "line %d\r\n", i))
+	}
+	if got, want := s.ScrolledSince(mark), []string{"line 0", "line 1", "line 2"}; !slices.Equal(got, want) {
+		t.Errorf("scrolled = %q, want %q", got, want)
+	}
+	if got, want := s.Lines(), []string{"line 3", "line 4", ""}; !slices.Equal(got, want) {
+		t.Errorf("screen = %q, want %q", got, want)
+	}
+
+	// A scroll region keeps the rows outside it, and nothing reaches the scrollback
+	mark = s.Scrolled()
+	s.Write([]byte("\x1b[2J\x1b[1;1Hheader\x1b[2;3r\x1b[2;1Ha\r\nb\r\nc"))
+	if got, want := s.Lines(), []string{"header", "b", "c"}; !slices.Equal(got, want) {
+		t.Errorf("screen with scroll region = %q, want %q", got, want)
+	}
+	if got := s.ScrolledSince(mark); len(got) != 0 {
+		t.Errorf("scrolled inside a region = %q, want none", got)
+	}
+
+	// The alternate screen never scrolls into the scrollback
+	s.Write([]byte("\x1b[r\x1b[?1049h" + strings.Repeat("x\r\n", 10) + "\x1b[?1049l"))
+	if got := s.ScrolledSince(mark); len(got) != 0 {
+		t.Errorf("scrolled on the alternate screen = %q, want none", got)
+	}
+}
+
+func TestVTScreenModesAndReplies(t *testing.T) {
+	s := newVTScreen(10, 3)
+	s.Write([]byte("\x1b[?2004h"))
+	if !s.bracketedPaste {
+		t.Error("bracketed paste should be on")
+	}
+	s.Write([]byte("ab\x1b[6n\x1b[c"))
+	if got, want := string(s.TakeReplies()), "\x1b[1;3R\x1b[?62;22c"; got != want {
+		t.Errorf("replies = %q, want %q", got, want)
+	}
+	if got := s.TakeReplies(); got != nil {
+		t.Errorf("replies should be taken once, got %q", got)
+	}
+	s.Write([]byte("\x1b[?2004l"))
+	if s.bracketedPaste {
+		t.Error("bracketed paste should be off")
+	}
+}